@@ -77,6 +77,7 @@ func addCmd() *cobra.Command {
 
 func addCmdFunc(cmd *cobra.Command, args []string, flags addCmdFlags) error {
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -92,7 +92,7 @@ func whoAmICmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			box, err := devbox.Open(&devopt.Opts{Dir: wd, Stderr: cmd.ErrOrStderr()})
+			box, err := devbox.Open(&devopt.Opts{Ctx: cmd.Context(), Dir: wd, Stderr: cmd.ErrOrStderr()})
 			if err != nil {
 				return err
 			}
@@ -56,6 +56,7 @@ func cacheCmd() *cobra.Command {
 				)
 			}
 			box, err := devbox.Open(&devopt.Opts{
+				Ctx:    cmd.Context(),
 				Dir:    flags.path,
 				Stderr: cmd.ErrOrStderr(),
 			})
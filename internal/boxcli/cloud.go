@@ -141,6 +141,7 @@ func runCloudShellCmd(cmd *cobra.Command, flags *cloudShellCmdFlags) error {
 	}
 
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -158,6 +159,7 @@ func runCloudInit(cmd *cobra.Command, flags *cloudShellCmdFlags) error {
 	}
 
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -16,14 +16,14 @@ type configFlags struct {
 func (flags *configFlags) register(cmd *cobra.Command) {
 	flags.pathFlag.register(cmd)
 	cmd.Flags().StringVar(
-		&flags.environment, "environment", "dev", "environment to use, when supported (e.g.secrets support dev, prod, preview.)",
+		&flags.environment, "environment", "dev", "environment to use. Selects a profile from devbox.json, and secrets support dev, prod and preview",
 	)
 }
 
 func (flags *configFlags) registerPersistent(cmd *cobra.Command) {
 	flags.pathFlag.registerPersistent(cmd)
 	cmd.PersistentFlags().StringVar(
-		&flags.environment, "environment", "dev", "environment to use, when supported (e.g. secrets support dev, prod, preview.)",
+		&flags.environment, "environment", "dev", "environment to use. Selects a profile from devbox.json, and secrets support dev, prod and preview",
 	)
 }
 
@@ -108,6 +108,7 @@ func dockerfileCmd() *cobra.Command {
 		Args: cobra.MaximumNArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			box, err := devbox.Open(&devopt.Opts{
+				Ctx:         cmd.Context(),
 				Dir:         flags.config.path,
 				Environment: flags.config.environment,
 				Stderr:      cmd.ErrOrStderr(),
@@ -188,6 +189,7 @@ func genReadmeCmd() *cobra.Command {
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			box, err := devbox.Open(&devopt.Opts{
+				Ctx:         cmd.Context(),
 				Dir:         flags.config.path,
 				Environment: flags.config.environment,
 				Stderr:      cmd.ErrOrStderr(),
@@ -229,6 +231,7 @@ func genAliasCmd() *cobra.Command {
 					"Cannot use both --prefix and --no-prefix flags together")
 			}
 			box, err := devbox.Open(&devopt.Opts{
+				Ctx:    cmd.Context(),
 				Dir:    flags.config.path,
 				Stderr: cmd.ErrOrStderr(),
 			})
@@ -269,6 +272,7 @@ func genAliasCmd() *cobra.Command {
 func runGenerateCmd(cmd *cobra.Command, flags *generateCmdFlags) error {
 	// Check the directory exists.
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -296,6 +300,7 @@ func runGenerateDirenvCmd(cmd *cobra.Command, flags *generateCmdFlags) error {
 	}
 
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
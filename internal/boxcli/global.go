@@ -100,6 +100,7 @@ func ensureGlobalEnvEnabled(cmd *cobra.Command, args []string) error {
 	}
 
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:    cmd.Context(),
 		Dir:    path,
 		Stderr: cmd.ErrOrStderr(),
 	})
@@ -5,10 +5,15 @@ package boxcli
 
 import (
 	"fmt"
+	"io"
+	"slices"
 
+	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 )
@@ -16,27 +21,48 @@ import (
 type infoCmdFlags struct {
 	config   configFlags
 	markdown bool
+	output   outputFlag
 }
 
 func infoCmd() *cobra.Command {
 	flags := infoCmdFlags{}
 	command := &cobra.Command{
-		Use:     "info <pkg>",
-		Short:   "Display package info",
-		Args:    cobra.ExactArgs(1),
+		Use:   "info [<pkg>]",
+		Short: "Display package info",
+		Long: heredoc.Doc(`
+			Display information about a package, including any plugins that
+			it installs.
+
+			Without a package, info shows which included devbox.json files,
+			plugins and profiles the project's packages, environment
+			variables, scripts and init hooks come from.
+		`),
+		Args:    cobra.MaximumNArgs(1),
 		PreRunE: ensureNixInstalled,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return infoSourcesCmdFunc(cmd, flags)
+			}
 			return infoCmdFunc(cmd, args[0], flags)
 		},
 	}
 
 	flags.config.register(command)
 	command.Flags().BoolVar(&flags.markdown, "markdown", false, "output in markdown format")
+	flags.output.register(command)
 	return command
 }
 
 func infoCmdFunc(cmd *cobra.Command, pkg string, flags infoCmdFlags) error {
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+	if flags.markdown && flags.output.structured() {
+		return usererr.New("--markdown cannot be used with --output %s", flags.output.format)
+	}
+
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -45,6 +71,14 @@ func infoCmdFunc(cmd *cobra.Command, pkg string, flags infoCmdFlags) error {
 		return errors.WithStack(err)
 	}
 
+	if flags.output.structured() {
+		info, err := box.PackageInfo(cmd.Context(), pkg)
+		if err != nil {
+			return err
+		}
+		return flags.output.print(cmd.OutOrStdout(), info)
+	}
+
 	info, err := box.Info(cmd.Context(), pkg, flags.markdown)
 	if err != nil {
 		return errors.WithStack(err)
@@ -52,3 +86,58 @@ func infoCmdFunc(cmd *cobra.Command, pkg string, flags infoCmdFlags) error {
 	fmt.Fprint(cmd.OutOrStdout(), info)
 	return nil
 }
+
+func infoSourcesCmdFunc(cmd *cobra.Command, flags infoCmdFlags) error {
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+	if flags.markdown {
+		return usererr.New("--markdown can only be used with a package")
+	}
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sources := box.ConfigSources()
+	if flags.output.structured() {
+		return flags.output.print(cmd.OutOrStdout(), sources)
+	}
+	if sources.Empty() {
+		fmt.Fprintln(cmd.OutOrStdout(), "Everything in this project is declared in its devbox.json.")
+		return nil
+	}
+	printConfigSources(cmd.OutOrStdout(), sources)
+	return nil
+}
+
+// printConfigSources prints the parts of a project's config that come from
+// included files, plugins and profiles, grouped by kind. Kinds without any
+// included parts are skipped.
+func printConfigSources(w io.Writer, sources *devbox.ConfigSources) {
+	printSection := func(title string, items map[string]string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s:\n", title)
+		names := lo.Keys(items)
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "* %s (from %s)\n", name, items[name])
+		}
+	}
+	printSection("Packages", sources.Packages)
+	printSection("Env", sources.Env)
+	printSection("Scripts", sources.Scripts)
+	if len(sources.InitHook) > 0 {
+		fmt.Fprintln(w, "Init hooks:")
+		for _, source := range sources.InitHook {
+			fmt.Fprintf(w, "* from %s\n", source)
+		}
+	}
+}
@@ -45,6 +45,7 @@ func installCmd() *cobra.Command {
 func installCmdFunc(cmd *cobra.Command, flags installCmdFlags) error {
 	// Check the directory exists.
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -86,6 +86,7 @@ func runIntegrateVSCodeCmd(cmd *cobra.Command, flags integrateCmdFlags) error {
 
 	// todo: add error handling - consider sending error message to parent process
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:    cmd.Context(),
 		Dir:    message.ConfigDir,
 		Stderr: cmd.ErrOrStderr(),
 	})
@@ -5,16 +5,20 @@ package boxcli
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig"
 )
 
 type listCmdFlags struct {
 	config configFlags
+	output outputFlag
+	tree   bool
 }
 
 func listCmd() *cobra.Command {
@@ -25,19 +29,98 @@ func listCmd() *cobra.Command {
 		Short:   "List installed packages",
 		PreRunE: ensureNixInstalled,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.output.validate(); err != nil {
+				return err
+			}
 			box, err := devbox.Open(&devopt.Opts{
+				Ctx:    cmd.Context(),
 				Dir:    flags.config.path,
 				Stderr: cmd.ErrOrStderr(),
 			})
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			for _, p := range box.AllPackageNamesIncludingRemovedTriggerPackages() {
-				fmt.Fprintf(cmd.OutOrStdout(), "* %s\n", p)
+			if flags.tree {
+				tree := box.Config().PackageTree()
+				if flags.output.structured() {
+					return flags.output.print(cmd.OutOrStdout(), tree)
+				}
+				printTree(cmd.OutOrStdout(), packageTreeNode(tree), "")
+				return nil
+			}
+			packages, err := box.ListPackages(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if flags.output.structured() {
+				return flags.output.print(cmd.OutOrStdout(), packages)
+			}
+			for _, p := range packages {
+				if p.Source != "" {
+					fmt.Fprintf(cmd.OutOrStdout(), "* %s (from %s)\n", p.Name, p.Source)
+				} else {
+					fmt.Fprintf(cmd.OutOrStdout(), "* %s\n", p.Name)
+				}
+			}
+			// Packages are already listed with their sources.
+			sources := box.ConfigSources()
+			sources.Packages = nil
+			if !sources.Empty() {
+				fmt.Fprintln(cmd.OutOrStdout())
+				printConfigSources(cmd.OutOrStdout(), sources)
 			}
 			return nil
 		},
 	}
 	flags.config.register(cmd)
+	flags.output.register(cmd)
+	cmd.Flags().BoolVar(
+		&flags.tree, "tree", false,
+		"show which devbox.json or plugin added each package",
+	)
 	return cmd
 }
+
+// treeNode is a line of text output in a tree.
+type treeNode struct {
+	label    string
+	children []treeNode
+}
+
+// packageTreeNode converts a config's packages and the configs that it
+// includes to a tree for printing. Built-in plugins are placed under the
+// package that activated them.
+func packageTreeNode(tree *devconfig.PackageTree) treeNode {
+	node := treeNode{label: tree.Source}
+	if tree.Plugin != "" && tree.Source != "plugin:"+tree.Plugin {
+		node.label += " (" + tree.Plugin + ")"
+	}
+	for _, pkg := range tree.Packages {
+		child := treeNode{label: pkg.Name}
+		if pkg.ReplacedBy != "" {
+			child.label += " (replaced by " + pkg.ReplacedBy + ")"
+		}
+		for _, p := range pkg.Plugins {
+			child.children = append(child.children, packageTreeNode(p))
+		}
+		node.children = append(node.children, child)
+	}
+	for _, include := range tree.Includes {
+		node.children = append(node.children, packageTreeNode(include))
+	}
+	return node
+}
+
+// printTree prints node and its children. indent is the prefix of the lines
+// below node's own line.
+func printTree(w io.Writer, node treeNode, indent string) {
+	fmt.Fprintln(w, node.label)
+	for i, child := range node.children {
+		branch, next := "├── ", "│   "
+		if i == len(node.children)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Fprint(w, indent+branch)
+		printTree(w, child, indent+next)
+	}
+}
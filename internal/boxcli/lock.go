@@ -0,0 +1,243 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// lockVerifyExitCodes are the exit codes of `devbox lock verify`. When there
+// are several kinds of issues, the lowest code wins.
+var lockVerifyExitCodes = map[lock.VerifyIssueKind]int{
+	lock.VerifyMismatch:  2,
+	lock.VerifyMissing:   3,
+	lock.VerifyStale:     4,
+	lock.VerifyEvalError: 5,
+}
+
+type lockCmdFlags struct {
+	config     configFlags
+	allSystems bool
+	systems    []string
+}
+
+type lockVerifyCmdFlags struct {
+	config configFlags
+	output outputFlag
+}
+
+type lockDiffCmdFlags struct {
+	config configFlags
+	output outputFlag
+}
+
+func lockCmd() *cobra.Command {
+	flags := &lockCmdFlags{}
+	command := &cobra.Command{
+		Use:   "lock",
+		Short: "Update and verify the devbox.lock file",
+		Long: heredoc.Doc(`
+			Resolve every package in devbox.json and record its store paths in
+			devbox.lock without installing anything. By default the systems in the
+			systems field of devbox.json are locked, or only the current system if
+			it's not set. Use --all-systems to lock every system in --systems, so
+			that teammates on other platforms don't have to resolve packages
+			themselves.
+		`),
+		Args:              cobra.ExactArgs(0),
+		PersistentPreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lockCmdFunc(cmd, flags)
+		},
+	}
+
+	flags.config.register(command)
+	command.Flags().BoolVar(
+		&flags.allSystems, "all-systems", false,
+		"lock every system in --systems instead of only the current one")
+	command.Flags().StringSliceVar(
+		&flags.systems, "systems", devbox.DefaultLockSystems,
+		"systems to lock when --all-systems is set, instead of the systems in devbox.json")
+
+	command.AddCommand(lockDiffCmd())
+	command.AddCommand(lockVerifyCmd())
+	return command
+}
+
+func lockCmdFunc(cmd *cobra.Command, flags *lockCmdFlags) error {
+	if cmd.Flags().Changed("systems") && !flags.allSystems {
+		return usererr.New("--systems requires --all-systems")
+	}
+
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Without --systems, --all-systems locks the systems in devbox.json if
+	// it has any.
+	opts := devopt.LockOpts{}
+	if flags.allSystems &&
+		(cmd.Flags().Changed("systems") || len(box.Config().Root.Systems) == 0) {
+		opts.Systems = flags.systems
+	}
+	return box.Lock(cmd.Context(), opts)
+}
+
+func lockDiffCmd() *cobra.Command {
+	flags := &lockDiffCmdFlags{}
+	command := &cobra.Command{
+		Use:   "diff [git-ref]",
+		Short: "Show how devbox.lock changed since a git revision",
+		Long: heredoc.Doc(`
+			Compare devbox.lock in the working tree with the one at a git revision
+			(HEAD by default) and print the packages that were added, removed, or
+			changed. For changed packages, the version and resolved reference
+			changes are shown along with the outputs that were added or removed and
+			the store paths that changed for each system.
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref := "HEAD"
+			if len(args) > 0 {
+				ref = args[0]
+			}
+			return lockDiffCmdFunc(cmd, ref, flags)
+		},
+	}
+
+	flags.config.register(command)
+	flags.output.register(command)
+	return command
+}
+
+func lockDiffCmdFunc(cmd *cobra.Command, ref string, flags *lockDiffCmdFlags) error {
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	diff, err := box.LockfileDiff(cmd.Context(), ref)
+	if err != nil {
+		return err
+	}
+	return printLockDiff(cmd.OutOrStdout(), diff, &flags.output)
+}
+
+// printLockDiff prints diff for `devbox lock diff` and `devbox update
+// --dry-run`.
+func printLockDiff(w io.Writer, diff *lock.Diff, output *outputFlag) error {
+	if output.structured() {
+		return output.print(w, diff)
+	}
+	diff.Print(w)
+	return nil
+}
+
+func lockVerifyCmd() *cobra.Command {
+	flags := &lockVerifyCmdFlags{}
+	command := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that devbox.lock matches devbox.json and nix",
+		Long: heredoc.Doc(`
+			Verify that devbox.lock matches devbox.json and that the store paths it
+			records are the ones nix evaluates each resolved reference to. Only the
+			current system is evaluated. The lockfile is not modified.
+
+			Exit codes:
+			  0  the lockfile is valid
+			  2  recorded store paths don't match the resolved reference
+			  3  a package has no entry, reference, or outputs for this system
+			  4  the lockfile has entries that devbox.json doesn't use
+			  5  a resolved reference could not be evaluated
+
+			When there are several kinds of issues, the lowest code is used.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lockVerifyCmdFunc(cmd, flags)
+		},
+	}
+
+	flags.config.register(command)
+	flags.output.register(command)
+	return command
+}
+
+func lockVerifyCmdFunc(cmd *cobra.Command, flags *lockVerifyCmdFlags) error {
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	report, err := box.VerifyLockfile(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if flags.output.structured() {
+		if err := flags.output.print(cmd.OutOrStdout(), report); err != nil {
+			return err
+		}
+	} else {
+		printLockVerifyReport(cmd.OutOrStdout(), report)
+	}
+
+	if report.OK() {
+		return nil
+	}
+	code := 0
+	for _, issue := range report.Issues {
+		if c := lockVerifyExitCodes[issue.Kind]; code == 0 || c < code {
+			code = c
+		}
+	}
+	return usererr.NewExitCode(code, "devbox.lock failed verification with %d issue(s)", len(report.Issues))
+}
+
+func printLockVerifyReport(w io.Writer, report *lock.VerifyReport) {
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "%s: %s: %s\n", issue.Kind, issue.Package, issue.Message)
+		for _, path := range issue.Locked {
+			fmt.Fprintf(w, "    locked:    %s\n", path)
+		}
+		for _, path := range issue.Evaluated {
+			fmt.Fprintf(w, "    evaluated: %s\n", path)
+		}
+	}
+	fmt.Fprintf(w, "Verified %d package(s) for %s.\n", report.Verified, report.System)
+}
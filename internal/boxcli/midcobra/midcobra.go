@@ -73,6 +73,10 @@ func (ex *midcobraExecutable) Execute(ctx context.Context, args []string) int {
 		// Note: order matters! Check if it is a user exec error before a generic exit error.
 		var exitErr *exec.ExitError
 		var userExecErr *usererr.ExitError
+		var exitCodeErr *usererr.ExitCodeError
+		if errors.As(err, &exitCodeErr) {
+			return exitCodeErr.ExitCode()
+		}
 		if errors.As(err, &userExecErr) {
 			return userExecErr.ExitCode()
 		}
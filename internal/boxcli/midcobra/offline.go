@@ -0,0 +1,44 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package midcobra
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// OfflineMiddleware turns on offline mode when its flag is set. Offline mode
+// is stored in the DEVBOX_OFFLINE environment variable so that it also
+// applies to the devbox and nix processes that this command starts.
+type OfflineMiddleware struct {
+	flag *pflag.Flag
+}
+
+var _ Middleware = (*OfflineMiddleware)(nil)
+
+func (o *OfflineMiddleware) AttachToFlag(flags *pflag.FlagSet, flagName string) {
+	flags.Bool(
+		flagName,
+		false,
+		"never access the network and only use devbox.lock and the local nix store. "+
+			"Can also be set with "+envir.DevboxOffline+"=1",
+	)
+	o.flag = flags.Lookup(flagName)
+}
+
+func (o *OfflineMiddleware) preRun(cmd *cobra.Command, args []string) {
+	if o == nil || o.flag == nil || !o.flag.Changed {
+		return
+	}
+	if enabled, _ := strconv.ParseBool(o.flag.Value.String()); enabled {
+		os.Setenv(envir.DevboxOffline, "1")
+	}
+}
+
+func (o *OfflineMiddleware) postRun(cmd *cobra.Command, args []string, runErr error) {}
@@ -0,0 +1,113 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// Exit codes of `devbox outdated`. When some packages are outdated and others
+// couldn't be checked, outdatedExitCode wins.
+const (
+	outdatedExitCode        = 2
+	outdatedUnknownExitCode = 3
+)
+
+type outdatedCmdFlags struct {
+	config configFlags
+	output outputFlag
+}
+
+func outdatedCmd() *cobra.Command {
+	flags := &outdatedCmdFlags{}
+	command := &cobra.Command{
+		Use:   "outdated",
+		Short: "List packages that have newer versions available",
+		Long: heredoc.Doc(`
+			List the packages in devbox.json that have a newer version than the one
+			in devbox.lock. Current is the locked version, wanted is the newest
+			version that satisfies devbox.json (what devbox update would install),
+			and latest is the newest version available. For flakes, current is the
+			locked revision, wanted is the revision that the flake reference points
+			to now and latest is the revision of the repository's default branch.
+
+			The lockfile is not modified. The command exits with code 2 when any
+			package is outdated, and with code 3 when some packages couldn't be
+			checked for updates, so it can be used in scheduled CI jobs.
+		`),
+		Args:    cobra.ExactArgs(0),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outdatedCmdFunc(cmd, flags)
+		},
+	}
+
+	flags.config.register(command)
+	flags.output.register(command)
+	return command
+}
+
+func outdatedCmdFunc(cmd *cobra.Command, flags *outdatedCmdFlags) error {
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	packages, err := box.Outdated(cmd.Context())
+	if err != nil {
+		return err
+	}
+	unknown := lo.CountBy(packages, func(p devbox.OutdatedPackage) bool { return p.Unknown() })
+	outdated := len(packages) - unknown
+
+	if flags.output.structured() {
+		if err := flags.output.print(cmd.OutOrStdout(), packages); err != nil {
+			return err
+		}
+	} else if len(packages) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "All packages are up to date.")
+	} else {
+		table := tablewriter.NewWriter(cmd.OutOrStdout())
+		table.SetHeader([]string{"Package", "Current", "Wanted", "Latest"})
+		table.SetAutoFormatHeaders(false)
+		table.SetBorder(false)
+		for _, pkg := range packages {
+			if pkg.Unknown() {
+				table.Append([]string{pkg.Package, "unknown", "unknown", "unknown"})
+				ux.Fwarning(cmd.ErrOrStderr(), "Unable to check %s for updates: %s\n", pkg.Package, pkg.Error)
+				continue
+			}
+			table.Append([]string{pkg.Package, pkg.Current, pkg.Wanted, pkg.Latest})
+		}
+		table.Render()
+	}
+
+	if outdated > 0 {
+		return usererr.NewExitCode(outdatedExitCode, "%d package(s) are outdated", outdated)
+	}
+	if unknown > 0 {
+		return usererr.NewExitCode(
+			outdatedUnknownExitCode, "%d package(s) couldn't be checked for updates", unknown)
+	}
+	return nil
+}
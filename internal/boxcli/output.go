@@ -0,0 +1,62 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// outputFlag is the --output flag of commands that can print structured data
+// for scripts in addition to text for humans.
+type outputFlag struct {
+	format string
+}
+
+func (f *outputFlag) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&f.format, "output", "o", "text", "output format, one of text, json, or yaml")
+}
+
+func (f *outputFlag) validate() error {
+	switch f.format {
+	case "text", "json", "yaml":
+		return nil
+	}
+	return usererr.New("invalid output format %q, must be text, json, or yaml", f.format)
+}
+
+// structured returns true if the output should be printed with print instead
+// of as text.
+func (f *outputFlag) structured() bool {
+	return f.format == "json" || f.format == "yaml"
+}
+
+// print writes v to w as JSON or YAML.
+func (f *outputFlag) print(w io.Writer, v any) error {
+	switch f.format {
+	case "json":
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return errors.WithStack(err)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(enc.Close())
+	}
+	return errors.Errorf("unsupported output format %q", f.format)
+}
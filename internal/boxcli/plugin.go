@@ -0,0 +1,335 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/plugin"
+)
+
+type pluginCmdFlags struct {
+	config configFlags
+	output outputFlag
+}
+
+type pluginUpdateCmdFlags struct {
+	config configFlags
+}
+
+func pluginCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "plugin",
+		Short: "Manage the plugins included in your project",
+	}
+	command.AddCommand(pluginInitCmd())
+	command.AddCommand(pluginListCmd())
+	command.AddCommand(pluginRenderCmd())
+	command.AddCommand(pluginShowCmd())
+	command.AddCommand(pluginUpdateCmd())
+	command.AddCommand(pluginValidateCmd())
+	return command
+}
+
+func pluginListCmd() *cobra.Command {
+	flags := pluginCmdFlags{}
+	command := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the plugins included in your project",
+		Long: heredoc.Doc(`
+			List the plugins included in your project, including plugins
+			included by other plugins and the built-in plugins of packages.
+		`),
+		Args:    cobra.NoArgs,
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.output.validate(); err != nil {
+				return err
+			}
+			box, err := openPluginBox(cmd, flags.config)
+			if err != nil {
+				return err
+			}
+			plugins, err := box.ListPlugins(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if flags.output.structured() {
+				return flags.output.print(cmd.OutOrStdout(), plugins)
+			}
+			if len(plugins) == 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No plugins are included in this project.")
+				return nil
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 3, 2, 4, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tVERSION\tTYPE\tINCLUDE")
+			for _, p := range plugins {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Name, p.Version, p.Type, p.Include)
+			}
+			return errors.WithStack(tw.Flush())
+		},
+	}
+	flags.config.register(command)
+	flags.output.register(command)
+	return command
+}
+
+func pluginShowCmd() *cobra.Command {
+	flags := pluginCmdFlags{}
+	command := &cobra.Command{
+		Use:   "show <name>",
+		Short: "Show the details of a plugin",
+		Long: heredoc.Doc(`
+			Show the details of a plugin, including the packages, environment
+			variables, files, services, scripts, and lifecycle hooks that it adds
+			to your project.
+
+			<name> is the name of an included plugin or an include reference,
+			such as path:./my-plugin, which doesn't have to be included yet.
+		`),
+		Args:    cobra.ExactArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.output.validate(); err != nil {
+				return err
+			}
+			box, err := openPluginBox(cmd, flags.config)
+			if err != nil {
+				return err
+			}
+			info, err := box.PluginInfo(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if flags.output.structured() {
+				return flags.output.print(cmd.OutOrStdout(), info)
+			}
+			printPluginInfo(cmd.OutOrStdout(), info)
+			return nil
+		},
+	}
+	flags.config.register(command)
+	flags.output.register(command)
+	return command
+}
+
+func printPluginInfo(w io.Writer, info *devbox.PluginInfo) {
+	fmt.Fprintf(w, "Name:     %s\n", info.Name)
+	if info.Version != "" {
+		fmt.Fprintf(w, "Version:  %s\n", info.Version)
+	}
+	fmt.Fprintf(w, "Type:     %s\n", info.Type)
+	fmt.Fprintf(w, "Include:  %s\n", info.Include)
+	if info.Rev != "" {
+		fmt.Fprintf(w, "Rev:      %s\n", info.Rev)
+	}
+	if info.Hash != "" {
+		fmt.Fprintf(w, "Hash:     %s\n", info.Hash)
+	}
+	if info.Description != "" {
+		fmt.Fprintf(w, "\n%s\n", info.Description)
+	}
+	printList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s:\n", title)
+		for _, item := range items {
+			fmt.Fprintf(w, "* %s\n", item)
+		}
+	}
+	printList("Packages", info.Packages)
+	env := []string{}
+	for name, value := range info.Env {
+		env = append(env, name+"="+value)
+	}
+	slices.Sort(env)
+	printList("Environment variables", env)
+	printList("Files", info.Files)
+	printList("Services", info.Services)
+	printList("Scripts", info.Scripts)
+	printList("Hooks", lo.Map(info.Hooks, func(e plugin.HookEvent, _ int) string { return string(e) }))
+}
+
+func pluginRenderCmd() *cobra.Command {
+	flags := pluginCmdFlags{}
+	command := &cobra.Command{
+		Use:   "render <name>",
+		Short: "Print the files that a plugin creates in your project",
+		Long: heredoc.Doc(`
+			Print the files in a plugin's create_files after templating them
+			for your project, without writing them.
+
+			<name> is the name of an included plugin or an include reference,
+			such as path:./my-plugin, which doesn't have to be included yet.
+		`),
+		Args:    cobra.ExactArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.output.validate(); err != nil {
+				return err
+			}
+			box, err := openPluginBox(cmd, flags.config)
+			if err != nil {
+				return err
+			}
+			files, err := box.RenderPlugin(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if flags.output.structured() {
+				return flags.output.print(cmd.OutOrStdout(), files)
+			}
+			for i, f := range files {
+				if i > 0 {
+					fmt.Fprintln(cmd.OutOrStdout())
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "=== %s (from %s) ===\n", f.Path, f.ContentPath)
+				fmt.Fprint(cmd.OutOrStdout(), f.Content)
+				if !strings.HasSuffix(f.Content, "\n") {
+					fmt.Fprintln(cmd.OutOrStdout())
+				}
+			}
+			return nil
+		},
+	}
+	flags.config.register(command)
+	flags.output.register(command)
+	return command
+}
+
+func pluginInitCmd() *cobra.Command {
+	var name string
+	command := &cobra.Command{
+		Use:   "init [dir]",
+		Short: "Create a new plugin",
+		Long: heredoc.Doc(`
+			Create a plugin.json in [dir], or the current directory, with a
+			process-compose.yaml for the plugin's services. Include the plugin
+			in a project with "path:<dir>", and check it with
+			devbox plugin validate.
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			created, err := plugin.Init(dir, name)
+			if err != nil {
+				return err
+			}
+			wd, _ := os.Getwd()
+			for _, path := range created {
+				if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+					path = rel
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Created %s\n", path)
+			}
+			return nil
+		},
+	}
+	command.Flags().StringVar(
+		&name, "name", "", "name of the plugin. Defaults to the name of the directory")
+	return command
+}
+
+func pluginValidateCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "validate [path]",
+		Short: "Check a plugin for errors",
+		Long: heredoc.Doc(`
+			Check the plugin.json at [path], or in the directory at [path], for
+			errors. It checks that plugin.json matches the plugin schema, that
+			the files in create_files exist, and that templates only use
+			variables that devbox provides, such as {{ .Virtenv }}.
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "."
+			if len(args) > 0 {
+				path = args[0]
+			}
+			problems := plugin.Validate(cmd.Context(), path)
+			if len(problems) == 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Plugin %s is valid.\n", path)
+				return nil
+			}
+			for _, problem := range problems {
+				fmt.Fprintf(cmd.OutOrStdout(), "* %s\n", problem)
+			}
+			return usererr.New("Plugin %s has %d problem(s).", path, len(problems))
+		},
+	}
+	return command
+}
+
+func pluginUpdateCmd() *cobra.Command {
+	flags := &pluginUpdateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "update [name]...",
+		Short: "Update included plugins to their latest commit",
+		Long: heredoc.Doc(`
+			Update included plugins to the latest commit of their ref, and pin
+			their new commit and content hash in devbox.lock.
+
+			Plugins in GitHub and git repositories, tarballs, and URLs are pinned
+			when they're first included, and devbox refuses to load them if
+			their content changes upstream. Plugins are only updated by this
+			command, or by running devbox update without any packages.
+
+			[name] is the name of a plugin or its include reference, such as
+			github:org/repo?dir=plugin. If no names are given, every pinned
+			plugin is updated.
+		`),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pluginUpdateCmdFunc(cmd, args, flags)
+		},
+	}
+	flags.config.register(command)
+	return command
+}
+
+func pluginUpdateCmdFunc(cmd *cobra.Command, args []string, flags *pluginUpdateCmdFlags) error {
+	// Plugins that no longer match their pins fail to load, so download them
+	// again while opening the project. Plugin names can only be matched after
+	// the plugins load, which UpdatePlugins does.
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:              cmd.Context(),
+		Dir:              flags.config.path,
+		Environment:      flags.config.environment,
+		Stderr:           cmd.ErrOrStderr(),
+		UpdatePlugins:    args,
+		UpdateAllPlugins: len(args) == 0,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return box.UpdatePlugins(cmd.Context(), args...)
+}
+
+func openPluginBox(cmd *cobra.Command, flags configFlags) (*devbox.Devbox, error) {
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
+		Dir:         flags.path,
+		Environment: flags.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	return box, errors.WithStack(err)
+}
@@ -32,6 +32,7 @@ func removeCmd() *cobra.Command {
 
 func runRemoveCmd(cmd *cobra.Command, args []string, flags removeCmdFlags) error {
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
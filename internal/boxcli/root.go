@@ -25,8 +25,9 @@ import (
 type cobraFunc func(cmd *cobra.Command, args []string) error
 
 var (
-	debugMiddleware = &midcobra.DebugMiddleware{}
-	traceMiddleware = &midcobra.TraceMiddleware{}
+	debugMiddleware   = &midcobra.DebugMiddleware{}
+	offlineMiddleware = &midcobra.OfflineMiddleware{}
+	traceMiddleware   = &midcobra.TraceMiddleware{}
 )
 
 type rootCmdFlags struct {
@@ -69,7 +70,10 @@ func RootCmd() *cobra.Command {
 	command.AddCommand(installCmd())
 	command.AddCommand(integrateCmd())
 	command.AddCommand(listCmd())
+	command.AddCommand(lockCmd())
 	command.AddCommand(logCmd())
+	command.AddCommand(outdatedCmd())
+	command.AddCommand(pluginCmd())
 	command.AddCommand(removeCmd())
 	command.AddCommand(runCmd(runFlagDefaults{}))
 	command.AddCommand(searchCmd())
@@ -100,6 +104,7 @@ func RootCmd() *cobra.Command {
 	command.PersistentFlags().BoolVarP(
 		&flags.quiet, "quiet", "q", false, "suppresses logs")
 	debugMiddleware.AttachToFlag(command.PersistentFlags(), "debug")
+	offlineMiddleware.AttachToFlag(command.PersistentFlags(), "offline")
 	traceMiddleware.AttachToFlag(command.PersistentFlags(), "trace")
 
 	return command
@@ -109,6 +114,8 @@ func Execute(ctx context.Context, args []string) int {
 	defer debug.Recover()
 	rootCmd := RootCmd()
 	exe := midcobra.New(rootCmd)
+	// The offline middleware goes first so that the others see offline mode.
+	exe.AddMiddleware(offlineMiddleware)
 	exe.AddMiddleware(traceMiddleware)
 	exe.AddMiddleware(midcobra.Telemetry())
 	exe.AddMiddleware(debugMiddleware)
@@ -70,6 +70,7 @@ func runCmd(defaults runFlagDefaults) *cobra.Command {
 
 func listScripts(cmd *cobra.Command, flags runCmdFlags) []string {
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:            cmd.Context(),
 		Dir:            flags.config.path,
 		Environment:    flags.config.environment,
 		Stderr:         cmd.ErrOrStderr(),
@@ -110,6 +111,7 @@ func runScriptCmd(cmd *cobra.Command, args []string, flags runCmdFlags) error {
 
 	// Check the directory exists.
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
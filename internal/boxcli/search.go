@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/searcher"
 	"go.jetpack.io/devbox/internal/ux"
 )
@@ -21,6 +22,8 @@ const trimmedVersionsLength = 10
 
 type searchCmdFlags struct {
 	showAll bool
+	reindex bool
+	output  outputFlag
 }
 
 func searchCmd() *cobra.Command {
@@ -30,22 +33,40 @@ func searchCmd() *cobra.Command {
 		Short: "Search for nix packages",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.output.validate(); err != nil {
+				return err
+			}
+			s, err := devbox.SearcherForDir("", cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			if flags.reindex {
+				if err := searcher.RebuildLocalIndex(cmd.Context(), s); err != nil {
+					return err
+				}
+			}
 			query := args[0]
 			name, version, isVersioned := searcher.ParseVersionedPackage(query)
 			if !isVersioned {
-				results, err := searcher.Client().Search(query)
+				results, err := s.Search(cmd.Context(), query)
 				if err != nil {
 					return err
 				}
+				if flags.output.structured() {
+					return flags.output.print(cmd.OutOrStdout(), searchPackageInfos(results))
+				}
 				return printSearchResults(
 					cmd.OutOrStdout(), query, results, flags.showAll)
 			}
-			packageVersion, err := searcher.Client().Resolve(name, version)
+			packageVersion, err := s.Resolve(cmd.Context(), name, version)
 			if err != nil {
 				// This is not ideal. Search service should return valid response we
 				// can parse
 				return usererr.WithUserMessage(err, "No results found for %q\n", query)
 			}
+			if flags.output.structured() {
+				return flags.output.print(cmd.OutOrStdout(), devbox.SearchPackageInfo(packageVersion))
+			}
 			fmt.Fprintf(
 				cmd.OutOrStdout(),
 				"%s resolves to: %s@%s\n",
@@ -61,6 +82,11 @@ func searchCmd() *cobra.Command {
 		&flags.showAll, "show-all", false,
 		"show all available templates",
 	)
+	command.Flags().BoolVar(
+		&flags.reindex, "reindex", false,
+		"rebuild the local search index before searching (requires search.nixpkgs in devbox.json or DEVBOX_SEARCH_NIXPKGS)",
+	)
+	flags.output.register(command)
 
 	return command
 }
@@ -120,3 +146,18 @@ func printSearchResults(
 
 	return nil
 }
+
+// searchPackageInfos flattens search results into one entry per package
+// version. Unlike the text output, it is never truncated.
+func searchPackageInfos(results *searcher.SearchResults) []devbox.PackageInfo {
+	infos := []devbox.PackageInfo{}
+	for _, pkg := range results.Packages {
+		for i := range pkg.Versions {
+			if pkg.Versions[i].Version == "" {
+				continue
+			}
+			infos = append(infos, devbox.SearchPackageInfo(&pkg.Versions[i]))
+		}
+	}
+	return infos
+}
@@ -19,6 +19,7 @@ type secretsFlags struct {
 
 func (f *secretsFlags) envsec(cmd *cobra.Command) (*envsec.Envsec, error) {
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         f.config.path,
 		Environment: f.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -229,6 +230,7 @@ func secretsInitFunc(
 ) error {
 	ctx := cmd.Context()
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:    cmd.Context(),
 		Dir:    secretsFlags.config.path,
 		Stderr: cmd.ErrOrStderr(),
 	})
@@ -4,10 +4,21 @@
 package boxcli
 
 import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/services"
+	"go.jetpack.io/devbox/internal/ux"
 )
 
 type servicesCmdFlags struct {
@@ -20,12 +31,45 @@ type serviceUpFlags struct {
 	background          bool
 	processComposeFile  string
 	processComposeFlags []string
+	supervisor          string
+	ci                  bool
+	timeout             time.Duration
+}
+
+type serviceExportFlags struct {
+	format string
+	dir    string
+}
+
+type serviceSuperviseFlags struct {
+	port  int
+	files []string
 }
 
 type serviceStopFlags struct {
 	allProjects bool
 }
 
+type serviceWaitFlags struct {
+	timeout time.Duration
+}
+
+type servicePsFlags struct {
+	all    bool
+	output outputFlag
+}
+
+type servicePortsFlags struct {
+	output  outputFlag
+	release bool
+}
+
+type serviceLogsFlags struct {
+	follow bool
+	since  string
+	tail   int
+}
+
 func (flags *serviceUpFlags) register(cmd *cobra.Command) {
 	cmd.Flags().StringVar(
 		&flags.processComposeFile,
@@ -38,6 +82,29 @@ func (flags *serviceUpFlags) register(cmd *cobra.Command) {
 		&flags.background, "background", "b", false, "run service in background")
 	cmd.Flags().StringArrayVar(
 		&flags.processComposeFlags, "pcflags", []string{}, "pass flags directly to process compose")
+	cmd.Flags().StringVar(
+		&flags.supervisor, "supervisor", "",
+		"what runs the services: process-compose or native. "+
+			"Defaults to $DEVBOX_SERVICES_SUPERVISOR, or process-compose if it isn't set")
+	cmd.Flags().BoolVar(
+		&flags.ci, "ci", false,
+		"start services without a TUI, wait for them to be ready, run the command after --, "+
+			"and then stop them. Exits with the command's exit code")
+	cmd.Flags().DurationVar(
+		&flags.timeout, "timeout", 5*time.Minute,
+		"with --ci, how long to wait for services to be ready, or 0 to wait forever")
+}
+
+func (flags *serviceExportFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&flags.format, "format", "systemd", "format to export services in. Only systemd is supported")
+	cmd.Flags().StringVar(
+		&flags.dir, "dir", "", "directory to write the exported files to. Prints them to stdout if not set")
+}
+
+func (flags *serviceSuperviseFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&flags.port, "port", 0, "port to serve the API on")
+	cmd.Flags().StringArrayVar(&flags.files, "file", nil, "process-compose file to run")
+	_ = cmd.MarkFlagRequired("port")
 }
 
 func (flags *serviceStopFlags) register(cmd *cobra.Command) {
@@ -45,10 +112,33 @@ func (flags *serviceStopFlags) register(cmd *cobra.Command) {
 		&flags.allProjects, "all-projects", false, "stop all running services across all your projects.\nThis flag cannot be used simultaneously with the [services] argument")
 }
 
+func (flags *serviceLogsFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(
+		&flags.follow, "follow", "f", false, "keep streaming new log lines")
+	cmd.Flags().StringVar(
+		&flags.since, "since", "",
+		"only show lines logged since a timestamp (e.g. 2024-05-01T12:00:00Z) or "+
+			"relative time (e.g. 10m). Only applies to lines that start with a timestamp",
+	)
+	cmd.Flags().IntVarP(
+		&flags.tail, "tail", "n", -1, "number of lines to show from the end of each service's logs, or -1 for all")
+}
+
+func (flags *serviceWaitFlags) register(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(
+		&flags.timeout, "timeout", 2*time.Minute, "how long to wait for services to be ready, or 0 to wait forever")
+}
+
 func servicesCmd(persistentPreRunE ...cobraFunc) *cobra.Command {
 	flags := servicesCmdFlags{}
 	serviceUpFlags := serviceUpFlags{}
 	serviceStopFlags := serviceStopFlags{}
+	serviceLogsFlags := serviceLogsFlags{}
+	serviceWaitFlags := serviceWaitFlags{}
+	servicePortsFlags := servicePortsFlags{}
+	servicePsFlags := servicePsFlags{}
+	serviceSuperviseFlags := serviceSuperviseFlags{}
+	serviceExportFlags := serviceExportFlags{}
 	servicesCommand := &cobra.Command{
 		Use:   "services",
 		Short: "Interact with devbox services.",
@@ -104,14 +194,144 @@ func servicesCmd(persistentPreRunE ...cobraFunc) *cobra.Command {
 		},
 	}
 
+	logsCommand := &cobra.Command{
+		Use:   "logs [service]...",
+		Short: "Show the logs of running services. If no service is specified, shows the logs of all services",
+		Long: "Show the logs of services that are running in process-compose, " +
+			"including services started with `devbox services up --background`. " +
+			"When more than one service is shown, each line is prefixed with the " +
+			"name of its service.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serviceLogs(cmd, args, flags, serviceLogsFlags)
+		},
+	}
+
+	waitCommand := &cobra.Command{
+		Use:   "wait [service]...",
+		Short: "Wait until services are ready. If no service is specified, waits for all running services",
+		Long: "Wait until services are ready. A service with a readiness probe in its " +
+			"process-compose.yaml is ready once the probe passes, and a service " +
+			"without one is ready once it's running. Exits with an error if a " +
+			"service fails or isn't ready before the timeout.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return waitForServices(cmd, args, flags, serviceWaitFlags)
+		},
+	}
+
+	psCommand := &cobra.Command{
+		Use:   "ps",
+		Short: "Shows the status of the process manager and services for this project, or for all projects with --all",
+		Long: "Show the running process-compose instance for this project, with " +
+			"its PID, port, uptime, and the status of each service. With --all, " +
+			"show every project on this machine that has a running process " +
+			"manager. Entries for process managers that are no longer running " +
+			"are cleaned up.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listProcessManagers(cmd, flags, servicePsFlags)
+		},
+	}
+
+	portsCommand := &cobra.Command{
+		Use:   "ports",
+		Short: "List the ports assigned to services in this project",
+		Long: "List the ports assigned to services in this project. Devbox " +
+			"assigns each project its own ports for plugin services, such as " +
+			"PGPORT for PostgreSQL, so that services in different projects " +
+			"don't clash. Ports are assigned when the project is installed, " +
+			"such as by devbox install, devbox shell or devbox services up. " +
+			"A port stays assigned to the project until the plugin that uses " +
+			"it is removed, the project directory is deleted, or it's " +
+			"released with --release.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listServicePorts(cmd, flags, servicePortsFlags)
+		},
+	}
+
 	upCommand := &cobra.Command{
-		Use:   "up [service]...",
+		Use:   "up [service]... [-- <cmd> [args]...]",
 		Short: "Starts process manager with specified services. If no services are listed, starts the process manager with all the services in your project",
+		Long: "Starts process manager with specified services. If no services are " +
+			"listed, starts the process manager with all the services in your " +
+			"project.\n\n" +
+			"With --ci, the services start without a TUI. Once they're ready, the " +
+			"command after -- runs, and then the services are stopped. The logs of " +
+			"any service that crashed are printed, and devbox exits with the " +
+			"command's exit code.",
+		Example: "  devbox services up --ci postgresql -- go test ./...",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return startProcessManager(cmd, args, flags, serviceUpFlags)
 		},
 	}
 
+	exportCommand := &cobra.Command{
+		Use:   "export",
+		Short: "Export services as systemd user units",
+		Long: "Export the services in your project as systemd user units. Each unit " +
+			"runs its service's command in your devbox environment with `devbox run`, " +
+			"and keeps the service's dependencies and restart policy. A target unit " +
+			"starts all of the services. Use `devbox services install` to install " +
+			"and start the units.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exportServices(cmd, flags, serviceExportFlags)
+		},
+	}
+
+	installCommand := &cobra.Command{
+		Use:   "install",
+		Short: "Install services as systemd user units and start them",
+		Long: "Install the services in your project as systemd user units, so that " +
+			"systemd runs them and starts them when you log in. Units that were " +
+			"installed before are replaced. Use `devbox services uninstall` to " +
+			"stop and remove them.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return installServices(cmd, flags)
+		},
+	}
+
+	uninstallCommand := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Stop and remove the systemd user units installed for services",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return uninstallServices(cmd, flags)
+		},
+	}
+
+	watchCommand := &cobra.Command{
+		Use:   "watch",
+		Short: "Restart services when their files change",
+		Long: "Restart services when files that match the watch option of the " +
+			"service in devbox.json change. `devbox services up` already does " +
+			"this when it runs in the foreground, so use this command with " +
+			"`devbox services up --background`. It stops when the process " +
+			"manager stops.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := openServicesBox(cmd, flags)
+			if err != nil {
+				return err
+			}
+			return box.WatchServices(cmd.Context())
+		},
+	}
+
+	snapshotCommand := servicesSnapshotCmd(flags)
+
+	superviseCommand := &cobra.Command{
+		Use:    "supervise [service]...",
+		Short:  "Run services with the native supervisor",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return services.RunSupervisor(
+				cmd.Context(), cmd.OutOrStdout(), serviceSuperviseFlags.port,
+				serviceSuperviseFlags.files, args)
+		},
+	}
+
 	flags.envFlag.register(servicesCommand)
 	flags.config.registerPersistent(servicesCommand)
 	servicesCommand.PersistentFlags().BoolVar(
@@ -123,16 +343,38 @@ func servicesCmd(persistentPreRunE ...cobraFunc) *cobra.Command {
 	servicesCommand.Flag("run-in-current-shell").Hidden = true
 	serviceUpFlags.register(upCommand)
 	serviceStopFlags.register(stopCommand)
+	serviceSuperviseFlags.register(superviseCommand)
+	serviceExportFlags.register(exportCommand)
+	serviceLogsFlags.register(logsCommand)
+	serviceWaitFlags.register(waitCommand)
+	servicePortsFlags.output.register(portsCommand)
+	portsCommand.Flags().BoolVar(
+		&servicePortsFlags.release, "release", false,
+		"release the project's ports so that other projects can use them")
+	psCommand.Flags().BoolVar(
+		&servicePsFlags.all, "all", false, "show process managers for all projects on this machine")
+	servicePsFlags.output.register(psCommand)
+	servicesCommand.AddCommand(exportCommand)
+	servicesCommand.AddCommand(installCommand)
+	servicesCommand.AddCommand(logsCommand)
 	servicesCommand.AddCommand(lsCommand)
+	servicesCommand.AddCommand(portsCommand)
+	servicesCommand.AddCommand(psCommand)
 	servicesCommand.AddCommand(upCommand)
 	servicesCommand.AddCommand(restartCommand)
 	servicesCommand.AddCommand(startCommand)
+	servicesCommand.AddCommand(snapshotCommand)
 	servicesCommand.AddCommand(stopCommand)
+	servicesCommand.AddCommand(superviseCommand)
+	servicesCommand.AddCommand(uninstallCommand)
+	servicesCommand.AddCommand(waitCommand)
+	servicesCommand.AddCommand(watchCommand)
 	return servicesCommand
 }
 
 func listServices(cmd *cobra.Command, flags servicesCmdFlags) error {
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
@@ -144,12 +386,306 @@ func listServices(cmd *cobra.Command, flags servicesCmdFlags) error {
 	return box.ListServices(cmd.Context(), flags.runInCurrentShell)
 }
 
+func serviceLogs(
+	cmd *cobra.Command,
+	services []string,
+	servicesFlags servicesCmdFlags,
+	flags serviceLogsFlags,
+) error {
+	since, err := parseSince(flags.since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	box, err := openServicesBox(cmd, servicesFlags)
+	if err != nil {
+		return err
+	}
+
+	return box.ServiceLogs(cmd.Context(), cmd.OutOrStdout(), devopt.ServiceLogsOpts{
+		Follow: flags.follow,
+		Since:  since,
+		Tail:   flags.tail,
+	}, services...)
+}
+
+func servicesSnapshotCmd(flags servicesCmdFlags) *cobra.Command {
+	listOutput := outputFlag{}
+	snapshotCommand := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and restore the data of plugin services, such as databases",
+		Long: "Save and restore the data that plugins with services, such as " +
+			"postgresql and mysql, keep in .devbox/virtenv. Services that are " +
+			"running are stopped while a snapshot is saved or restored, and " +
+			"started again afterwards.",
+	}
+
+	saveCommand := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Save the data of plugin services in a snapshot, replacing any snapshot with the same name",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := openServicesBox(cmd, flags)
+			if err != nil {
+				return err
+			}
+			snapshot, err := box.SaveSnapshot(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Saved snapshot %s of %s.\n",
+				snapshot.Name, strings.Join(snapshot.Plugins, ", "))
+			return nil
+		},
+	}
+
+	restoreCommand := &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Replace the data of plugin services with the data in a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := openServicesBox(cmd, flags)
+			if err != nil {
+				return err
+			}
+			snapshot, err := box.RestoreSnapshot(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Restored snapshot %s of %s.\n",
+				snapshot.Name, strings.Join(snapshot.Plugins, ", "))
+			return nil
+		},
+	}
+
+	listCommand := &cobra.Command{
+		Use:   "list",
+		Short: "List snapshots",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := listOutput.validate(); err != nil {
+				return err
+			}
+			box, err := openServicesBox(cmd, flags)
+			if err != nil {
+				return err
+			}
+			snapshots, err := box.ListSnapshots()
+			if err != nil {
+				return err
+			}
+			if listOutput.structured() {
+				return listOutput.print(cmd.OutOrStdout(), snapshots)
+			}
+			if len(snapshots) == 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No snapshots found. Run `devbox services snapshot save <name>` to create one.")
+				return nil
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 3, 2, 4, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tCREATED\tPLUGINS")
+			for _, s := range snapshots {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n",
+					s.Name, s.CreatedAt.Local().Format(time.DateTime), strings.Join(s.Plugins, ", "))
+			}
+			return tw.Flush()
+		},
+	}
+	listOutput.register(listCommand)
+
+	snapshotCommand.AddCommand(saveCommand)
+	snapshotCommand.AddCommand(restoreCommand)
+	snapshotCommand.AddCommand(listCommand)
+	return snapshotCommand
+}
+
+func openServicesBox(cmd *cobra.Command, flags servicesCmdFlags) (*devbox.Devbox, error) {
+	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	return box, errors.WithStack(err)
+}
+
+func exportServices(cmd *cobra.Command, servicesFlags servicesCmdFlags, flags serviceExportFlags) error {
+	box, err := openServicesBox(cmd, servicesFlags)
+	if err != nil {
+		return err
+	}
+	return box.ExportServices(
+		cmd.Context(), cmd.OutOrStdout(), servicesFlags.runInCurrentShell,
+		devopt.ServiceExportOpts{Format: flags.format, Dir: flags.dir})
+}
+
+func installServices(cmd *cobra.Command, flags servicesCmdFlags) error {
+	box, err := openServicesBox(cmd, flags)
+	if err != nil {
+		return err
+	}
+	return box.InstallServices(cmd.Context(), flags.runInCurrentShell)
+}
+
+func uninstallServices(cmd *cobra.Command, flags servicesCmdFlags) error {
+	box, err := openServicesBox(cmd, flags)
+	if err != nil {
+		return err
+	}
+	return box.UninstallServices(cmd.Context())
+}
+
+func listProcessManagers(
+	cmd *cobra.Command,
+	servicesFlags servicesCmdFlags,
+	flags servicePsFlags,
+) error {
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+
+	var managers []services.ProcessManager
+	if flags.all {
+		var err error
+		managers, err = devbox.AllProcessManagers(cmd.Context())
+		if err != nil {
+			return err
+		}
+	} else {
+		box, err := openServicesBox(cmd, servicesFlags)
+		if err != nil {
+			return err
+		}
+		pm, err := box.ProcessManager(cmd.Context())
+		if err != nil {
+			return err
+		}
+		managers = []services.ProcessManager{}
+		if pm != nil {
+			managers = append(managers, *pm)
+		}
+	}
+
+	if flags.output.structured() {
+		return flags.output.print(cmd.OutOrStdout(), managers)
+	}
+	if len(managers) == 0 {
+		fmt.Fprintln(cmd.ErrOrStderr(), "No process managers are running. Run `devbox services up` to start one.")
+		return nil
+	}
+	printProcessManagers(cmd.OutOrStdout(), managers, time.Now())
+	return nil
+}
+
+func printProcessManagers(w io.Writer, managers []services.ProcessManager, now time.Time) {
+	for i, pm := range managers {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		uptime := "unknown"
+		if d := pm.Uptime(now); d > 0 {
+			uptime = d.Truncate(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s (pid %d, port %d, up %s)\n", pm.ProjectDir, pm.Pid, pm.Port, uptime)
+		if pm.Error != "" {
+			fmt.Fprintf(w, "  error listing services: %s\n", pm.Error)
+			continue
+		}
+		tw := tabwriter.NewWriter(w, 3, 2, 4, ' ', 0)
+		fmt.Fprintln(tw, "  NAME\tSTATUS\tHEALTH\tEXIT CODE")
+		for _, svc := range pm.Services {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%d\n", svc.Name, svc.Status, svc.Health, svc.ExitCode)
+		}
+		tw.Flush()
+	}
+}
+
+func listServicePorts(
+	cmd *cobra.Command,
+	servicesFlags servicesCmdFlags,
+	flags servicePortsFlags,
+) error {
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+	box, err := openServicesBox(cmd, servicesFlags)
+	if err != nil {
+		return err
+	}
+
+	if flags.release {
+		released, err := box.ReleaseServicePorts()
+		if err != nil {
+			return err
+		}
+		if len(released) == 0 {
+			fmt.Fprintln(cmd.ErrOrStderr(), "No ports are assigned to this project.")
+			return nil
+		}
+		ux.Fsuccess(cmd.ErrOrStderr(), "Released ports: %s\n", strings.Join(released, ", "))
+		return nil
+	}
+
+	ports := box.ServicePorts()
+	if flags.output.structured() {
+		if ports == nil {
+			ports = map[string]int{}
+		}
+		return flags.output.print(cmd.OutOrStdout(), ports)
+	}
+	if len(ports) == 0 {
+		fmt.Fprintln(cmd.ErrOrStderr(), "No ports are assigned to this project.")
+		return nil
+	}
+
+	names := lo.Keys(ports)
+	slices.Sort(names)
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 3, 2, 8, ' ', tabwriter.TabIndent)
+	fmt.Fprintln(tw, "NAME\tPORT")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%d\n", name, ports[name])
+	}
+	return errors.WithStack(tw.Flush())
+}
+
+func waitForServices(
+	cmd *cobra.Command,
+	services []string,
+	servicesFlags servicesCmdFlags,
+	flags serviceWaitFlags,
+) error {
+	box, err := openServicesBox(cmd, servicesFlags)
+	if err != nil {
+		return err
+	}
+
+	return box.WaitForServices(cmd.Context(), flags.timeout, services...)
+}
+
+// parseSince parses the --since flag, which is either a timestamp or a
+// duration before now.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, usererr.New(
+		"Invalid --since value %q. Use a timestamp such as 2024-05-01T12:00:00Z or a duration such as 10m.", since)
+}
+
 func startServices(cmd *cobra.Command, services []string, flags servicesCmdFlags) error {
 	env, err := flags.Env(flags.config.path)
 	if err != nil {
 		return err
 	}
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Env:         env,
@@ -173,6 +709,7 @@ func stopServices(
 		return err
 	}
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         servicesFlags.config.path,
 		Environment: servicesFlags.config.environment,
 		Env:         env,
@@ -199,6 +736,7 @@ func restartServices(
 		return err
 	}
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Env:         env,
@@ -223,6 +761,7 @@ func startProcessManager(
 	}
 
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:                      cmd.Context(),
 		Dir:                      servicesFlags.config.path,
 		Env:                      env,
 		Environment:              servicesFlags.config.environment,
@@ -233,13 +772,32 @@ func startProcessManager(
 		return errors.WithStack(err)
 	}
 
-	return box.StartProcessManager(
+	pcOpts := devopt.ProcessComposeOpts{
+		Background: flags.background,
+		ExtraFlags: flags.processComposeFlags,
+		Supervisor: flags.supervisor,
+	}
+	svcs, command := args, []string(nil)
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		svcs, command = args[:dash], args[dash:]
+	}
+	if !flags.ci {
+		if len(command) > 0 {
+			return usererr.New("A command after -- can only be used with --ci")
+		}
+		return box.StartProcessManager(cmd.Context(), servicesFlags.runInCurrentShell, svcs, pcOpts)
+	}
+	if flags.background {
+		return usererr.New("--ci can't be used with --background")
+	}
+	return box.RunServicesCI(
 		cmd.Context(),
 		servicesFlags.runInCurrentShell,
-		args,
-		devopt.ProcessComposeOpts{
-			Background: flags.background,
-			ExtraFlags: flags.processComposeFlags,
+		svcs,
+		devopt.ServicesCIOpts{
+			ProcessComposeOpts: pcOpts,
+			Command:            command,
+			Timeout:            flags.timeout,
 		},
 	)
 }
@@ -66,6 +66,7 @@ func runShellCmd(cmd *cobra.Command, flags shellCmdFlags) error {
 	}
 	// Check the directory exists.
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Env:         env,
 		Environment: flags.config.environment,
@@ -100,6 +100,7 @@ func shellEnvFunc(
 		ctx = ux.HideMessage(ctx, devbox.StateOutOfDateMessage)
 	}
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
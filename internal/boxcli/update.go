@@ -17,6 +17,8 @@ type updateCmdFlags struct {
 	config      configFlags
 	sync        bool
 	allProjects bool
+	dryRun      bool
+	output      outputFlag
 }
 
 func updateCmd() *cobra.Command {
@@ -49,6 +51,13 @@ func updateCmd() *cobra.Command {
 		false,
 		"update all projects in the working directory, recursively.",
 	)
+	command.Flags().BoolVar(
+		&flags.dryRun,
+		"dry-run",
+		false,
+		"print how devbox.lock would change without modifying it or installing anything.",
+	)
+	flags.output.register(command)
 	return command
 }
 
@@ -57,6 +66,17 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 		return usererr.New("cannot specify both a package and --sync")
 	}
 
+	if flags.dryRun && (flags.sync || flags.allProjects) {
+		return usererr.New("--dry-run cannot be used with --sync-lock or --all-projects")
+	}
+
+	if err := flags.output.validate(); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("output") && !flags.dryRun {
+		return usererr.New("--output requires --dry-run")
+	}
+
 	if flags.allProjects {
 		return updateAllProjects(cmd, args)
 	}
@@ -66,14 +86,26 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 	}
 
 	box, err := devbox.Open(&devopt.Opts{
+		Ctx:         cmd.Context(),
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
+		// Updating every package also updates included plugins to their
+		// latest commits, and locks their new content.
+		UpdateAllPlugins: len(args) == 0 && !flags.dryRun,
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
+	if flags.dryRun {
+		diff, err := box.UpdateDryRun(cmd.Context(), devopt.UpdateOpts{Pkgs: args})
+		if err != nil {
+			return err
+		}
+		return printLockDiff(cmd.OutOrStdout(), diff, &flags.output)
+	}
+
 	return box.Update(cmd.Context(), devopt.UpdateOpts{
 		Pkgs: args,
 	})
@@ -81,7 +113,9 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 
 func updateAllProjects(cmd *cobra.Command, args []string) error {
 	boxes, err := multi.Open(&devopt.Opts{
-		Stderr: cmd.ErrOrStderr(),
+		Ctx:              cmd.Context(),
+		Stderr:           cmd.ErrOrStderr(),
+		UpdateAllPlugins: len(args) == 0,
 	})
 	if err != nil {
 		return errors.WithStack(err)
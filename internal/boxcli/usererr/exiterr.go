@@ -27,3 +27,20 @@ func NewExecError(source error) error {
 	}
 	return &ExitError{ExitError: exitErr}
 }
+
+// ExitCodeError is a user error that makes devbox exit with a specific exit
+// code so that scripts can tell failures apart.
+type ExitCodeError struct {
+	error
+	Code int
+}
+
+// NewExitCode creates a user error with the given message that makes devbox
+// exit with code.
+func NewExitCode(code int, msg string, args ...any) error {
+	return &ExitCodeError{error: New(msg, args...), Code: code}
+}
+
+func (e *ExitCodeError) ExitCode() int { return e.Code }
+
+func (e *ExitCodeError) Unwrap() error { return e.error }
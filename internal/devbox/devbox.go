@@ -32,6 +32,7 @@ import (
 	"go.jetpack.io/devbox/internal/devbox/envpath"
 	"go.jetpack.io/devbox/internal/devbox/generate"
 	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
 	"go.jetpack.io/devbox/internal/envir"
@@ -63,8 +64,13 @@ type Devbox struct {
 	nix                      nix.Nixer
 	projectDir               string
 	pluginManager            *plugin.Manager
+	searcher                 searcher.Searcher
 	customProcessComposeFile string
 
+	// withinPluginHooks is true while an operation that runs plugin hooks
+	// when it finishes is in progress. See withPluginHooks.
+	withinPluginHooks bool
+
 	// This is needed because of the --quiet flag.
 	stderr io.Writer
 }
@@ -86,18 +92,22 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		return nil, errors.WithStack(err)
 	}
 
-	environment, err := validateEnvironment(opts.Environment)
+	environment, err := validateEnvironment(opts.Environment, cfg)
 	if err != nil {
 		return nil, err
 	}
+	cfg.SetProfile(environment)
 
 	box := &Devbox{
-		cfg:                      cfg,
-		env:                      opts.Env,
-		environment:              environment,
-		nix:                      &nix.Nix{},
-		projectDir:               projectDir,
-		pluginManager:            plugin.NewManager(),
+		cfg:         cfg,
+		env:         opts.Env,
+		environment: environment,
+		nix:         &nix.Nix{},
+		projectDir:  projectDir,
+		pluginManager: plugin.NewManager(plugin.WithRefetch(
+			plugin.NewRefetch(opts.UpdateAllPlugins, opts.UpdatePlugins...),
+		)),
+		searcher:                 newSearcher(projectDir, cfg.Root.Search, opts.Stderr),
 		stderr:                   opts.Stderr,
 		customProcessComposeFile: opts.CustomProcessComposeFile,
 	}
@@ -107,7 +117,11 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		return nil, err
 	}
 
-	if err := cfg.LoadRecursive(lock); err != nil {
+	ctx := opts.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := cfg.LoadRecursive(ctx, lock, box.pluginManager.Refetch()); err != nil {
 		return nil, err
 	}
 
@@ -233,6 +247,10 @@ func (d *Devbox) RunScript(ctx context.Context, envOpts devopt.EnvOptions, cmdNa
 	ctx, task := trace.NewTask(ctx, "devboxRun")
 	defer task.End()
 
+	if err := d.checkPluginScript(cmdName); err != nil {
+		return err
+	}
+
 	if err := shellgen.WriteScriptsToFiles(d); err != nil {
 		return err
 	}
@@ -290,13 +308,38 @@ func (d *Devbox) RunScript(ctx context.Context, envOpts devopt.EnvOptions, cmdNa
 	return nix.RunScript(d.projectDir, strings.Join(cmdWithArgs, " "), env)
 }
 
+// checkPluginScript returns an error if cmdName looks like <plugin>:<script>
+// for an included plugin that doesn't have the script. Otherwise it'd be run
+// as a command, which fails with a confusing error.
+func (d *Devbox) checkPluginScript(cmdName string) error {
+	pluginName, script, ok := strings.Cut(cmdName, ":")
+	if !ok {
+		return nil
+	}
+	if _, ok := d.cfg.Scripts()[cmdName]; ok {
+		return nil
+	}
+	for _, cfg := range d.cfg.IncludedPluginConfigs() {
+		if cfg.Name == pluginName {
+			return usererr.New(
+				"Plugin %s doesn't have a script named %s. Run `devbox plugin show %s` to see its scripts.",
+				pluginName, script, pluginName,
+			)
+		}
+	}
+	return nil
+}
+
 // Install ensures that all the packages in the config are installed
-// but does not run init hooks. It is used to power devbox install cli command.
+// but does not run init hooks. It runs the on_install hooks of plugins. It is
+// used to power devbox install cli command.
 func (d *Devbox) Install(ctx context.Context) error {
 	ctx, task := trace.NewTask(ctx, "devboxInstall")
 	defer task.End()
 
-	return d.ensureStateIsUpToDate(ctx, ensure)
+	return d.withPluginHooks(ctx, func() error {
+		return d.ensureStateIsUpToDate(ctx, ensure)
+	}, plugin.OnInstall)
 }
 
 func (d *Devbox) ListScripts() []string {
@@ -374,34 +417,23 @@ func (d *Devbox) Info(ctx context.Context, pkg string, markdown bool) (string, e
 	ctx, task := trace.NewTask(ctx, "devboxInfo")
 	defer task.End()
 
-	name, version, isVersioned := searcher.ParseVersionedPackage(pkg)
-	if !isVersioned {
-		name = pkg
-		version = "latest"
-	}
-
-	packageVersion, err := searcher.Client().Resolve(name, version)
+	pkgInfo, err := d.PackageInfo(ctx, pkg)
 	if err != nil {
-		if !errors.Is(err, searcher.ErrNotFound) {
-			return "", usererr.WithUserMessage(err, "Package %q not found\n", pkg)
-		}
-
-		packageVersion = nil
-		// fallthrough to below
-	}
-
-	if packageVersion == nil {
-		return "", usererr.WithUserMessage(err, "Package %q not found\n", pkg)
+		return "", err
 	}
 
 	// we should only have one result
+	name, _, _ := searcher.ParseVersionedPackage(pkgInfo.Name)
 	info := fmt.Sprintf(
 		"%s%s %s\n%s\n",
 		lo.Ternary(markdown, "## ", ""),
-		packageVersion.Name,
-		packageVersion.Version,
-		packageVersion.Summary,
+		name,
+		pkgInfo.Version,
+		pkgInfo.Summary,
 	)
+	if pkgInfo.Source != "" {
+		info += fmt.Sprintf("Included from: %s\n", pkgInfo.Source)
+	}
 	readme, err := plugin.Readme(
 		ctx,
 		devpkg.PackageFromStringWithDefaults(pkg, d.lockfile),
@@ -414,6 +446,50 @@ func (d *Devbox) Info(ctx context.Context, pkg string, markdown bool) (string, e
 	return info + readme, nil
 }
 
+// PackageInfo returns information about a package from the search index. If
+// devbox.json has the same package and version, the information from
+// devbox.json and devbox.lock is included too.
+func (d *Devbox) PackageInfo(ctx context.Context, pkg string) (*PackageInfo, error) {
+	name, version, isVersioned := searcher.ParseVersionedPackage(pkg)
+	if !isVersioned {
+		name = pkg
+		version = "latest"
+	}
+
+	packageVersion, err := d.searcher.Resolve(ctx, name, version)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Package %q not found\n", pkg)
+	}
+
+	versioned := name + "@" + version
+	cfgPackages := d.cfg.Packages(true /*includeRemovedTriggerPackages*/)
+	for i, p := range devpkg.PackagesFromConfig(cfgPackages, d.lockfile) {
+		if p.Raw != versioned {
+			continue
+		}
+		info, err := d.packageInfo(p, cfgPackages[i])
+		if err != nil {
+			return nil, err
+		}
+		if info.Version == "" {
+			info.Version = packageVersion.Version
+		}
+		info.Summary = packageVersion.Summary
+		info.Source = d.cfg.PackageSources()[name]
+		return info, nil
+	}
+
+	info := SearchPackageInfo(packageVersion)
+	info.Name = versioned
+	info.Source = d.cfg.PackageSources()[name]
+	info.Plugin, err = plugin.BuiltinPluginName(
+		devpkg.PackageFromStringWithDefaults(versioned, d.lockfile), d.projectDir)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
 // GenerateDevcontainer generates devcontainer.json and Dockerfile for vscode run-in-container
 // and GitHub Codespaces
 func (d *Devbox) GenerateDevcontainer(ctx context.Context, generateOpts devopt.GenerateOpts) error {
@@ -553,7 +629,27 @@ func (d *Devbox) Services() (services.Services, error) {
 
 	userSvcs := services.FromUserProcessCompose(d.projectDir, d.customProcessComposeFile)
 
-	svcSet := lo.Assign(pluginSvcs, userSvcs)
+	cfgSvcs := d.cfg.Services()
+	for name, svc := range cfgSvcs {
+		for _, dep := range svc.DependsOn {
+			_, inCfg := cfgSvcs[dep]
+			_, inPlugin := pluginSvcs[dep]
+			_, inUser := userSvcs[dep]
+			if !inCfg && !inPlugin && !inUser {
+				return nil, usererr.New("Service %s in devbox.json depends on unknown service %s", name, dep)
+			}
+		}
+	}
+	configSvcs, err := services.FromConfig(d.projectDir, cfgSvcs, func(name string) bool {
+		return pluginSvcs[name].HasReadinessProbe || userSvcs[name].HasReadinessProbe
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Services in devbox.json replace plugin services with the same name, and
+	// an explicit process-compose.yaml replaces both.
+	svcSet := lo.Assign(pluginSvcs, configSvcs, userSvcs)
 	keys := make([]string, 0, len(svcSet))
 	for k := range svcSet {
 		keys = append(keys, k)
@@ -1122,15 +1218,43 @@ func (d *Devbox) RunXPaths(ctx context.Context) (string, error) {
 	return runxBinPath, nil
 }
 
-func validateEnvironment(environment string) (string, error) {
+// builtInEnvironments are always valid environments, even if devbox.json
+// doesn't define a profile for them. They are the environments supported by
+// secrets.
+var builtInEnvironments = []string{"dev", "prod", "preview"}
+
+func validateEnvironment(environment string, cfg *devconfig.Config) (string, error) {
 	if environment == "" {
 		return "dev", nil
 	}
-	if environment == "dev" || environment == "prod" || environment == "preview" {
+	if _, ok := cfg.Root.Profile(environment); ok {
+		return environment, nil
+	}
+	if slices.Contains(builtInEnvironments, environment) {
 		return environment, nil
 	}
+	valid := append(slices.Clone(builtInEnvironments), lo.Keys(cfg.Root.Profiles)...)
+	slices.Sort(valid)
 	return "", usererr.New(
-		"invalid environment %q. Environment must be one of dev, prod, or preview.",
+		"invalid environment %q. Environment must be one of %s, or a profile defined in devbox.json.",
 		environment,
+		strings.Join(lo.Uniq(valid), ", "),
 	)
 }
+
+// ActiveProfile returns the devbox.json profile selected with --environment,
+// or nil if devbox.json doesn't define it.
+func (d *Devbox) ActiveProfile() *lock.Profile {
+	name, profile := d.cfg.Profile()
+	if profile == nil {
+		return nil
+	}
+	base := lo.Map(d.cfg.BasePackages(true /*includeRemovedTriggerPackages*/),
+		func(p configfile.Package, _ int) string { return p.VersionedName() })
+	all := d.AllPackageNamesIncludingRemovedTriggerPackages()
+	return &lock.Profile{
+		Name:            name,
+		Packages:        lo.Without(all, base...),
+		RemovedPackages: lo.Without(base, all...),
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/stretchr/testify/assert"
@@ -132,3 +133,15 @@ func devboxForTesting(t *testing.T) *Devbox {
 
 	return d
 }
+
+// devboxWithHelloPlugin returns a project where the hello package activates
+// a built-in plugin with the given plugin.json, instead of the plugins that
+// are embedded in devbox. The project doesn't have the package yet.
+func devboxWithHelloPlugin(t *testing.T, pluginJSON string) *Devbox {
+	t.Helper()
+	t.Setenv("__DEVBOX_NIX_SYSTEM", "x86_64-linux")
+	d := devboxForTesting(t)
+	d.nix = &testNix{}
+	d.cfg.SetBuiltinPlugins(fstest.MapFS{"hello.json": {Data: []byte(pluginJSON)}})
+	return d
+}
@@ -1,7 +1,9 @@
 package devopt
 
 import (
+	"context"
 	"io"
+	"time"
 )
 
 // Naming Convention:
@@ -9,17 +11,62 @@ import (
 // - omit suffix Opts for other structs that are composed into an Opts struct
 
 type Opts struct {
+	// Ctx is the context of the command that opens the project. Plugins
+	// that are downloaded while the project is opened stop downloading when
+	// it's done. If it's nil, context.Background() is used.
+	Ctx context.Context
+
 	Dir                      string
 	Env                      map[string]string
 	Environment              string
 	IgnoreWarnings           bool
 	CustomProcessComposeFile string
 	Stderr                   io.Writer
+
+	// UpdatePlugins has the include references of plugins to download
+	// again and lock at their latest revision and content, instead of
+	// loading them from the cache and checking them against devbox.lock.
+	// UpdateAllPlugins does the same for every plugin that devbox downloads.
+	UpdatePlugins    []string
+	UpdateAllPlugins bool
 }
 
 type ProcessComposeOpts struct {
 	ExtraFlags []string
 	Background bool
+
+	// Supervisor is "process-compose" or "native". If it's empty, it's
+	// read from DEVBOX_SERVICES_SUPERVISOR and defaults to process-compose.
+	Supervisor string
+}
+
+type ServicesCIOpts struct {
+	ProcessComposeOpts
+
+	// Command runs once the services are ready. Its exit code is the exit
+	// code of `devbox services up --ci`.
+	Command []string
+
+	// Timeout is how long to wait for the services to be ready, or 0 to
+	// wait forever.
+	Timeout time.Duration
+}
+
+type ServiceExportOpts struct {
+	// Format is the format to export services in. Only "systemd" is
+	// supported.
+	Format string
+
+	// Dir is the directory to write the exported files to. If it's empty,
+	// they're written to stdout.
+	Dir string
+}
+
+type ServiceLogsOpts struct {
+	Follow bool
+	Since  time.Time
+	// Tail is the number of lines to print per service, or -1 for all lines.
+	Tail int
 }
 
 type GenerateOpts struct {
@@ -60,6 +107,12 @@ type UpdateOpts struct {
 	IgnoreMissingPackages bool
 }
 
+type LockOpts struct {
+	// Systems are the nix systems to lock outputs for. If empty, the systems
+	// in devbox.json are locked, or only the current system if it has none.
+	Systems []string
+}
+
 type EnvExportsOpts struct {
 	DontRecomputeEnvironment bool
 	EnvOptions               EnvOptions
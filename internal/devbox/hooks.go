@@ -0,0 +1,155 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/plugin"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// withPluginHooks runs op, which changes the project, and then runs the
+// on_remove hooks of plugins that op removed, the on_add hooks of plugins
+// that it added, and the hooks for events of the plugins that the project
+// still includes. on_update only runs for plugins that were already
+// included.
+//
+// Operations can call each other, such as Add calling Remove to replace a
+// package, so hooks only run once the outermost operation finishes. That way
+// replacing a package with another version of it doesn't remove and add its
+// plugin.
+func (d *Devbox) withPluginHooks(
+	ctx context.Context,
+	op func() error,
+	events ...plugin.HookEvent,
+) error {
+	if d.withinPluginHooks {
+		return op()
+	}
+	d.withinPluginHooks = true
+	defer func() { d.withinPluginHooks = false }()
+
+	before := d.pluginsByName()
+	if err := op(); err != nil {
+		return err
+	}
+	// Operations only change the root config, so load the plugins that it
+	// includes again to see which ones were added or removed.
+	if err := d.cfg.LoadRecursive(ctx, d.lockfile, d.pluginManager.Refetch()); err != nil {
+		return err
+	}
+	after := d.pluginsByName()
+
+	// Sort by name so hooks run in the same order every time.
+	beforeNames, afterNames := lo.Keys(before), lo.Keys(after)
+	slices.Sort(beforeNames)
+	slices.Sort(afterNames)
+
+	hooks := []pluginHook{}
+	for _, name := range beforeNames {
+		if _, ok := after[name]; !ok {
+			hooks = append(hooks, pluginHook{plugin.OnRemove, before[name]})
+		}
+	}
+	for _, name := range afterNames {
+		if _, ok := before[name]; !ok {
+			hooks = append(hooks, pluginHook{plugin.OnAdd, after[name]})
+		}
+	}
+	for _, event := range events {
+		for _, name := range afterNames {
+			if _, ok := before[name]; ok || event != plugin.OnUpdate {
+				hooks = append(hooks, pluginHook{event, after[name]})
+			}
+		}
+	}
+	return d.runPluginHooks(ctx, hooks)
+}
+
+// pluginHook is a hook of a plugin that should run.
+type pluginHook struct {
+	event plugin.HookEvent
+	cfg   *plugin.Config
+}
+
+// runPluginHooks runs hooks in order. Plugins that don't have a hook for the
+// event are skipped, and if there aren't any hooks to run, the environment
+// isn't computed.
+func (d *Devbox) runPluginHooks(ctx context.Context, hooks []pluginHook) error {
+	hooks = lo.Filter(hooks, func(h pluginHook, _ int) bool { return h.cfg.Hook(h.event) != nil })
+	if len(hooks) == 0 {
+		return nil
+	}
+	env, err := d.pluginHookEnv(ctx)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if err := d.runPluginHook(ctx, env, h.event, h.cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pluginsByName returns the plugins that the project includes by their
+// canonical name, which doesn't change when a package with a built-in plugin
+// changes versions.
+func (d *Devbox) pluginsByName() map[string]*plugin.Config {
+	return lo.SliceToMap(d.cfg.IncludedPluginConfigs(), func(cfg *plugin.Config) (string, *plugin.Config) {
+		return cfg.Source.CanonicalName(), cfg
+	})
+}
+
+// pluginHookEnv returns the devbox environment that plugin hooks run in.
+func (d *Devbox) pluginHookEnv(ctx context.Context) (map[string]string, error) {
+	if d.IsEnvEnabled() {
+		// Like RunScript, use the environment of the current devbox shell.
+		return envir.PairsToMap(os.Environ()), nil
+	}
+	return d.computeEnv(ctx, true /*usePrintDevEnvCache*/, devopt.EnvOptions{})
+}
+
+// runPluginHook runs the hook for event of a plugin in env. Plugins that were
+// removed aren't in env anymore, so their own env is added to it, without
+// overriding variables that the project sets.
+func (d *Devbox) runPluginHook(
+	ctx context.Context,
+	env map[string]string,
+	event plugin.HookEvent,
+	cfg *plugin.Config,
+) error {
+	name := cfg.Source.CanonicalName()
+	ux.Finfo(d.stderr, "Running %s hook of plugin %s\n", event, name)
+
+	hookEnv := maps.Clone(env)
+	for k, v := range cfg.Env {
+		if _, ok := hookEnv[k]; !ok {
+			hookEnv[k] = v
+		}
+	}
+	sh := cmdutil.GetPathOrDefault("sh", "/bin/sh")
+	cmd := exec.CommandContext(ctx, sh, "-e", "-c", cfg.Hook(event).String())
+	cmd.Dir = d.projectDir
+	cmd.Env = envir.MapToPairs(hookEnv)
+	cmd.Stdout = d.stderr
+	cmd.Stderr = d.stderr
+	if err := cmd.Run(); err != nil {
+		return usererr.WithUserMessage(
+			usererr.NewExecError(err),
+			"The %s hook of plugin %s failed", event, name,
+		)
+	}
+	return nil
+}
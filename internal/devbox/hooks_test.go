@@ -0,0 +1,91 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/plugin"
+)
+
+const testHooksPluginJSON = `{
+  "name": "hello",
+  "version": "0.0.1",
+  "env": {
+    "HOOK_LOG": "{{ .DevboxProjectDir }}/hooks.log"
+  },
+  "hooks": {
+    "on_install": "echo install >> $HOOK_LOG",
+    "on_add": "echo add >> $HOOK_LOG",
+    "on_remove": ["echo remove >> $HOOK_LOG"],
+    "on_update": "echo update >> $HOOK_LOG"
+  }
+}`
+
+// readHookLog returns the hooks that ran since it was last called.
+func readHookLog(t *testing.T, d *Devbox) string {
+	t.Helper()
+	path := filepath.Join(d.projectDir, "hooks.log")
+	got, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(path))
+	return string(got)
+}
+
+func TestPluginHooks(t *testing.T) {
+	d := devboxWithHelloPlugin(t, testHooksPluginJSON)
+	ctx := context.Background()
+
+	// Adding a package only changes devbox.json, and the plugins that it
+	// activates are loaded afterwards. Devbox.Add does the same, but it also
+	// needs nix to look up and install the package.
+	addHello := func() error {
+		d.cfg.PackageMutator().Add("hello@latest")
+		return d.saveCfg()
+	}
+	require.NoError(t, d.withPluginHooks(ctx, addHello, plugin.OnInstall))
+	assert.Equal(t, "add\ninstall\n", readHookLog(t, d))
+
+	// on_update only runs for plugins that were already included.
+	require.NoError(t, d.withPluginHooks(ctx, func() error { return nil }, plugin.OnUpdate))
+	assert.Equal(t, "update\n", readHookLog(t, d))
+
+	// Nested operations only run hooks once the outermost one finishes, so
+	// replacing a package doesn't remove and add its plugin.
+	err := d.withPluginHooks(ctx, func() error {
+		if err := d.Remove(ctx, "hello"); err != nil {
+			return err
+		}
+		return d.withPluginHooks(ctx, addHello)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", readHookLog(t, d))
+
+	// Removed plugins still have their env when on_remove runs.
+	require.NoError(t, d.Remove(ctx, "hello"))
+	assert.Equal(t, "remove\n", readHookLog(t, d))
+	assert.Empty(t, d.cfg.IncludedPluginConfigs())
+}
+
+func TestPluginHooksFailure(t *testing.T) {
+	d := devboxWithHelloPlugin(t,
+		`{"name": "hello", "version": "1", "hooks": {"on_remove": ["false", "echo unreachable"]}}`,
+	)
+	ctx := context.Background()
+	d.cfg.PackageMutator().Add("hello@latest")
+	require.NoError(t, d.cfg.LoadRecursive(ctx, d.lockfile, nil))
+
+	err := d.Remove(ctx, "hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "on_remove hook of plugin hello failed")
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// DefaultLockSystems are the systems that `devbox lock --all-systems` locks
+// unless others are given with --systems or the systems field of devbox.json.
+var DefaultLockSystems = []string{
+	"x86_64-linux",
+	"aarch64-linux",
+	"aarch64-darwin",
+	"x86_64-darwin",
+}
+
+// Lock resolves every package in devbox.json and records its outputs for
+// each of opts.Systems in devbox.lock, so that the lockfile is complete no
+// matter which platform added the package. If opts.Systems is empty, the
+// systems field of devbox.json is used, or else the current system. Packages
+// restricted with platforms or excluded_platforms are only locked for the
+// systems they're enabled on. Systems that a package can't be evaluated for
+// are skipped with a warning, as are flakes that aren't pinned to a commit.
+func (d *Devbox) Lock(ctx context.Context, opts devopt.LockOpts) error {
+	systems := opts.Systems
+	if len(systems) == 0 {
+		systems = d.cfg.Root.Systems
+	}
+	if len(systems) == 0 {
+		systems = []string{nix.System()}
+	}
+	if err := nix.EnsureValidPlatform(systems...); err != nil {
+		return err
+	}
+
+	cfgPackages := d.cfg.Packages(false /*includeRemovedTriggerPackages*/)
+	for i, pkg := range devpkg.PackagesFromConfig(cfgPackages, d.lockfile) {
+		// Runx packages don't have nix outputs, and legacy packages need
+		// `devbox update` first.
+		if pkg.IsRunX() || pkg.IsLegacy() {
+			continue
+		}
+		lockSystem := func(system string) (bool, error) {
+			return d.lockfile.LockSystem(ctx, pkg.LockfileKey(), system)
+		}
+		if !pkg.IsDevboxPackage {
+			// Flakes aren't resolved, so unless they're pinned to a commit
+			// each system could be evaluated at a different revision than
+			// the one that's installed.
+			flakeInstallable, err := pkg.FlakeInstallable()
+			if err != nil {
+				ux.Fwarning(d.stderr, "Unable to lock %s: %v\n", pkg.Raw, err)
+				continue
+			}
+			if flakeInstallable.Ref.Rev == "" {
+				ux.Fwarning(d.stderr,
+					"Skipping %s because only flakes that are pinned to a commit can be locked\n",
+					pkg.Raw)
+				continue
+			}
+			installable, err := pkg.InstallableForEval()
+			if err != nil {
+				ux.Fwarning(d.stderr, "Unable to lock %s: %v\n", pkg.Raw, err)
+				continue
+			}
+			lockSystem = func(system string) (bool, error) {
+				return d.lockfile.LockFlakeSystem(ctx, pkg.LockfileKey(), installable, system)
+			}
+		}
+		for _, system := range systems {
+			if !cfgPackages[i].IsEnabledOnSystem(system) {
+				continue
+			}
+			added, err := lockSystem(system)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				ux.Fwarning(d.stderr, "Unable to lock %s for %s: %v\n", pkg.Raw, system, err)
+				continue
+			}
+			if added {
+				fmt.Fprintf(d.stderr, "Locked %s for %s\n", pkg.Raw, system)
+			}
+		}
+	}
+	return d.lockfile.Save()
+}
+
+// VerifyLockfile re-evaluates the resolved reference of every package that is
+// installable on the current system and compares the result with the store
+// paths in devbox.lock. It doesn't modify the lockfile.
+func (d *Devbox) VerifyLockfile(ctx context.Context) (*lock.VerifyReport, error) {
+	required := []string{}
+	for _, pkg := range d.InstallablePackages() {
+		// Flakes and runx packages don't record store paths in the lockfile.
+		if pkg.IsDevboxPackage && !pkg.IsRunX() {
+			required = append(required, pkg.LockfileKey())
+		}
+	}
+	return d.lockfile.Verify(ctx, required)
+}
+
+// LockfileDiff compares devbox.lock at the git revision ref with the one in
+// the working tree. A lockfile that doesn't exist at ref is treated as empty.
+func (d *Devbox) LockfileDiff(ctx context.Context, ref string) (*lock.Diff, error) {
+	// Use the lockfile on disk rather than d.lockfile, which has the active
+	// profile's packages merged in, so that both sides are parsed the same
+	// way.
+	data, err := os.ReadFile(filepath.Join(d.projectDir, "devbox.lock"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+	current, err := lock.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = d.gitShowLockfile(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := lock.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return lock.DiffFiles(previous, current), nil
+}
+
+// gitShowLockfile returns the contents of devbox.lock at the git revision
+// ref, or nil if the lockfile doesn't exist at that revision.
+func (d *Devbox) gitShowLockfile(ctx context.Context, ref string) ([]byte, error) {
+	git := func(args ...string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = d.projectDir
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %w: %s",
+				strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+
+	if _, err := git("rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, usererr.WithUserMessage(err, "%q is not a git revision in %s", ref, d.projectDir)
+	}
+	data, err := git("show", ref+":./devbox.lock")
+	if err != nil {
+		// rev-parse succeeded, so the lockfile doesn't exist at ref.
+		return nil, nil //nolint:nilerr
+	}
+	return data, nil
+}
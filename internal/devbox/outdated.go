@@ -0,0 +1,192 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/internal/shellgen"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// OutdatedPackage is a package with a newer version than the one in
+// devbox.lock, or a package that couldn't be checked for updates.
+type OutdatedPackage struct {
+	Package string `json:"package"`
+
+	// Current is the version in devbox.lock, or the locked revision for
+	// flakes.
+	Current string `json:"current"`
+
+	// Wanted is the newest version that satisfies the version in
+	// devbox.json. It's what `devbox update` would install.
+	Wanted string `json:"wanted"`
+
+	// Latest is the newest version available, regardless of the version in
+	// devbox.json. For flakes, it's the revision of the flake's default
+	// branch.
+	Latest string `json:"latest"`
+
+	// Error is set if the package couldn't be checked for updates. The
+	// versions are empty in that case.
+	Error string `json:"error,omitempty"`
+}
+
+// Unknown returns true if the package couldn't be checked for updates.
+func (p *OutdatedPackage) Unknown() bool {
+	return p.Error != ""
+}
+
+// Outdated returns the packages in devbox.json that have a newer version
+// available than the one they're locked to. It doesn't modify the lockfile.
+// Packages that can't be resolved are returned with Error set. Flakes are
+// outdated when their reference, or the default branch of their repository,
+// points to a different revision than the one in the generated flake.lock.
+// Local path flakes don't have an upstream and are never outdated.
+func (d *Devbox) Outdated(ctx context.Context) ([]OutdatedPackage, error) {
+	flakeLock, _ := nix.ReadFlakeLock(filepath.Join(shellgen.FlakePath(d), "flake.lock"))
+
+	outdated := []OutdatedPackage{}
+	for _, pkg := range d.AllPackages() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var result *OutdatedPackage
+		var err error
+		switch {
+		case pkg.IsRunX():
+			result, err = d.outdatedRunX(ctx, pkg)
+		case pkg.IsDevboxPackage && !pkg.IsLegacy():
+			result, err = d.outdatedDevboxPackage(ctx, pkg)
+		case !pkg.IsDevboxPackage:
+			result, err = outdatedFlake(ctx, pkg, flakeLock)
+		default:
+			// Legacy packages don't have versions. `devbox update` converts
+			// them to versioned packages.
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result = &OutdatedPackage{Package: pkg.Raw, Error: err.Error()}
+		}
+		if result != nil {
+			outdated = append(outdated, *result)
+		}
+	}
+	return outdated, nil
+}
+
+func (d *Devbox) outdatedDevboxPackage(ctx context.Context, pkg *devpkg.Package) (*OutdatedPackage, error) {
+	current := d.lockfile.Get(pkg.LockfileKey())
+	if current == nil {
+		return nil, nil
+	}
+	name, version, _ := searcher.ParseVersionedPackage(pkg.Raw)
+	if version == "" {
+		version = "latest"
+	}
+	wanted, err := d.searcher.Resolve(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := d.searcher.Resolve(ctx, name, "latest")
+	if err != nil {
+		return nil, err
+	}
+	return outdatedVersion(pkg.Raw, current.Version, wanted.Version, latest.Version), nil
+}
+
+func (d *Devbox) outdatedRunX(ctx context.Context, pkg *devpkg.Package) (*OutdatedPackage, error) {
+	current := d.lockfile.Get(pkg.LockfileKey())
+	if current == nil {
+		return nil, nil
+	}
+	wanted, err := lock.ResolveRunXPackage(ctx, pkg.Raw)
+	if err != nil {
+		return nil, err
+	}
+	name, _, _ := searcher.ParseVersionedPackage(pkg.Raw)
+	if name == "" {
+		name = pkg.Raw
+	}
+	latest, err := lock.ResolveRunXPackage(ctx, name+"@latest")
+	if err != nil {
+		return nil, err
+	}
+	return outdatedVersion(pkg.Raw, current.Version, wanted.Version, latest.Version), nil
+}
+
+// outdatedFlake compares the revision of a flake input in the generated
+// flake.lock with the revision its reference currently points to upstream
+// (wanted) and with the revision of the default branch of its repository
+// (latest). It returns an error if the revisions can't be determined.
+func outdatedFlake(ctx context.Context, pkg *devpkg.Package, flakeLock *nix.FlakeLock) (*OutdatedPackage, error) {
+	installable, err := pkg.FlakeInstallable()
+	if err != nil {
+		return nil, err
+	}
+	if installable.Ref.Type == flake.TypePath {
+		return nil, nil
+	}
+
+	if flakeLock == nil {
+		return nil, errors.New("flake isn't locked yet, run devbox install first")
+	}
+	input, ok := flakeLock.Input(pkg.FlakeInputName())
+	if !ok {
+		return nil, errors.New("flake isn't locked yet, run devbox install first")
+	}
+	if input.Locked.Rev == "" {
+		return nil, errors.New("flake isn't in a git repository, so it doesn't have revisions")
+	}
+
+	wanted, err := nix.GetFlakeMetadata(ctx, pkg.URLForFlakeInput())
+	if err != nil {
+		return nil, err
+	}
+	latest := wanted
+	if installable.Ref.Rev != "" || installable.Ref.Ref != "" {
+		upstream := installable.Ref
+		upstream.Rev, upstream.Ref = "", ""
+		if latest, err = nix.GetFlakeMetadata(ctx, upstream.String()); err != nil {
+			return nil, err
+		}
+	}
+	if wanted.Revision == "" || latest.Revision == "" {
+		return nil, errors.New("upstream flake doesn't have a revision")
+	}
+	if wanted.Revision == input.Locked.Rev && latest.Revision == input.Locked.Rev {
+		return nil, nil
+	}
+	return &OutdatedPackage{
+		Package: pkg.Raw,
+		Current: input.Locked.Rev,
+		Wanted:  wanted.Revision,
+		Latest:  latest.Revision,
+	}, nil
+}
+
+// outdatedVersion returns an OutdatedPackage if wanted or latest is newer than
+// current, or nil otherwise.
+func outdatedVersion(pkg, current, wanted, latest string) *OutdatedPackage {
+	if searcher.CompareVersions(current, wanted) >= 0 &&
+		searcher.CompareVersions(current, latest) >= 0 {
+		return nil
+	}
+	return &OutdatedPackage{
+		Package: pkg,
+		Current: current,
+		Wanted:  wanted,
+		Latest:  latest,
+	}
+}
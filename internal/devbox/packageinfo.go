@@ -0,0 +1,169 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/plugin"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// PackageInfo describes a package for machine-readable output from
+// `devbox list`, `devbox info`, and `devbox search`. Fields that don't apply
+// to a package, or that aren't known without installing it, are empty.
+type PackageInfo struct {
+	// Name is the package as it's written in devbox.json, such as go@1.22.
+	Name string `json:"name" yaml:"name"`
+
+	// Version is the resolved version, such as 1.22.3.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+
+	Summary string `json:"summary,omitempty" yaml:"summary,omitempty"`
+
+	// Resolved is the flake installable that the package resolves to.
+	Resolved string `json:"resolved,omitempty" yaml:"resolved,omitempty"`
+
+	// Outputs and StorePaths are for the current system.
+	Outputs    []PackageOutput `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	StorePaths []string        `json:"store_paths,omitempty" yaml:"store_paths,omitempty"`
+
+	// Plugin is the name of the built-in plugin that the package uses.
+	Plugin string `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+
+	// Source is the included devbox.json that added the package.
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+
+	Platforms         []string `json:"platforms,omitempty" yaml:"platforms,omitempty"`
+	ExcludedPlatforms []string `json:"excluded_platforms,omitempty" yaml:"excluded_platforms,omitempty"`
+	AllowInsecure     bool     `json:"allow_insecure,omitempty" yaml:"allow_insecure,omitempty"`
+}
+
+// ConfigSources shows which included devbox.json files, plugins and profiles
+// the project's packages, environment variables, scripts and init hooks come
+// from. Anything that the project's own devbox.json declares is left out.
+type ConfigSources struct {
+	// Packages, Env and Scripts map names to the file, plugin or profile
+	// that declared them.
+	Packages map[string]string `json:"packages,omitempty" yaml:"packages,omitempty"`
+	Env      map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Scripts  map[string]string `json:"scripts,omitempty" yaml:"scripts,omitempty"`
+
+	// InitHook has the files, plugins and profiles with an init_hook, in
+	// the order that they run.
+	InitHook []string `json:"init_hook,omitempty" yaml:"init_hook,omitempty"`
+}
+
+// Empty reports whether everything in the project's config comes from its
+// own devbox.json.
+func (s *ConfigSources) Empty() bool {
+	return len(s.Packages) == 0 && len(s.Env) == 0 && len(s.Scripts) == 0 && len(s.InitHook) == 0
+}
+
+// ConfigSources returns where the parts of the project's config that don't
+// come from its own devbox.json were declared.
+func (d *Devbox) ConfigSources() *ConfigSources {
+	return &ConfigSources{
+		Packages: d.cfg.PackageSources(),
+		Env:      d.cfg.EnvSources(),
+		Scripts:  d.cfg.ScriptSources(),
+		InitHook: d.cfg.InitHookSources(),
+	}
+}
+
+// PackageOutput is a nix output of a package.
+type PackageOutput struct {
+	Name    string `json:"name" yaml:"name"`
+	Path    string `json:"path,omitempty" yaml:"path,omitempty"`
+	Default bool   `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// ListPackages returns information about every package in devbox.json,
+// including packages that plugins add. It only reads devbox.json and
+// devbox.lock, so packages that haven't been resolved yet don't have versions
+// or outputs.
+func (d *Devbox) ListPackages(ctx context.Context) ([]PackageInfo, error) {
+	cfgPackages := d.cfg.Packages(true /*includeRemovedTriggerPackages*/)
+	sources := d.cfg.PackageSources()
+
+	infos := make([]PackageInfo, 0, len(cfgPackages))
+	for i, pkg := range devpkg.PackagesFromConfig(cfgPackages, d.lockfile) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info, err := d.packageInfo(pkg, cfgPackages[i])
+		if err != nil {
+			return nil, err
+		}
+		info.Source = sources[cfgPackages[i].Name]
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// packageInfo returns information about a package from devbox.json and its
+// entry in devbox.lock.
+func (d *Devbox) packageInfo(pkg *devpkg.Package, cfgPkg configfile.Package) (*PackageInfo, error) {
+	info := &PackageInfo{
+		Name:              pkg.Raw,
+		Platforms:         cfgPkg.Platforms,
+		ExcludedPlatforms: cfgPkg.ExcludedPlatforms,
+		AllowInsecure:     len(cfgPkg.AllowInsecure) > 0,
+	}
+
+	pluginName, err := plugin.BuiltinPluginName(pkg, d.projectDir)
+	if err != nil {
+		return nil, err
+	}
+	info.Plugin = pluginName
+
+	locked := d.lockfile.Get(pkg.LockfileKey())
+	if locked == nil {
+		return info, nil
+	}
+	info.Version = locked.Version
+	info.Resolved = locked.Resolved
+	info.AllowInsecure = info.AllowInsecure || locked.AllowInsecure
+	if sysInfo := locked.Systems[nix.System()]; sysInfo != nil {
+		for _, out := range sysInfo.Outputs {
+			info.Outputs = append(info.Outputs, PackageOutput{
+				Name:    out.Name,
+				Path:    out.Path,
+				Default: out.Default,
+			})
+			if out.Path != "" {
+				info.StorePaths = append(info.StorePaths, out.Path)
+			}
+		}
+	}
+	return info, nil
+}
+
+// SearchPackageInfo returns information about a package version from the
+// search index. Platforms are the systems that the version is available on.
+func SearchPackageInfo(pkg *searcher.PackageVersion) PackageInfo {
+	info := PackageInfo{
+		Name:    pkg.Name + "@" + pkg.Version,
+		Version: pkg.Version,
+		Summary: pkg.Summary,
+	}
+	for system := range pkg.Systems {
+		info.Platforms = append(info.Platforms, system)
+	}
+	slices.Sort(info.Platforms)
+
+	sysInfo, ok := pkg.Systems[nix.System()]
+	if !ok {
+		sysInfo = pkg.PackageInfo
+	}
+	if sysInfo.CommitHash != "" && len(sysInfo.AttrPaths) > 0 {
+		info.Resolved = fmt.Sprintf("github:NixOS/nixpkgs/%s#%s", sysInfo.CommitHash, sysInfo.AttrPaths[0])
+	}
+	return info
+}
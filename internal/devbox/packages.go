@@ -24,6 +24,7 @@ import (
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/setup"
 	"go.jetpack.io/devbox/internal/shellgen"
@@ -48,6 +49,12 @@ func (d *Devbox) Add(ctx context.Context, pkgsNames []string, opts devopt.AddOpt
 	ctx, task := trace.NewTask(ctx, "devboxAdd")
 	defer task.End()
 
+	return d.withPluginHooks(ctx, func() error {
+		return d.add(ctx, pkgsNames, opts)
+	}, plugin.OnInstall)
+}
+
+func (d *Devbox) add(ctx context.Context, pkgsNames []string, opts devopt.AddOpts) error {
 	// Track which packages had no changes so we can report that to the user.
 	unchangedPackageNames := []string{}
 
@@ -195,6 +202,12 @@ func (d *Devbox) Remove(ctx context.Context, pkgs ...string) error {
 	ctx, task := trace.NewTask(ctx, "devboxRemove")
 	defer task.End()
 
+	return d.withPluginHooks(ctx, func() error {
+		return d.remove(ctx, pkgs...)
+	})
+}
+
+func (d *Devbox) remove(ctx context.Context, pkgs ...string) error {
 	packagesToUninstall := []string{}
 	missingPkgs := []string{}
 	for _, pkg := range lo.Uniq(pkgs) {
@@ -254,6 +267,12 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 	defer trace.StartRegion(ctx, "devboxEnsureStateIsUpToDate").End()
 	defer debug.FunctionTimer().End()
 
+	// Install, shell, run and services up all get here, so plugins have
+	// their ports before the environment is computed.
+	if err := d.allocatePorts(ctx); err != nil {
+		return err
+	}
+
 	upToDate, err := d.lockfile.IsUpToDateAndInstalled(isFishShell())
 	if err != nil {
 		return err
@@ -270,6 +289,12 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 		ux.Finfo(d.stderr, "Ensuring packages are installed.\n")
 	}
 
+	if envir.IsOffline() && mode != uninstall {
+		if err := d.ensurePackagesAreAvailableOffline(ctx); err != nil {
+			return err
+		}
+	}
+
 	if mode == install || mode == update || mode == ensure {
 		if err := d.installPackages(ctx, mode); err != nil {
 			return err
@@ -301,8 +326,12 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 // updateLockfile will ensure devbox.lock is up to date with the current state of the project.update
 // If recomputeState is true, then we will also update the local.lock file.
 func (d *Devbox) updateLockfile(recomputeState bool) error {
-	// Ensure we clean out packages that are no longer needed.
+	// Ensure we clean out packages and plugins that are no longer needed.
 	d.lockfile.Tidy()
+	d.lockfile.TidyPlugins(lo.Map(
+		d.cfg.IncludedPluginConfigs(),
+		func(cfg *plugin.Config, _ int) string { return cfg.Source.LockfileKey() },
+	))
 
 	// Update lockfile with new packages that are not to be installed
 	for _, pkg := range d.AllPackages() {
@@ -324,11 +353,15 @@ func (d *Devbox) updateLockfile(recomputeState bool) error {
 		if err != nil {
 			return err
 		}
-		return lock.UpdateAndSaveStateHashFile(lock.UpdateStateHashFileArgs{
+		args := lock.UpdateStateHashFileArgs{
 			ProjectDir: d.projectDir,
 			ConfigHash: configHash,
 			IsFish:     isFishShell(),
-		})
+		}
+		if profile := d.ActiveProfile(); profile != nil {
+			args.Profile = profile.Name
+		}
+		return lock.UpdateAndSaveStateHashFile(args)
 	}
 	return nil
 }
@@ -401,12 +434,15 @@ func (d *Devbox) installPackages(ctx context.Context, mode installMode) error {
 	defer debug.FunctionTimer().End()
 	// Create plugin directories first because packages might need them
 	for _, pluginConfig := range d.Config().IncludedPluginConfigs() {
-		if err := d.PluginManager().CreateFilesForConfig(pluginConfig); err != nil {
+		if err := d.PluginManager().CreateFilesForConfig(ctx, pluginConfig); err != nil {
 			return err
 		}
 	}
 
 	if err := d.installNixPackagesToStore(ctx, mode); err != nil {
+		if envir.IsOffline() {
+			return err
+		}
 		if caches, _ := nixcache.CachedReadCaches(ctx); len(caches) > 0 {
 			err = d.handleInstallFailure(ctx, mode)
 		}
@@ -512,6 +548,9 @@ func (d *Devbox) installNixPackagesToStore(ctx context.Context, mode installMode
 }
 
 func (d *Devbox) appendExtraSubstituters(ctx context.Context, args *nix.BuildArgs) error {
+	if envir.IsOffline() {
+		return nil
+	}
 	creds, err := nixcache.CachedCredentials(ctx)
 	if errors.Is(err, auth.ErrNotLoggedIn) {
 		return nil
@@ -649,6 +688,63 @@ func (d *Devbox) moveAllowInsecureFromLockfile(writer io.Writer, lockfile *lock.
 	return nil
 }
 
+// ensurePackagesAreAvailableOffline returns a user error listing every package
+// that can't be installed without network access because it's missing from
+// devbox.lock or from the local nix store. Flakes and legacy packages are left
+// to nix, which uses its own cache in offline mode.
+func (d *Devbox) ensurePackagesAreAvailableOffline(ctx context.Context) error {
+	defer debug.FunctionTimer().End()
+
+	packages := d.InstallablePackages()
+	missing := []string{}
+	pkgStorePaths := map[string][]string{}
+	storePaths := []string{}
+	for _, pkg := range packages {
+		if !pkg.IsDevboxPackage || pkg.IsLegacy() {
+			continue
+		}
+		if d.lockfile.Get(pkg.LockfileKey()) == nil {
+			missing = append(missing, pkg.Raw+" (not in devbox.lock)")
+			continue
+		}
+		if pkg.IsRunX() {
+			continue
+		}
+		paths, err := pkg.GetResolvedStorePaths()
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			missing = append(missing, fmt.Sprintf("%s (no store paths for %s in devbox.lock)", pkg.Raw, nix.System()))
+			continue
+		}
+		pkgStorePaths[pkg.Raw] = paths
+		storePaths = append(storePaths, paths...)
+	}
+
+	inStore, err := nix.StorePathsAreInStore(ctx, storePaths)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range packages {
+		for _, path := range pkgStorePaths[pkg.Raw] {
+			if !inStore[path] {
+				missing = append(missing, pkg.Raw+" (not in the local nix store)")
+				break
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return usererr.New(
+			"The following packages are not available offline:\n\n  %s\n\n"+
+				"Run `devbox install` with network access to download them, or run without --offline.",
+			strings.Join(missing, "\n  "),
+		)
+	}
+	return nil
+}
+
 func (d *Devbox) FixMissingStorePaths(ctx context.Context) error {
 	packages := d.InstallablePackages()
 	for _, pkg := range packages {
@@ -0,0 +1,138 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/plugin"
+)
+
+// PluginInfo describes an included plugin for `devbox plugin list` and
+// `devbox plugin show`.
+type PluginInfo struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// Type is where the plugin comes from: builtin, local, github, git,
+	// tarball, or file.
+	Type string `json:"type" yaml:"type"`
+
+	// Include is the include reference of the plugin. For built-in plugins,
+	// it's the package that uses the plugin.
+	Include string `json:"include" yaml:"include"`
+
+	// Rev and Hash are the plugin's pin in devbox.lock, if it has one.
+	Rev  string `json:"rev,omitempty" yaml:"rev,omitempty"`
+	Hash string `json:"hash,omitempty" yaml:"hash,omitempty"`
+
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Packages    []string          `json:"packages,omitempty" yaml:"packages,omitempty"`
+	Env         map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Files are the files and directories that the plugin creates.
+	Files    []string `json:"files,omitempty" yaml:"files,omitempty"`
+	Services []string `json:"services,omitempty" yaml:"services,omitempty"`
+
+	// Scripts are the names that the plugin's scripts can be run with, such
+	// as postgresql:setup.
+	Scripts []string `json:"scripts,omitempty" yaml:"scripts,omitempty"`
+
+	// Hooks are the events that the plugin runs commands for, such as
+	// on_add.
+	Hooks []plugin.HookEvent `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+}
+
+// ListPlugins returns every plugin that the project includes, including
+// plugins included by other plugins and built-in plugins used by packages.
+func (d *Devbox) ListPlugins(ctx context.Context) ([]PluginInfo, error) {
+	infos := []PluginInfo{}
+	for _, cfg := range d.cfg.IncludedPluginConfigs() {
+		info, err := d.pluginInfo(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// PluginInfo returns information about an included plugin. See findPlugin
+// for the names it accepts.
+func (d *Devbox) PluginInfo(ctx context.Context, name string) (*PluginInfo, error) {
+	cfg, err := d.findPlugin(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return d.pluginInfo(ctx, cfg)
+}
+
+// RenderPlugin returns the files that a plugin creates in the project after
+// templating, without writing them. See findPlugin for the names it accepts.
+func (d *Devbox) RenderPlugin(ctx context.Context, name string) ([]plugin.RenderedFile, error) {
+	cfg, err := d.findPlugin(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return d.pluginManager.RenderFiles(ctx, cfg)
+}
+
+func (d *Devbox) pluginInfo(ctx context.Context, cfg *plugin.Config) (*PluginInfo, error) {
+	info := &PluginInfo{
+		Name:        cfg.Source.CanonicalName(),
+		Version:     cfg.Version,
+		Type:        plugin.SourceType(cfg.Source),
+		Include:     cfg.Source.LockfileKey(),
+		Description: cfg.Description(),
+		Env:         cfg.Env,
+		Files:       lo.Keys(cfg.CreateFiles),
+		Scripts:     cfg.ScriptNames(),
+	}
+	if locked := d.lockfile.GetPlugin(info.Include); locked != nil {
+		info.Rev = locked.Rev
+		info.Hash = locked.Hash
+	}
+	for _, pkg := range cfg.TopLevelPackages() {
+		info.Packages = append(info.Packages, pkg.VersionedName())
+	}
+	slices.Sort(info.Files)
+	for _, event := range []plugin.HookEvent{
+		plugin.OnInstall, plugin.OnAdd, plugin.OnRemove, plugin.OnUpdate,
+	} {
+		if cfg.Hook(event) != nil {
+			info.Hooks = append(info.Hooks, event)
+		}
+	}
+
+	services, err := cfg.ServiceNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.Services = services
+	return info, nil
+}
+
+// findPlugin returns the included plugin with a name or include reference.
+// Plugins that aren't included can be given by their include reference, such
+// as path:./my-plugin, which is useful while writing a plugin.
+func (d *Devbox) findPlugin(ctx context.Context, name string) (*plugin.Config, error) {
+	for _, cfg := range d.cfg.IncludedPluginConfigs() {
+		if name == cfg.Source.CanonicalName() || name == cfg.Name ||
+			name == cfg.Source.LockfileKey() {
+			return cfg, nil
+		}
+	}
+	if strings.ContainsAny(name, ":/") {
+		return plugin.LoadConfigFromInclude(
+			ctx, name, d.lockfile, d.pluginManager.Refetch(), d.projectDir)
+	}
+	return nil, usererr.New(
+		"No plugin named %s is included in this project. Run `devbox plugin list` to see the included plugins.",
+		name,
+	)
+}
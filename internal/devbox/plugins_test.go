@@ -0,0 +1,79 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// testSearchServer serves /v2/resolve for any package and version, so that
+// packages can be locked without the search API.
+func testSearchServer(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, version := r.URL.Query().Get("name"), r.URL.Query().Get("version")
+		resolved := searcher.ResolveResponse{
+			Name:    name,
+			Version: version,
+			Systems: map[string]searcher.ResolvedSystem{
+				"x86_64-linux": {
+					FlakeInstallable: flake.Installable{
+						Ref:      flake.Ref{Type: flake.TypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Rev: "abc"},
+						AttrPath: name,
+					},
+				},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(resolved); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(envir.DevboxSearchHost, server.URL)
+}
+
+func TestPluginPackagesAreLocked(t *testing.T) {
+	testSearchServer(t)
+	d := devboxWithHelloPlugin(t, `{"name": "hello", "version": "1", "packages": ["jq@1.7"]}`)
+	ctx := context.Background()
+
+	// Install resolves the packages to install before building them, and
+	// then saves devbox.lock.
+	install := func() {
+		t.Helper()
+		require.NoError(t, d.cfg.LoadRecursive(ctx, d.lockfile, nil))
+		for _, pkg := range d.InstallablePackages() {
+			_, err := d.lockfile.Resolve(pkg.Raw)
+			require.NoError(t, err)
+		}
+		require.NoError(t, d.updateLockfile(false /*recomputeState*/))
+	}
+
+	d.cfg.PackageMutator().Add("hello@latest")
+	install()
+	saved, err := lock.GetFile(d)
+	require.NoError(t, err)
+	assert.Contains(t, saved.Packages, "hello@latest")
+	assert.Contains(t, saved.Packages, "jq@1.7", "the plugin's packages should be locked")
+	assert.Equal(t, "github:NixOS/nixpkgs/abc#jq", saved.Packages["jq@1.7"].Resolved)
+
+	// The plugin's packages are removed from devbox.lock with the package
+	// that activated it.
+	d.cfg.PackageMutator().Remove("hello@latest")
+	install()
+	saved, err = lock.GetFile(d)
+	require.NoError(t, err)
+	assert.NotContains(t, saved.Packages, "jq@1.7")
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// SearcherForDir returns the package searcher that the search field of the
+// devbox.json in dir or its parent directories selects. Commands that don't
+// open a project, such as devbox search, use it so that they search the same
+// packages as the project. Outside of a project, it returns the default
+// searcher.
+func SearcherForDir(dir string, stderr io.Writer) (searcher.Searcher, error) {
+	projectDir, err := findProjectDir(dir)
+	if err != nil {
+		// Outside of a project, search uses the Devbox search API or
+		// DEVBOX_SEARCH_NIXPKGS.
+		return searcher.New(searcher.LocalIndexConfig{Stderr: stderr}), nil //nolint:nilerr
+	}
+	cfg, err := devconfig.Open(projectDir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return newSearcher(projectDir, cfg.Root.Search, stderr), nil
+}
+
+// Searcher returns the package searcher that the search field of devbox.json
+// selects.
+func (d *Devbox) Searcher() searcher.Searcher {
+	return d.searcher
+}
+
+// newSearcher returns a searcher for the local index in search, resolving
+// paths relative to projectDir.
+func newSearcher(projectDir string, search *configfile.SearchConfig, stderr io.Writer) searcher.Searcher {
+	cfg := searcher.LocalIndexConfig{Stderr: stderr}
+	if search != nil {
+		cfg.Nixpkgs = search.Nixpkgs
+		if dir := filepath.Join(projectDir, search.Nixpkgs); !filepath.IsAbs(search.Nixpkgs) &&
+			fileutil.IsDir(dir) {
+			cfg.Nixpkgs = dir
+		}
+		cfg.Dump = search.Dump
+		if cfg.Dump != "" && !filepath.IsAbs(cfg.Dump) {
+			cfg.Dump = filepath.Join(projectDir, cfg.Dump)
+		}
+	}
+	return searcher.New(cfg)
+}
@@ -2,8 +2,12 @@ package devbox
 
 import (
 	"context"
+	"slices"
+	"strings"
 
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/ux"
 	"go.jetpack.io/envsec/pkg/envsec"
 	"go.jetpack.io/envsec/pkg/stores/jetstore"
 	"go.jetpack.io/pkg/envvar"
@@ -31,8 +35,12 @@ func (d *Devbox) Secrets(ctx context.Context) (*envsec.Envsec, error) {
 		return nil, err
 	}
 
+	envName, err := d.secretsEnvironment()
+	if err != nil {
+		return nil, err
+	}
 	envsecInstance.EnvID = envsec.EnvID{
-		EnvName:   d.environment,
+		EnvName:   envName,
 		OrgID:     project.OrgID.String(),
 		ProjectID: project.ProjectID.String(),
 	}
@@ -43,3 +51,29 @@ func (d *Devbox) Secrets(ctx context.Context) (*envsec.Envsec, error) {
 
 	return envsecInstance, nil
 }
+
+// secretsEnvironment returns the secrets environment of the selected
+// environment. Secrets only support the built-in environments, so custom
+// profiles use the environment in their secrets field, or dev if they don't
+// set one.
+func (d *Devbox) secretsEnvironment() (string, error) {
+	if slices.Contains(builtInEnvironments, d.environment) {
+		return d.environment, nil
+	}
+	_, profile := d.cfg.Profile()
+	if profile == nil || profile.Secrets == "" {
+		ux.Fwarning(
+			d.stderr,
+			"Profile %q doesn't set a secrets environment. Using the dev secrets.\n",
+			d.environment,
+		)
+		return "dev", nil
+	}
+	if !slices.Contains(builtInEnvironments, profile.Secrets) {
+		return "", usererr.New(
+			"Profile %q has invalid secrets environment %q. Valid environments are: %s",
+			d.environment, profile.Secrets, strings.Join(builtInEnvironments, ", "),
+		)
+	}
+	return profile.Secrets, nil
+}
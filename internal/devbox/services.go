@@ -3,10 +3,22 @@ package devbox
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/plugin"
 	"go.jetpack.io/devbox/internal/services"
 )
 
@@ -122,15 +134,270 @@ func (d *Devbox) ListServices(ctx context.Context, runInCurrentShell bool) error
 		fmt.Fprintln(d.stderr, "Error listing services: ", err)
 	} else {
 		fmt.Fprintln(d.stderr, "Services running in process-compose:")
-		fmt.Fprintln(tw, "NAME\tSTATUS\tEXIT CODE")
+		fmt.Fprintln(tw, "NAME\tSTATUS\tREADINESS\tEXIT CODE")
 		for _, s := range pcSvcs {
-			fmt.Fprintf(tw, "%s\t%s\t%d\n", s.Name, s.Status, s.ExitCode)
+			readiness := s.Readiness(svcSet[s.Name].HasReadinessProbe)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", s.Name, s.Status, readiness, s.ExitCode)
 		}
 		tw.Flush()
 	}
 	return nil
 }
 
+// ServiceLogs writes the logs of running services to w. If no services are
+// given, it writes the logs of every service that process-compose is running.
+func (d *Devbox) ServiceLogs(
+	ctx context.Context, w io.Writer, opts devopt.ServiceLogsOpts, serviceNames ...string,
+) error {
+	if !services.ProcessManagerIsRunning(d.projectDir) {
+		return usererr.New("Process manager is not running. Run `devbox services up` to start it.")
+	}
+
+	running, err := services.ListServices(ctx, d.projectDir, d.stderr)
+	if err != nil {
+		return err
+	}
+	runningNames := lo.Map(running, func(p services.Process, _ int) string { return p.Name })
+	slices.Sort(runningNames)
+
+	for _, s := range serviceNames {
+		if !slices.Contains(runningNames, s) {
+			return usererr.New("Service %s is not running in process-compose", s)
+		}
+	}
+	if len(serviceNames) == 0 {
+		serviceNames = runningNames
+	}
+
+	return services.StreamLogs(ctx, w, d.projectDir, serviceNames, services.LogsOpts{
+		Follow: opts.Follow,
+		Since:  opts.Since,
+		Tail:   opts.Tail,
+	})
+}
+
+// WaitForServices blocks until the given services are ready, or until the
+// timeout. A service with a readiness probe is ready once the probe passes,
+// and a service without one is ready once it's running. If no services are
+// given, it waits for every service that process-compose is running.
+func (d *Devbox) WaitForServices(
+	ctx context.Context, timeout time.Duration, serviceNames ...string,
+) error {
+	if !services.ProcessManagerIsRunning(d.projectDir) {
+		return usererr.New("Process manager is not running. Run `devbox services up --background` to start it.")
+	}
+
+	svcSet, err := d.Services()
+	if err != nil {
+		return err
+	}
+	for _, s := range serviceNames {
+		if _, ok := svcSet[s]; !ok {
+			return usererr.New("Service %s not found in your project", s)
+		}
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return services.WaitForServices(ctx, d.stderr, d.projectDir, serviceNames,
+		func(name string) bool { return svcSet[name].HasReadinessProbe })
+}
+
+// ProcessManager returns the project's running process-compose instance and
+// the status of its services, or nil if it isn't running.
+func (d *Devbox) ProcessManager(ctx context.Context) (*services.ProcessManager, error) {
+	managers, err := services.ListProcessManagers(ctx, d.projectDir)
+	if err != nil || len(managers) == 0 {
+		return nil, err
+	}
+	return &managers[0], nil
+}
+
+// AllProcessManagers returns every process-compose instance that devbox is
+// running on this machine, across all projects.
+func AllProcessManagers(ctx context.Context) ([]services.ProcessManager, error) {
+	return services.ListProcessManagers(ctx, "")
+}
+
+// ServicePorts returns the ports assigned to the project's services, keyed by
+// name. Ports are assigned when the project is installed or started, so it
+// doesn't list the ports of plugins that were added since.
+func (d *Devbox) ServicePorts() map[string]int {
+	return services.ProjectPorts(d.projectDir)
+}
+
+// ReleaseServicePorts removes the project's ports from the port registry, so
+// that other projects can be assigned them. The project is assigned ports
+// again the next time it's installed or started. It returns the names of the
+// released ports.
+func (d *Devbox) ReleaseServicePorts() ([]string, error) {
+	return services.ReleasePorts(d.projectDir, func(string) bool { return true })
+}
+
+// allocatePorts assigns ports to every port that the project's plugins use
+// and releases the ones they no longer use. If a port was newly assigned,
+// the config is reloaded so that plugins use it.
+func (d *Devbox) allocatePorts(ctx context.Context) error {
+	allocated, err := plugin.AllocatePorts(d.projectDir, d.cfg.IncludedPluginConfigs())
+	if err != nil || !allocated {
+		return err
+	}
+	return d.cfg.LoadRecursive(ctx, d.lockfile, d.pluginManager.Refetch())
+}
+
+// SaveSnapshot saves the data of the project's plugins that run services,
+// such as a postgresql database, in a snapshot called name. Services that are
+// running are stopped while the snapshot is saved.
+func (d *Devbox) SaveSnapshot(ctx context.Context, name string) (*services.Snapshot, error) {
+	svcs, err := d.Services()
+	if err != nil {
+		return nil, err
+	}
+	pluginSvcs := services.PluginServices(d.projectDir, svcs)
+	plugins := lo.Keys(pluginSvcs)
+	slices.Sort(plugins)
+
+	var snapshot *services.Snapshot
+	err = d.withServicesPaused(ctx, lo.Flatten(lo.Values(pluginSvcs)), func() error {
+		snapshot, err = services.SaveSnapshot(d.projectDir, name, plugins)
+		return err
+	})
+	return snapshot, err
+}
+
+// RestoreSnapshot replaces the data of the plugins in a snapshot with the data
+// that was saved in it. Services that are running are stopped while the
+// snapshot is restored.
+func (d *Devbox) RestoreSnapshot(ctx context.Context, name string) (*services.Snapshot, error) {
+	snapshot, err := services.ReadSnapshot(d.projectDir, name)
+	if err != nil {
+		return nil, err
+	}
+	svcs, err := d.Services()
+	if err != nil {
+		return nil, err
+	}
+	pluginSvcs := services.PluginServices(d.projectDir, svcs)
+	var svcNames []string
+	for _, plugin := range snapshot.Plugins {
+		svcNames = append(svcNames, pluginSvcs[plugin]...)
+	}
+
+	err = d.withServicesPaused(ctx, svcNames, func() error {
+		return services.RestoreSnapshot(d.projectDir, snapshot)
+	})
+	return snapshot, err
+}
+
+// ListSnapshots returns the project's service snapshots.
+func (d *Devbox) ListSnapshots() ([]services.Snapshot, error) {
+	return services.ListSnapshots(d.projectDir)
+}
+
+// withServicesPaused stops whichever of the named services are running, calls
+// fn, and then starts them again.
+func (d *Devbox) withServicesPaused(ctx context.Context, serviceNames []string, fn func() error) error {
+	stopCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	resume, err := services.PauseServices(stopCtx, d.stderr, d.projectDir, serviceNames)
+	if err == nil {
+		err = fn()
+	}
+	if resumeErr := resume(ctx); err == nil {
+		err = resumeErr
+	}
+	return err
+}
+
+// ExportServices writes the project's services as systemd user units, either
+// to w or to opts.Dir.
+func (d *Devbox) ExportServices(
+	ctx context.Context, w io.Writer, runInCurrentShell bool, opts devopt.ServiceExportOpts,
+) error {
+	if opts.Format != "systemd" {
+		return usererr.New("Unsupported export format %q. The only supported format is systemd.", opts.Format)
+	}
+	if !runInCurrentShell {
+		args := []string{"export", "--run-in-current-shell", "--format", opts.Format}
+		if opts.Dir != "" {
+			dir, err := filepath.Abs(opts.Dir)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			args = append(args, "--dir", dir)
+		}
+		return d.runDevboxServicesScript(ctx, args)
+	}
+
+	units, err := d.systemdUnits()
+	if err != nil {
+		return err
+	}
+	if opts.Dir != "" {
+		paths, err := services.WriteSystemdUnits(opts.Dir, units)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			fmt.Fprintf(d.stderr, "Wrote %s\n", path)
+		}
+		return nil
+	}
+	for i, unit := range units {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "### %s\n%s", unit.Name, unit.Contents)
+	}
+	return nil
+}
+
+// InstallServices installs the project's services as systemd user units and
+// starts them.
+func (d *Devbox) InstallServices(ctx context.Context, runInCurrentShell bool) error {
+	if !runInCurrentShell {
+		return d.runDevboxServicesScript(ctx, []string{"install", "--run-in-current-shell"})
+	}
+	units, err := d.systemdUnits()
+	if err != nil {
+		return err
+	}
+	return services.InstallSystemdUnits(ctx, d.stderr, d.projectDir, units)
+}
+
+// UninstallServices stops the project's systemd user units and removes them.
+func (d *Devbox) UninstallServices(ctx context.Context) error {
+	return services.UninstallSystemdUnits(ctx, d.stderr, d.projectDir)
+}
+
+func (d *Devbox) systemdUnits() ([]services.SystemdUnit, error) {
+	svcs, err := d.Services()
+	if err != nil {
+		return nil, err
+	}
+	devboxPath, err := devboxExecutable()
+	if err != nil {
+		return nil, err
+	}
+	return services.SystemdUnits(d.projectDir, devboxPath, svcs)
+}
+
+// devboxExecutable returns the path to the Devbox launcher script or the
+// current binary if the launcher is unavailable. Units run the launcher so
+// that they keep working when devbox is updated.
+func devboxExecutable() (string, error) {
+	if exe := os.Getenv(envir.LauncherPath); exe != "" {
+		if abs, err := filepath.Abs(exe); err == nil {
+			return abs, nil
+		}
+	}
+	exe, err := os.Executable()
+	return exe, errors.WithStack(err)
+}
+
 func (d *Devbox) RestartServices(
 	ctx context.Context, runInCurrentShell bool, serviceNames ...string,
 ) error {
@@ -176,6 +443,14 @@ func (d *Devbox) StartProcessManager(
 	requestedServices []string,
 	processComposeOpts devopt.ProcessComposeOpts,
 ) error {
+	native, err := useNativeSupervisor(processComposeOpts.Supervisor)
+	if err != nil {
+		return err
+	}
+	if native && len(processComposeOpts.ExtraFlags) > 0 {
+		return usererr.New("--pcflags can't be used with the native supervisor")
+	}
+
 	if !runInCurrentShell {
 		args := []string{"up", "--run-in-current-shell"}
 		args = append(args, requestedServices...)
@@ -192,6 +467,9 @@ func (d *Devbox) StartProcessManager(
 		for _, flag := range processComposeOpts.ExtraFlags {
 			args = append(args, "--pcflags", flag)
 		}
+		if processComposeOpts.Supervisor != "" {
+			args = append(args, "--supervisor", processComposeOpts.Supervisor)
+		}
 
 		return d.runDevboxServicesScript(ctx, args)
 	}
@@ -211,29 +489,181 @@ func (d *Devbox) StartProcessManager(
 		}
 	}
 
-	err = initDevboxUtilityProject(ctx, d.stderr)
-	if err != nil {
-		return err
+	// The native supervisor is built into devbox, so it doesn't need the
+	// process-compose binary from the utility project.
+	var binPath string
+	if native {
+		binPath, err = os.Executable()
+	} else {
+		if err = initDevboxUtilityProject(ctx, d.stderr); err != nil {
+			return err
+		}
+		binPath, err = utilityLookPath("process-compose")
 	}
-
-	processComposeBinPath, err := utilityLookPath("process-compose")
 	if err != nil {
-		return err
+		return errors.WithStack(err)
 	}
 
+	watches := d.serviceWatches(requestedServices)
+
 	// Start the process manager
 
-	return services.StartProcessManager(
+	err = services.StartProcessManager(
 		d.stderr,
 		requestedServices,
 		svcs,
 		d.projectDir,
 		services.ProcessComposeOpts{
-			BinPath:    processComposeBinPath,
+			BinPath:    binPath,
 			Background: processComposeOpts.Background,
 			ExtraFlags: processComposeOpts.ExtraFlags,
+			Native:     native,
+			Watches:    watches,
 		},
 	)
+	if err == nil && processComposeOpts.Background && len(watches) > 0 {
+		fmt.Fprintln(d.stderr, "\nTip: Run `devbox services watch` to restart services when their files change")
+	}
+	return err
+}
+
+// RunServicesCI starts the process manager in the background, waits for the
+// services to be ready, runs opts.Command, and then stops the process manager.
+// The logs of services that crashed are printed before stopping it. The
+// returned error has the exit code of the command, so that devbox exits with
+// it.
+func (d *Devbox) RunServicesCI(
+	ctx context.Context,
+	runInCurrentShell bool,
+	requestedServices []string,
+	opts devopt.ServicesCIOpts,
+) error {
+	if len(opts.Command) == 0 {
+		return usererr.New("--ci needs a command to run, such as `devbox services up --ci -- make test`")
+	}
+	if _, err := useNativeSupervisor(opts.Supervisor); err != nil {
+		return err
+	}
+
+	if !runInCurrentShell {
+		args := []string{"up", "--run-in-current-shell", "--ci", "--timeout", opts.Timeout.String()}
+		if d.customProcessComposeFile != "" {
+			args = append(args, "--process-compose-file", d.customProcessComposeFile)
+		}
+		for _, flag := range opts.ExtraFlags {
+			args = append(args, "--pcflags", flag)
+		}
+		if opts.Supervisor != "" {
+			args = append(args, "--supervisor", opts.Supervisor)
+		}
+		args = append(args, requestedServices...)
+		args = append(args, "--")
+		args = append(args, opts.Command...)
+		return d.runDevboxServicesScript(ctx, args)
+	}
+
+	if services.ProcessManagerIsRunning(d.projectDir) {
+		return usererr.New("Process manager is already running. Run `devbox services stop` to stop it first.")
+	}
+	pcOpts := opts.ProcessComposeOpts
+	pcOpts.Background = true
+	if err := d.StartProcessManager(ctx, runInCurrentShell, requestedServices, pcOpts); err != nil {
+		return err
+	}
+
+	err := d.WaitForServices(ctx, opts.Timeout, requestedServices...)
+	if err == nil {
+		cmd := exec.CommandContext(ctx, opts.Command[0], opts.Command[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = usererr.NewExecError(cmd.Run())
+	}
+
+	// Use a new context so that services are still torn down if ctx was
+	// canceled.
+	stopCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if procs, listErr := services.ListServices(stopCtx, d.projectDir, d.stderr); listErr == nil {
+		services.PrintServiceLogs(stopCtx, d.stderr, d.projectDir, services.CrashedServices(procs))
+	}
+	if stopErr := services.StopProcessManagerAndWait(stopCtx, d.projectDir, d.stderr); err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+// WatchServices restarts services that have a watch option in devbox.json
+// when their files change, until ctx is done or the process manager stops.
+// It's for process managers started in the background, since `devbox
+// services up` watches files itself when it runs in the foreground.
+func (d *Devbox) WatchServices(ctx context.Context) error {
+	watches := d.serviceWatches(nil)
+	if len(watches) == 0 {
+		return usererr.New("No services in devbox.json have a watch option")
+	}
+	if !services.ProcessManagerIsRunning(d.projectDir) {
+		return usererr.New("Process manager is not running. Run `devbox services up --background` to start it.")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !services.ProcessManagerIsRunning(d.projectDir) {
+					fmt.Fprintln(d.stderr, "Process manager stopped, no longer watching files.")
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	names := lo.Keys(watches)
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(d.stderr, "Watching %s for service %s\n", strings.Join(watches[name].Paths, ", "), name)
+	}
+	return services.WatchServices(ctx, d.stderr, d.projectDir, watches)
+}
+
+// serviceWatches returns the watch options of services in devbox.json. If
+// serviceNames isn't empty, only those services are watched.
+func (d *Devbox) serviceWatches(serviceNames []string) map[string]services.WatchConfig {
+	watches := map[string]services.WatchConfig{}
+	for name, svc := range d.cfg.Services() {
+		if svc.Watch == nil || (len(serviceNames) > 0 && !slices.Contains(serviceNames, name)) {
+			continue
+		}
+		watches[name] = services.WatchConfig{
+			Paths:    svc.Watch.Paths,
+			Ignore:   svc.Watch.Ignore,
+			Debounce: time.Duration(svc.Watch.DebounceMs) * time.Millisecond,
+		}
+	}
+	return watches
+}
+
+// useNativeSupervisor reports whether services run with the built-in
+// supervisor instead of process-compose.
+func useNativeSupervisor(supervisor string) (bool, error) {
+	if supervisor == "" {
+		supervisor = os.Getenv(envir.DevboxServicesSupervisor)
+	}
+	switch supervisor {
+	case "", "process-compose":
+		return false, nil
+	case "native":
+		return true, nil
+	}
+	return false, usererr.New(
+		"Unknown services supervisor %q. It must be process-compose or native.", supervisor)
 }
 
 // runDevboxServicesScript invokes RunScript with the envOptions set to the appropriate
@@ -6,8 +6,12 @@ package devbox
 import (
 	"context"
 	"fmt"
+	"io"
+	"slices"
 
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
@@ -20,6 +24,12 @@ import (
 )
 
 func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
+	return d.withPluginHooks(ctx, func() error {
+		return d.updatePackages(ctx, opts)
+	}, plugin.OnUpdate, plugin.OnInstall)
+}
+
+func (d *Devbox) updatePackages(ctx context.Context, opts devopt.UpdateOpts) error {
 	inputs, err := d.inputsToUpdate(opts)
 	if err != nil {
 		return err
@@ -76,11 +86,94 @@ func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
 	_ = nix.FlakeUpdate(shellgen.FlakePath(d))
 
 	// fix any missing store paths.
-	if err = d.FixMissingStorePaths(ctx); err != nil {
-		return errors.WithStack(err)
+	return errors.WithStack(d.FixMissingStorePaths(ctx))
+}
+
+// UpdatePlugins updates included plugins that are pinned in devbox.lock,
+// such as plugins in GitHub repositories, to the latest commit of their ref
+// and locks their new content. names are plugin names or include
+// references. If names is empty, every pinned plugin is updated.
+func (d *Devbox) UpdatePlugins(ctx context.Context, names ...string) error {
+	pinned := map[string]plugin.Includable{}
+	for _, cfg := range d.cfg.IncludedPluginConfigs() {
+		if key := cfg.Source.LockfileKey(); d.lockfile.GetPlugin(key) != nil {
+			pinned[key] = cfg.Source
+		}
+	}
+
+	toUpdate := []string{}
+	if len(names) == 0 {
+		toUpdate = lo.Keys(pinned)
+	}
+	for _, name := range names {
+		found := false
+		for key, source := range pinned {
+			if name == key || name == source.CanonicalName() {
+				toUpdate = append(toUpdate, key)
+				found = true
+			}
+		}
+		if !found {
+			return usererr.New(
+				"No included plugin named %s is pinned in devbox.lock. Plugins in "+
+					"local directories aren't pinned, so they don't need to be updated.",
+				name,
+			)
+		}
+	}
+	if len(toUpdate) == 0 {
+		fmt.Fprintln(d.stderr, "No plugins to update.")
+		return nil
+	}
+	slices.Sort(toUpdate)
+	toUpdate = slices.Compact(toUpdate)
+
+	// Plugins might have been updated in memory when the project was opened,
+	// so compare against the pins on disk.
+	saved, err := lock.GetFile(d)
+	if err != nil {
+		return err
+	}
+	changed := []string{}
+	err = d.withPluginHooks(ctx, func() error {
+		d.pluginManager.Refetch().Add(toUpdate...)
+		if err := d.cfg.LoadRecursive(ctx, d.lockfile, d.pluginManager.Refetch()); err != nil {
+			return err
+		}
+		for _, key := range toUpdate {
+			old, updated := saved.GetPlugin(key), d.lockfile.GetPlugin(key)
+			if old != nil && updated != nil && *old == *updated {
+				fmt.Fprintf(d.stderr, "Plugin %s is up to date.\n", key)
+				continue
+			}
+			fmt.Fprintf(d.stderr, "Updated plugin %s: %s -> %s\n",
+				key, pluginPin(old), pluginPin(updated))
+			changed = append(changed, key)
+		}
+		return d.ensureStateIsUpToDate(ctx, update)
+	})
+	if err != nil {
+		return err
+	}
+
+	hooks := []pluginHook{}
+	for _, cfg := range d.cfg.IncludedPluginConfigs() {
+		if slices.Contains(changed, cfg.Source.LockfileKey()) {
+			hooks = append(hooks, pluginHook{plugin.OnUpdate, cfg})
+		}
 	}
+	return d.runPluginHooks(ctx, hooks)
+}
 
-	return plugin.Update()
+// pluginPin formats a plugin's pin in devbox.lock for messages.
+func pluginPin(p *lock.Plugin) string {
+	if p == nil {
+		return "unlocked"
+	}
+	if p.Rev == "" {
+		return p.Hash
+	}
+	return p.Rev
 }
 
 func (d *Devbox) inputsToUpdate(
@@ -103,6 +196,48 @@ func (d *Devbox) inputsToUpdate(
 	return pkgsToUpdate, nil
 }
 
+// UpdateDryRun resolves the latest version of each versioned package into a
+// copy of the lockfile and returns how the lockfile would change. Nothing is
+// installed and neither devbox.json nor devbox.lock is modified.
+func (d *Devbox) UpdateDryRun(ctx context.Context, opts devopt.UpdateOpts) (*lock.Diff, error) {
+	inputs, err := d.inputsToUpdate(opts)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := d.lockfile.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	// Discard the per-package messages. The diff replaces them.
+	quiet := *d
+	quiet.stderr = io.Discard
+	for _, pkg := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if pkg.IsLegacy() {
+			ux.Finfo(d.stderr, "Would update %s -> %s\n", pkg.Raw, pkg.LegacyToVersioned())
+			continue
+		}
+		if _, _, isVersioned := searcher.ParseVersionedPackage(pkg.Raw); !isVersioned {
+			ux.Finfo(d.stderr, "Would attempt to upgrade %s using `nix profile upgrade`\n", pkg.Raw)
+			continue
+		}
+		resolved, err := updated.FetchResolvedPackage(pkg.Raw)
+		if err != nil {
+			return nil, err
+		}
+		if resolved == nil {
+			continue
+		}
+		if err := quiet.mergeResolvedPackageToLockfile(pkg, resolved, updated); err != nil {
+			return nil, err
+		}
+	}
+	return lock.DiffFiles(d.lockfile, updated), nil
+}
+
 func (d *Devbox) updateDevboxPackage(pkg *devpkg.Package) error {
 	resolved, err := d.lockfile.FetchResolvedPackage(pkg.Raw)
 	if err != nil {
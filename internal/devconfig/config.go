@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
@@ -15,6 +17,7 @@ import (
 	"go.jetpack.io/devbox/internal/cachehash"
 	"go.jetpack.io/devbox/internal/devbox/shellcmd"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/plugin"
 )
@@ -26,6 +29,15 @@ type Config struct {
 	pluginData *plugin.PluginOnlyData // pointer by design, to allow for nil
 
 	included []*Config
+
+	// profileName and profile are only set on the root config. profile is nil
+	// if devbox.json doesn't define the selected profile.
+	profileName string
+	profile     *configfile.Profile
+
+	// builtins has the built-in plugins that packages activate, or is nil
+	// for the ones embedded in devbox.
+	builtins fs.FS
 }
 
 const defaultInitHook = "echo 'Welcome to devbox!' > /dev/null"
@@ -109,15 +121,24 @@ func loadBytes(b []byte) (*Config, error) {
 	}, nil
 }
 
-func (c *Config) LoadRecursive(lockfile *lock.File) error {
-	return c.loadRecursive(lockfile, map[string]bool{}, "" /*cyclePath*/)
+// LoadRecursive loads the plugins and devbox.json files that the config
+// includes. Plugins that devbox downloads are downloaded again if refetch
+// selects them, and downloads stop when ctx is done.
+func (c *Config) LoadRecursive(
+	ctx context.Context,
+	lockfile *lock.File,
+	refetch *plugin.Refetch,
+) error {
+	return c.loadRecursive(ctx, lockfile, refetch, map[string]bool{}, "" /*cyclePath*/)
 }
 
 // loadRecursive loads all the included plugins and their included plugins, etc.
 // seen should be a cloned map because loading plugins twice is allowed if they
 // are in different paths.
 func (c *Config) loadRecursive(
+	ctx context.Context,
 	lockfile *lock.File,
+	refetch *plugin.Refetch,
 	seen map[string]bool,
 	cyclePath string,
 ) error {
@@ -125,7 +146,7 @@ func (c *Config) loadRecursive(
 
 	for _, includeRef := range c.Root.Include {
 		pluginConfig, err := plugin.LoadConfigFromInclude(
-			includeRef, lockfile, filepath.Dir(c.Root.AbsRootPath))
+			ctx, includeRef, lockfile, refetch, filepath.Dir(c.Root.AbsRootPath))
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -141,9 +162,10 @@ func (c *Config) loadRecursive(
 		seen[pluginConfig.Source.Hash()] = true
 
 		includable := createIncludableFromPluginConfig(pluginConfig)
+		includable.builtins = c.builtins
 
 		if err := includable.loadRecursive(
-			lockfile, maps.Clone(seen), newCyclePath); err != nil {
+			ctx, lockfile, refetch, maps.Clone(seen), newCyclePath); err != nil {
 			return errors.WithStack(err)
 		}
 
@@ -151,8 +173,9 @@ func (c *Config) loadRecursive(
 	}
 
 	builtIns, err := plugin.GetBuiltinsForPackages(
-		c.Root.TopLevelPackages(),
+		c.topLevelPackages(true /*withProfile*/),
 		lockfile,
+		c.builtins,
 	)
 	if err != nil {
 		return errors.WithStack(err)
@@ -162,10 +185,11 @@ func (c *Config) loadRecursive(
 		includable := &Config{
 			Root:       builtIn.ConfigFile,
 			pluginData: &builtIn.PluginOnlyData,
+			builtins:   c.builtins,
 		}
 		newCyclePath := fmt.Sprintf("%s -> %s", cyclePath, builtIn.Source.LockfileKey())
 		if err := includable.loadRecursive(
-			lockfile, maps.Clone(seen), newCyclePath); err != nil {
+			ctx, lockfile, refetch, maps.Clone(seen), newCyclePath); err != nil {
 			return errors.WithStack(err)
 		}
 		included = append(included, includable)
@@ -175,6 +199,29 @@ func (c *Config) loadRecursive(
 	return nil
 }
 
+// SetBuiltinPlugins replaces the built-in plugins that packages activate with
+// the plugin.json files in fsys, such as php.json. It must be called before
+// LoadRecursive.
+func (c *Config) SetBuiltinPlugins(fsys fs.FS) {
+	c.builtins = fsys
+}
+
+// SetProfile selects the profile that is applied on top of the root config.
+// It's not an error to select a profile that devbox.json doesn't define; in
+// that case only the name is recorded. It must be called before
+// LoadRecursive so that the profile's packages activate their built-in
+// plugins.
+func (c *Config) SetProfile(name string) {
+	c.profileName = name
+	c.profile, _ = c.Root.Profile(name)
+}
+
+// Profile returns the name of the selected profile and its definition. The
+// definition is nil if devbox.json doesn't define the profile.
+func (c *Config) Profile() (string, *configfile.Profile) {
+	return c.profileName, c.profile
+}
+
 func (c *Config) PackageMutator() *configfile.PackagesMutator {
 	return &c.Root.PackagesMutator
 }
@@ -200,6 +247,31 @@ func (c *Config) IncludedPluginConfigs() []*plugin.Config {
 // original package.
 func (c *Config) Packages(
 	includeRemovedTriggerPackages bool,
+) []configfile.Package {
+	return c.packages(includeRemovedTriggerPackages, true /*withProfile*/)
+}
+
+// BasePackages is like Packages, but ignores the selected profile.
+func (c *Config) BasePackages(
+	includeRemovedTriggerPackages bool,
+) []configfile.Package {
+	return c.packages(includeRemovedTriggerPackages, false /*withProfile*/)
+}
+
+// topLevelPackages returns the packages of the root config. If withProfile is
+// true, the selected profile's packages replace the ones it removes.
+func (c *Config) topLevelPackages(withProfile bool) []configfile.Package {
+	packages := c.Root.TopLevelPackages()
+	if withProfile && c.profile != nil {
+		packages = slices.DeleteFunc(slices.Clone(packages), c.profile.Removes)
+		packages = append(packages, c.profile.Packages...)
+	}
+	return packages
+}
+
+func (c *Config) packages(
+	includeRemovedTriggerPackages bool,
+	withProfile bool,
 ) []configfile.Package {
 	packages := []configfile.Package{}
 	packagesToRemove := map[string]bool{}
@@ -211,9 +283,14 @@ func (c *Config) Packages(
 		}
 	}
 
+	// Profile packages are applied last so they replace base packages.
+	if withProfile && c.profile != nil {
+		packages = slices.DeleteFunc(packages, c.profile.Removes)
+	}
+
 	// Packages to remove in built ins only affect the devbox.json where they are defined.
 	// They should not remove packages that are part of other imports.
-	for _, pkg := range c.Root.TopLevelPackages() {
+	for _, pkg := range c.topLevelPackages(withProfile) {
 		if !packagesToRemove[pkg.VersionedName()] {
 			packages = append(packages, pkg)
 		}
@@ -226,6 +303,129 @@ func (c *Config) Packages(
 	))
 }
 
+// PackageSources maps the name of every package that comes from an included
+// devbox.json or plugin to a human readable reference of the file that
+// declared it. Local paths are relative to the project directory. Packages
+// declared in the project's own devbox.json are omitted.
+//
+// Precedence matches Packages: a package declared in a later include replaces
+// an earlier one, and the project's devbox.json replaces them all.
+func (c *Config) PackageSources() map[string]string {
+	names := func(pkgs []configfile.Package) []string {
+		return lo.Map(pkgs, func(p configfile.Package, _ int) string { return p.Name })
+	}
+	sources := c.sources(func(c *Config) []string {
+		return names(c.Root.TopLevelPackages())
+	})
+	if c.profile != nil {
+		c.addProfileSources(sources, names(c.profile.Packages))
+	}
+	return sources
+}
+
+// EnvSources is like PackageSources, but for the names of environment
+// variables in env.
+func (c *Config) EnvSources() map[string]string {
+	sources := c.sources(func(c *Config) []string { return lo.Keys(c.Root.Env) })
+	if c.profile != nil {
+		c.addProfileSources(sources, lo.Keys(c.profile.Env))
+	}
+	return sources
+}
+
+// ScriptSources is like PackageSources, but for the names of scripts,
+// including the <plugin>:<script> names of plugin scripts.
+func (c *Config) ScriptSources() map[string]string {
+	sources := c.sources(func(c *Config) []string {
+		scripts := c.Root.Scripts()
+		names := lo.Keys(scripts)
+		if prefix := plugin.ScriptPrefix(c.Root.Name); c.pluginData != nil && prefix != "" {
+			for name := range scripts {
+				names = append(names, prefix+name)
+			}
+		}
+		return names
+	})
+	if c.profile != nil {
+		c.addProfileSources(sources, lo.Keys(c.profile.Scripts()))
+	}
+	return sources
+}
+
+// InitHookSources returns the included devbox.json files and plugins that
+// have an init_hook, in the order that their init hooks run. The project's
+// own devbox.json is omitted.
+func (c *Config) InitHookSources() []string {
+	sources := c.initHookSources(filepath.Dir(c.Root.AbsRootPath))
+	if c.profile != nil && len(c.profile.InitHook().Cmds) > 0 {
+		sources = append(sources, "profile "+c.profileName)
+	}
+	return sources
+}
+
+func (c *Config) initHookSources(projectDir string) []string {
+	sources := []string{}
+	for _, i := range c.included {
+		sources = append(sources, i.initHookSources(projectDir)...)
+	}
+	if c.pluginData != nil && len(c.Root.InitHook().Cmds) > 0 {
+		sources = append(sources, c.sourceName(projectDir))
+	}
+	return sources
+}
+
+// sources maps the names that names returns for every included config to the
+// config that declared them. Like merging, later includes replace earlier
+// ones, and the project's devbox.json replaces them all.
+func (c *Config) sources(names func(c *Config) []string) map[string]string {
+	sources := map[string]string{}
+	c.addSources(sources, filepath.Dir(c.Root.AbsRootPath), names)
+	return sources
+}
+
+func (c *Config) addSources(
+	sources map[string]string,
+	projectDir string,
+	names func(c *Config) []string,
+) {
+	for _, i := range c.included {
+		i.addSources(sources, projectDir, names)
+	}
+	for _, name := range names(c) {
+		if c.pluginData == nil {
+			delete(sources, name)
+		} else {
+			sources[name] = c.sourceName(projectDir)
+		}
+	}
+}
+
+// addProfileSources records that names come from the selected profile, which
+// replaces the rest of the config.
+func (c *Config) addProfileSources(sources map[string]string, names []string) {
+	for _, name := range names {
+		sources[name] = "profile " + c.profileName
+	}
+}
+
+// sourceName returns the file or plugin reference this config was loaded
+// from.
+func (c *Config) sourceName(projectDir string) string {
+	if c.pluginData == nil {
+		return configfile.DefaultName
+	}
+	switch source := c.pluginData.Source.(type) {
+	case *plugin.LocalPlugin:
+		if rel, err := filepath.Rel(projectDir, source.Path()); err == nil {
+			return rel
+		}
+		return source.Path()
+	case *devpkg.Package:
+		return "plugin:" + source.CanonicalName()
+	}
+	return c.pluginData.Source.LockfileKey()
+}
+
 func (c *Config) NixPkgsCommitHash() string {
 	return c.Root.NixPkgsCommitHash()
 }
@@ -236,6 +436,9 @@ func (c *Config) Env() map[string]string {
 		maps.Copy(env, i.Env())
 	}
 	maps.Copy(env, c.Root.Env)
+	if c.profile != nil {
+		maps.Copy(env, c.profile.Env)
+	}
 	return env
 }
 
@@ -245,18 +448,55 @@ func (c *Config) InitHook() *shellcmd.Commands {
 		commands.Cmds = append(commands.Cmds, i.InitHook().Cmds...)
 	}
 	commands.Cmds = append(commands.Cmds, c.Root.InitHook().Cmds...)
+	if c.profile != nil {
+		commands.Cmds = append(commands.Cmds, c.profile.InitHook().Cmds...)
+	}
 	return &commands
 }
 
+// Scripts returns the scripts declared in the config and its includes. A
+// script in the root config replaces an included script with the same name.
+// Scripts from named plugins are also available as <plugin>:<script>, so they
+// can still be run when another config replaces the unqualified name.
 func (c *Config) Scripts() configfile.Scripts {
 	scripts := configfile.Scripts{}
 	for _, i := range c.included {
 		maps.Copy(scripts, i.Scripts())
 	}
+	if prefix := plugin.ScriptPrefix(c.Root.Name); c.pluginData != nil && prefix != "" {
+		for name, script := range c.Root.Scripts() {
+			scripts[prefix+name] = script
+		}
+	}
 	maps.Copy(scripts, c.Root.Scripts())
+	if c.profile != nil {
+		maps.Copy(scripts, c.profile.Scripts())
+	}
 	return scripts
 }
 
+// Services returns the services declared in the config and its includes. A
+// service in the root config replaces an included service with the same name.
+// Working directories are made absolute.
+func (c *Config) Services() map[string]*configfile.Service {
+	services := map[string]*configfile.Service{}
+	for _, i := range c.included {
+		maps.Copy(services, i.Services())
+	}
+	dir := ""
+	if c.Root.AbsRootPath != "" {
+		dir = filepath.Dir(c.Root.AbsRootPath)
+	}
+	for name, svc := range c.Root.Services {
+		svc := *svc
+		if dir != "" && !filepath.IsAbs(svc.WorkingDir) {
+			svc.WorkingDir = filepath.Join(dir, svc.WorkingDir)
+		}
+		services[name] = &svc
+	}
+	return services
+}
+
 func (c *Config) Hash() (string, error) {
 	data := []byte{}
 	for _, i := range c.included {
@@ -271,6 +511,11 @@ func (c *Config) Hash() (string, error) {
 		return "", err
 	}
 	data = append(data, hash...)
+	// The profile's definition is part of the root hash, but selecting a
+	// different profile must also change the hash.
+	if c.profile != nil {
+		data = append(data, c.profileName...)
+	}
 	return cachehash.Bytes(data), nil
 }
 
@@ -1,13 +1,19 @@
 package devconfig
 
 import (
+	"context"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/tailscale/hujson"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/searcher"
 )
 
 func TestDefault(t *testing.T) {
@@ -42,3 +48,247 @@ func TestDefault(t *testing.T) {
 		t.Errorf("got different JSON after load/save/load:\ninput:\n%s\noutput:\n%s", inBytes, outBytes)
 	}
 }
+
+type testProject struct{ dir string }
+
+func (p *testProject) ConfigHash() (string, error)                              { return "", nil }
+func (p *testProject) NixPkgsCommitHash() string                                { return "" }
+func (p *testProject) AllPackageNamesIncludingRemovedTriggerPackages() []string { return nil }
+func (p *testProject) ProjectDir() string                                       { return p.dir }
+func (p *testProject) ActiveProfile() *lock.Profile                             { return nil }
+func (p *testProject) Searcher() searcher.Searcher                              { return searcher.Client() }
+
+func TestIncludeDevboxJSON(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("base/devbox.json", `{
+		"packages": ["hello@latest", "jq@1.6"],
+		"env": {"FROM_BASE": "base", "OVERRIDDEN": "base"},
+		"shell": {
+			"init_hook": ["echo base"],
+			"scripts": {"lint": "echo base-lint", "test": "echo base-test"}
+		}
+	}`)
+	writeFile("project/devbox.json", `{
+		"packages": ["jq@1.7"],
+		"env": {"OVERRIDDEN": "project"},
+		"shell": {
+			"init_hook": ["echo project"],
+			"scripts": {"test": "echo project-test"}
+		},
+		"include": ["path:../base/devbox.json"]
+	}`)
+
+	cfg, err := LoadForTest(filepath.Join(root, "project", configfile.DefaultName))
+	if err != nil {
+		t.Fatal("got load error:", err)
+	}
+	lockfile, err := lock.GetFile(&testProject{dir: filepath.Join(root, "project")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadRecursive(context.Background(), lockfile, nil); err != nil {
+		t.Fatal("got LoadRecursive error:", err)
+	}
+
+	gotPackages := []string{}
+	for _, p := range cfg.Packages(false) {
+		gotPackages = append(gotPackages, p.VersionedName())
+	}
+	if diff := cmp.Diff([]string{"hello@latest", "jq@1.7"}, gotPackages); diff != "" {
+		t.Errorf("wrong packages (-want +got):\n%s", diff)
+	}
+	wantEnv := map[string]string{"FROM_BASE": "base", "OVERRIDDEN": "project"}
+	if diff := cmp.Diff(wantEnv, cfg.Env()); diff != "" {
+		t.Errorf("wrong env (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"echo base", "echo project"}, cfg.InitHook().Cmds); diff != "" {
+		t.Errorf("wrong init hook (-want +got):\n%s", diff)
+	}
+	scripts := cfg.Scripts()
+	if got := scripts["test"].String(); got != "echo project-test" {
+		t.Errorf("got test script %q, want %q", got, "echo project-test")
+	}
+	if got := scripts["lint"].String(); got != "echo base-lint" {
+		t.Errorf("got lint script %q, want %q", got, "echo base-lint")
+	}
+	wantSources := map[string]string{"hello": filepath.Join("..", "base", "devbox.json")}
+	if diff := cmp.Diff(wantSources, cfg.PackageSources()); diff != "" {
+		t.Errorf("wrong package sources (-want +got):\n%s", diff)
+	}
+	base := wantSources["hello"]
+	if diff := cmp.Diff(map[string]string{"FROM_BASE": base}, cfg.EnvSources()); diff != "" {
+		t.Errorf("wrong env sources (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"lint": base}, cfg.ScriptSources()); diff != "" {
+		t.Errorf("wrong script sources (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{base}, cfg.InitHookSources()); diff != "" {
+		t.Errorf("wrong init hook sources (-want +got):\n%s", diff)
+	}
+}
+
+func TestIncludeDevboxJSONCycle(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"a/devbox.json": `{"include": ["path:../b"]}`,
+		"b/devbox.json": `{"include": ["path:../a"]}`,
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := LoadForTest(filepath.Join(root, "a", configfile.DefaultName))
+	if err != nil {
+		t.Fatal("got load error:", err)
+	}
+	lockfile, err := lock.GetFile(&testProject{dir: filepath.Join(root, "a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.LoadRecursive(context.Background(), lockfile, nil)
+	if err == nil || !strings.Contains(err.Error(), "circular or duplicate include") {
+		t.Errorf("got error %v, want circular include error", err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), configfile.DefaultName)
+	err := os.WriteFile(path, []byte(`{
+		"packages": ["go@1.21", "nodejs@20", "jq@latest"],
+		"env": {"MODE": "base", "KEEP": "base"},
+		"shell": {
+			"init_hook": ["echo base"],
+			"scripts": {"test": "echo base-test"}
+		},
+		"profiles": {
+			"ci": {
+				"packages": {"go": "1.22", "chromium": "latest"},
+				"remove_packages": ["nodejs"],
+				"env": {"MODE": "ci"},
+				"shell": {
+					"init_hook": "echo ci",
+					"scripts": {"test": "echo ci-test"}
+				}
+			}
+		}
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadForTest(path)
+	if err != nil {
+		t.Fatal("got load error:", err)
+	}
+	baseHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.SetProfile("ci")
+	gotPackages := []string{}
+	for _, p := range cfg.Packages(false) {
+		gotPackages = append(gotPackages, p.VersionedName())
+	}
+	wantPackages := []string{"jq@latest", "go@1.22", "chromium@latest"}
+	if diff := cmp.Diff(wantPackages, gotPackages); diff != "" {
+		t.Errorf("wrong packages (-want +got):\n%s", diff)
+	}
+	if got := len(cfg.BasePackages(false)); got != 3 {
+		t.Errorf("got %d base packages, want 3", got)
+	}
+	wantEnv := map[string]string{"MODE": "ci", "KEEP": "base"}
+	if diff := cmp.Diff(wantEnv, cfg.Env()); diff != "" {
+		t.Errorf("wrong env (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"echo base", "echo ci"}, cfg.InitHook().Cmds); diff != "" {
+		t.Errorf("wrong init hook (-want +got):\n%s", diff)
+	}
+	if got := cfg.Scripts()["test"].String(); got != "echo ci-test" {
+		t.Errorf("got test script %q, want %q", got, "echo ci-test")
+	}
+	profileHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseHash == profileHash {
+		t.Error("got same hash with and without profile")
+	}
+
+	// Selecting an undefined profile leaves the config unchanged.
+	cfg.SetProfile("prod")
+	if got := len(cfg.Packages(false)); got != 3 {
+		t.Errorf("got %d packages for undefined profile, want 3", got)
+	}
+}
+
+func TestProfileBuiltinPlugin(t *testing.T) {
+	t.Setenv("__DEVBOX_NIX_SYSTEM", "x86_64-linux")
+	dir := t.TempDir()
+	path := filepath.Join(dir, configfile.DefaultName)
+	err := os.WriteFile(path, []byte(`{
+		"packages": ["jq@latest"],
+		"profiles": {"hello": {"packages": ["hello@latest"]}}
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockfile, err := lock.GetFile(&testProject{dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadForTest(path)
+	if err != nil {
+		t.Fatal("got load error:", err)
+	}
+	cfg.SetBuiltinPlugins(fstest.MapFS{"hello.json": {
+		Data: []byte(`{"name": "hello", "version": "1", "env": {"HELLO_PLUGIN": "1"}}`),
+	}})
+	if err := cfg.LoadRecursive(context.Background(), lockfile, nil); err != nil {
+		t.Fatal("got LoadRecursive error:", err)
+	}
+	if _, ok := cfg.Env()["HELLO_PLUGIN"]; ok {
+		t.Error("got plugin env without the profile")
+	}
+
+	cfg.SetProfile("hello")
+	if err := cfg.LoadRecursive(context.Background(), lockfile, nil); err != nil {
+		t.Fatal("got LoadRecursive error:", err)
+	}
+	if got := cfg.Env()["HELLO_PLUGIN"]; got != "1" {
+		t.Errorf("got HELLO_PLUGIN=%q with the profile, want %q", got, "1")
+	}
+}
+
+func TestProfileName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"ci":        true,
+		"my-ci_2":   true,
+		"../../x":   false,
+		"a/b":       false,
+		"with dot.": false,
+	} {
+		_, err := loadBytes([]byte(`{"profiles": {"` + name + `": {}}}`))
+		if valid && err != nil {
+			t.Errorf("got error for profile name %q: %v", name, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("got no error for invalid profile name %q", name)
+		}
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/cachehash"
 	"go.jetpack.io/devbox/internal/devbox/shellcmd"
+	"go.jetpack.io/devbox/internal/nix"
 )
 
 const (
@@ -48,13 +49,32 @@ type ConfigFile struct {
 	// Deprecated: Versioned packages don't need this
 	Nixpkgs *NixpkgsConfig `json:"nixpkgs,omitempty"`
 
-	// Reserved to allow including other config files. Proposed format is:
-	// path: for local files
-	// https:// for remote files
+	// Systems are the nix systems that `devbox lock` records in devbox.lock.
+	// If it's empty, only the current system is locked unless
+	// --all-systems is used.
+	Systems []string `json:"systems,omitempty"`
+
+	// Include lists other config files whose packages, env, init hooks and
+	// scripts are merged into this one. Supported formats are:
+	// path: for local plugin.json or devbox.json files (or their directories)
+	// github: for plugins hosted on GitHub
 	// plugin: for built-in plugins
 	// This is a similar format to nix inputs
 	Include []string `json:"include,omitempty"`
 
+	// Services are long-running processes that `devbox services` manages,
+	// keyed by name. They're merged with services from plugins and
+	// process-compose.yaml.
+	Services map[string]*Service `json:"services,omitempty"`
+
+	// Profiles are named variations of this config, keyed by name. A profile
+	// is selected with the --environment flag.
+	Profiles map[string]*Profile `json:"profiles,omitempty"`
+
+	// Search selects a local index of nixpkgs for package search and version
+	// resolution instead of the Devbox search API.
+	Search *SearchConfig `json:"search,omitempty"`
+
 	ast *configAST
 }
 
@@ -68,6 +88,17 @@ type NixpkgsConfig struct {
 	Commit string `json:"commit,omitempty"`
 }
 
+type SearchConfig struct {
+	// Nixpkgs is a nixpkgs flake reference, or a path to a nixpkgs checkout
+	// relative to devbox.json.
+	Nixpkgs string `json:"nixpkgs,omitempty"`
+
+	// Dump is an optional path to the output of `nix search --json` for
+	// Nixpkgs, relative to devbox.json. It avoids evaluating all of nixpkgs
+	// to build the index.
+	Dump string `json:"dump,omitempty"`
+}
+
 // Stage contains a subset of fields from plansdk.Stage
 type Stage struct {
 	Command string `json:"command"`
@@ -155,6 +186,10 @@ func validateConfig(cfg *ConfigFile) error {
 	fns := []func(cfg *ConfigFile) error{
 		ValidateNixpkg,
 		validateScripts,
+		validateServices,
+		validateProfiles,
+		validateSystems,
+		validateSearch,
 	}
 
 	for _, fn := range fns {
@@ -165,10 +200,24 @@ func validateConfig(cfg *ConfigFile) error {
 	return nil
 }
 
+func validateSearch(cfg *ConfigFile) error {
+	if cfg.Search != nil && cfg.Search.Dump != "" && cfg.Search.Nixpkgs == "" {
+		return errors.New("search.dump in devbox.json requires search.nixpkgs")
+	}
+	return nil
+}
+
+func validateSystems(cfg *ConfigFile) error {
+	return nix.EnsureValidPlatform(cfg.Systems...)
+}
+
 var whitespace = regexp.MustCompile(`\s`)
 
 func validateScripts(cfg *ConfigFile) error {
-	scripts := cfg.Scripts()
+	return validateScriptMap(cfg.Scripts())
+}
+
+func validateScriptMap(scripts Scripts) error {
 	for k := range scripts {
 		if strings.TrimSpace(k) == "" {
 			return errors.New("cannot have script with empty name in devbox.json")
@@ -710,3 +710,55 @@ func TestNixpkgsValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestServicesValidation(t *testing.T) {
+	testCases := map[string]struct {
+		json     string
+		isErrant bool
+	}{
+		"valid": {`{"services": {"web": {"command": "npm start", "restart": "always",
+			"readiness_probe": {"http": "http://localhost:3000"}}}}`, false},
+		"missing_command":  {`{"services": {"web": {"working_dir": "app"}}}`, true},
+		"name_whitespace":  {`{"services": {"my web": {"command": "npm start"}}}`, true},
+		"invalid_restart":  {`{"services": {"web": {"command": "npm start", "restart": "sometimes"}}}`, true},
+		"two_probes":       {`{"services": {"web": {"command": "npm start", "readiness_probe": {"exec": "true", "log_line": "ready"}}}}`, true},
+		"invalid_http_url": {`{"services": {"web": {"command": "npm start", "readiness_probe": {"http": "localhost:3000"}}}}`, true},
+		"valid_watch":      {`{"services": {"web": {"command": "go run .", "watch": {"paths": ["**/*.go"], "ignore": ["**/*_test.go"], "debounce_ms": 200}}}}`, false},
+		"watch_no_paths":   {`{"services": {"web": {"command": "go run .", "watch": {"ignore": ["vendor/**"]}}}}`, true},
+		"watch_bad_glob":   {`{"services": {"web": {"command": "go run .", "watch": {"paths": ["src/[a-"]}}}}`, true},
+		"watch_abs_path":   {`{"services": {"web": {"command": "go run .", "watch": {"paths": ["/etc/**"]}}}}`, true},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadBytes([]byte(testCase.json))
+			if testCase.isErrant {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSystemsValidation(t *testing.T) {
+	testCases := map[string]struct {
+		json     string
+		isErrant bool
+	}{
+		"valid":   {`{"systems": ["x86_64-linux", "aarch64-darwin"]}`, false},
+		"empty":   {`{"systems": []}`, false},
+		"invalid": {`{"systems": ["x86_64-windows"]}`, true},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadBytes([]byte(testCase.json))
+			if testCase.isErrant {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -125,11 +125,21 @@ func (pkgs *PackagesMutator) ExcludePlatforms(writer io.Writer, versionedName st
 }
 
 func (pkgs *PackagesMutator) UnmarshalJSON(data []byte) error {
+	collection, err := unmarshalPackages(data)
+	if err != nil {
+		return err
+	}
+	pkgs.collection = collection
+	return nil
+}
+
+// unmarshalPackages parses packages from either a list of versioned names or
+// a map of package definitions.
+func unmarshalPackages(data []byte) ([]Package, error) {
 	// First, attempt to unmarshal as a list of strings (legacy format)
 	var packages []string
 	if err := json.Unmarshal(data, &packages); err == nil {
-		pkgs.collection = packagesFromLegacyList(packages)
-		return nil
+		return packagesFromLegacyList(packages), nil
 	}
 
 	// Second, attempt to unmarshal as a map of Packages
@@ -139,7 +149,7 @@ func (pkgs *PackagesMutator) UnmarshalJSON(data []byte) error {
 	orderedMap := orderedmap.New[string, Package]()
 	err := json.Unmarshal(data, &orderedMap)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 
 	// Convert the ordered map to a list of packages, and set the name field
@@ -150,8 +160,7 @@ func (pkgs *PackagesMutator) UnmarshalJSON(data []byte) error {
 		pkg.Name = pair.Key
 		packagesList = append(packagesList, pkg)
 	}
-	pkgs.collection = packagesList
-	return nil
+	return packagesList, nil
 }
 
 func (pkgs *PackagesMutator) SetPatchGLibc(versionedName string, v bool) error {
@@ -264,7 +273,11 @@ func NewVersionOnlyPackage(name, version string) Package {
 // If the package has a list of excluded platforms, it is enabled on all platforms
 // except those.
 func (p *Package) IsEnabledOnPlatform() bool {
-	platform := nix.System()
+	return p.IsEnabledOnSystem(nix.System())
+}
+
+// IsEnabledOnSystem is like IsEnabledOnPlatform, but for any nix system.
+func (p *Package) IsEnabledOnSystem(platform string) bool {
 	if len(p.Platforms) > 0 {
 		for _, plt := range p.Platforms {
 			if plt == platform {
@@ -0,0 +1,100 @@
+package configfile
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/devbox/shellcmd"
+)
+
+// Profile is a named set of changes that is applied on top of the rest of
+// the config when selected with the --environment flag.
+type Profile struct {
+	// Packages are added to the environment. A package with the same name as
+	// a package in the base config replaces it.
+	Packages ProfilePackages `json:"packages,omitempty"`
+
+	// RemovePackages lists packages, by name, that the profile removes from
+	// the base config.
+	RemovePackages []string `json:"remove_packages,omitempty"`
+
+	// Env overrides env variables from the base config.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Shell commands in the init hook run after the base init hook. Scripts
+	// replace base scripts with the same name.
+	Shell *shellConfig `json:"shell,omitempty"`
+
+	// Secrets is the secrets environment (dev, prod or preview) that the
+	// profile uses. Profiles without it use the dev secrets.
+	Secrets string `json:"secrets,omitempty"`
+}
+
+// ProfilePackages is a read-only list of packages that accepts the same
+// formats as the top level packages field.
+type ProfilePackages []Package
+
+func (p *ProfilePackages) UnmarshalJSON(data []byte) error {
+	packages, err := unmarshalPackages(data)
+	if err != nil {
+		return err
+	}
+	*p = packages
+	return nil
+}
+
+// Profile returns the profile with the given name, if devbox.json defines it.
+func (c *ConfigFile) Profile(name string) (*Profile, bool) {
+	profile, ok := c.Profiles[name]
+	return profile, ok && profile != nil
+}
+
+func (p *Profile) InitHook() *shellcmd.Commands {
+	if p == nil || p.Shell == nil || p.Shell.InitHook == nil {
+		return &shellcmd.Commands{}
+	}
+	return p.Shell.InitHook
+}
+
+func (p *Profile) Scripts() Scripts {
+	if p == nil || p.Shell == nil {
+		return nil
+	}
+	result := make(Scripts, len(p.Shell.Scripts))
+	for name, commands := range p.Shell.Scripts {
+		result[name] = &script{Commands: *commands}
+	}
+	return result
+}
+
+// Removes returns true if the profile removes pkg from the base config.
+func (p *Profile) Removes(pkg Package) bool {
+	for _, name := range p.RemovePackages {
+		if name == pkg.Name || name == pkg.VersionedName() {
+			return true
+		}
+	}
+	return false
+}
+
+// profileNameRegex matches valid profile names. Profile names are used in
+// file names, such as .devbox/state.<profile>.json.
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func validateProfiles(cfg *ConfigFile) error {
+	for name, profile := range cfg.Profiles {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("cannot have profile with empty name in devbox.json")
+		}
+		if !profileNameRegex.MatchString(name) {
+			return errors.Errorf(
+				"invalid profile name in devbox.json: %q. Profile names can only "+
+					"contain letters, numbers, - and _", name)
+		}
+		if err := validateScriptMap(profile.Scripts()); err != nil {
+			return errors.Wrapf(err, "profile %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/pkg/errors"
+)
+
+// Service is a long-running process, such as a web server or a database,
+// that `devbox services` runs with process-compose.
+type Service struct {
+	// Command runs in the devbox environment with the project's shell.
+	Command string `json:"command"`
+
+	// WorkingDir is relative to the directory containing the config file.
+	// It defaults to that directory.
+	WorkingDir string `json:"working_dir,omitempty"`
+
+	// Env is added to the devbox environment for this service only.
+	Env map[string]string `json:"env,omitempty"`
+
+	// DependsOn lists services that must start before this one. A dependency
+	// with a readiness probe must also be ready.
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	ReadinessProbe *ReadinessProbe `json:"readiness_probe,omitempty"`
+
+	// Restart is the restart policy: "always", "on_failure", or "no". The
+	// default is "no".
+	Restart string `json:"restart,omitempty"`
+
+	Watch *Watch `json:"watch,omitempty"`
+}
+
+// Watch restarts a service when files that it uses change, such as the
+// source files of a web server.
+type Watch struct {
+	// Paths are glob patterns relative to the project directory, such as
+	// src/**/*.go. A `**` matches any number of directories.
+	Paths []string `json:"paths"`
+
+	// Ignore are glob patterns for files that don't restart the service,
+	// even if they match Paths.
+	Ignore []string `json:"ignore,omitempty"`
+
+	// DebounceMs is how long to wait after the last change before
+	// restarting, so that saving many files restarts the service once. The
+	// default is 500.
+	DebounceMs int `json:"debounce_ms,omitempty"`
+}
+
+// ReadinessProbe tells process-compose how to check that a service is ready.
+// Exactly one of Exec, HTTP, or LogLine must be set.
+type ReadinessProbe struct {
+	// Exec is a command that succeeds when the service is ready.
+	Exec string `json:"exec,omitempty"`
+
+	// HTTP is a URL that returns a successful status when the service is
+	// ready, such as http://localhost:8000/health.
+	HTTP string `json:"http,omitempty"`
+
+	// LogLine is a regular expression that matches a line that the service
+	// logs when it's ready.
+	LogLine string `json:"log_line,omitempty"`
+
+	InitialDelaySeconds int `json:"initial_delay_seconds,omitempty"`
+	PeriodSeconds       int `json:"period_seconds,omitempty"`
+	TimeoutSeconds      int `json:"timeout_seconds,omitempty"`
+	FailureThreshold    int `json:"failure_threshold,omitempty"`
+}
+
+func validateServices(cfg *ConfigFile) error {
+	for name, svc := range cfg.Services {
+		if strings.TrimSpace(name) == "" || whitespace.MatchString(name) {
+			return errors.Errorf("invalid service name in devbox.json: %q", name)
+		}
+		if svc == nil || strings.TrimSpace(svc.Command) == "" {
+			return errors.Errorf("service %s in devbox.json must have a command", name)
+		}
+		switch svc.Restart {
+		case "", "always", "on_failure", "no":
+		default:
+			return errors.Errorf(
+				"service %s in devbox.json has invalid restart policy %q, "+
+					"must be always, on_failure, or no", name, svc.Restart)
+		}
+		if err := svc.ReadinessProbe.validate(); err != nil {
+			return errors.Wrapf(err, "service %s in devbox.json", name)
+		}
+		if err := svc.Watch.validate(); err != nil {
+			return errors.Wrapf(err, "service %s in devbox.json", name)
+		}
+	}
+	return nil
+}
+
+func (p *ReadinessProbe) validate() error {
+	if p == nil {
+		return nil
+	}
+	set := 0
+	for _, v := range []string{p.Exec, p.HTTP, p.LogLine} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("readiness_probe must have exactly one of exec, http, or log_line")
+	}
+	if p.HTTP != "" {
+		u, err := url.Parse(p.HTTP)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return errors.Errorf("readiness_probe.http must be an http or https URL, got %q", p.HTTP)
+		}
+	}
+	return nil
+}
+
+func (w *Watch) validate() error {
+	if w == nil {
+		return nil
+	}
+	if len(w.Paths) == 0 {
+		return errors.New("watch must have at least one path")
+	}
+	for _, pattern := range append(slices.Clone(w.Paths), w.Ignore...) {
+		if !doublestar.ValidatePattern(pattern) || path.IsAbs(pattern) {
+			return errors.Errorf("watch has invalid pattern %q, must be a glob relative to the project", pattern)
+		}
+	}
+	if w.DebounceMs < 0 {
+		return errors.New("watch.debounce_ms must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devconfig
+
+import (
+	"path/filepath"
+
+	"go.jetpack.io/devbox/internal/devpkg"
+)
+
+// PackageTree is a devbox.json or plugin in the tree of configs that declare
+// a project's packages.
+type PackageTree struct {
+	// Source is the file or plugin reference that the config was loaded
+	// from, such as devbox.json, ../base/devbox.json or plugin:php.
+	Source string `json:"source" yaml:"source"`
+
+	// Plugin is the name of the plugin, if the config is a named plugin.
+	Plugin string `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+
+	// Packages are the packages that the config declares.
+	Packages []*PackageTreeItem `json:"packages,omitempty" yaml:"packages,omitempty"`
+
+	// Includes are the plugins and devbox.json files in the config's
+	// include field, and the project's selected profile.
+	Includes []*PackageTree `json:"includes,omitempty" yaml:"includes,omitempty"`
+}
+
+// PackageTreeItem is a package in a PackageTree.
+type PackageTreeItem struct {
+	// Name is the package as it's written in the config, such as go@1.22.
+	Name string `json:"name" yaml:"name"`
+
+	// ReplacedBy is set when the project doesn't use this package. It's the
+	// package that the project uses instead, or the plugin or profile that
+	// removed it.
+	ReplacedBy string `json:"replaced_by,omitempty" yaml:"replaced_by,omitempty"`
+
+	// Plugins are the built-in plugins that the package activates.
+	Plugins []*PackageTree `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+}
+
+// PackageTree returns the packages of the config and everything it includes,
+// grouped by the devbox.json or plugin that declared them. Built-in plugins
+// are listed under the package that activated them.
+func (c *Config) PackageTree() *PackageTree {
+	used := map[string]string{}
+	for _, pkg := range c.Packages(true /*includeRemovedTriggerPackages*/) {
+		used[pkg.Name] = pkg.VersionedName()
+	}
+	return c.packageTree(used, filepath.Dir(c.Root.AbsRootPath))
+}
+
+// packageTree builds the tree of c. used maps the name of every package that
+// the project uses to its versioned name.
+func (c *Config) packageTree(used map[string]string, projectDir string) *PackageTree {
+	tree := &PackageTree{Source: c.sourceName(projectDir)}
+	if c.pluginData != nil {
+		tree.Plugin = c.Root.Name
+	}
+
+	builtIns := map[string][]*PackageTree{}
+	replaced := map[string]string{}
+	for _, i := range c.included {
+		child := i.packageTree(used, projectDir)
+		trigger, ok := i.pluginData.Source.(*devpkg.Package)
+		if !ok {
+			tree.Includes = append(tree.Includes, child)
+			continue
+		}
+		builtIns[trigger.LockfileKey()] = append(builtIns[trigger.LockfileKey()], child)
+		if i.pluginData.RemoveTriggerPackage {
+			replaced[trigger.LockfileKey()] = "plugin " + i.Root.Name
+		}
+	}
+
+	removedBy := ""
+	if c.profile != nil {
+		removedBy = "profile " + c.profileName
+	}
+	for _, pkg := range c.Root.TopLevelPackages() {
+		item := &PackageTreeItem{
+			Name:       pkg.VersionedName(),
+			ReplacedBy: replaced[pkg.VersionedName()],
+			Plugins:    builtIns[pkg.VersionedName()],
+		}
+		if item.ReplacedBy == "" {
+			item.ReplacedBy = replacedBy(pkg.Name, item.Name, used, removedBy)
+		}
+		tree.Packages = append(tree.Packages, item)
+	}
+
+	if c.profile != nil && len(c.profile.Packages) > 0 {
+		profile := &PackageTree{Source: "profile " + c.profileName}
+		for _, pkg := range c.profile.Packages {
+			item := &PackageTreeItem{
+				Name:       pkg.VersionedName(),
+				ReplacedBy: replaced[pkg.VersionedName()],
+				Plugins:    builtIns[pkg.VersionedName()],
+			}
+			if item.ReplacedBy == "" {
+				item.ReplacedBy = replacedBy(pkg.Name, item.Name, used, removedBy)
+			}
+			profile.Packages = append(profile.Packages, item)
+		}
+		tree.Includes = append(tree.Includes, profile)
+	}
+	return tree
+}
+
+// replacedBy returns the package that the project uses instead of
+// versionedName, or removedBy if the project doesn't use any version of the
+// package. It returns an empty string if the project uses versionedName.
+func replacedBy(name, versionedName string, used map[string]string, removedBy string) string {
+	usedName, ok := used[name]
+	if !ok {
+		return removedBy
+	}
+	if usedName == versionedName {
+		return ""
+	}
+	return usedName
+}
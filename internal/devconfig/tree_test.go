@@ -0,0 +1,110 @@
+package devconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestPluginPackagesAndScripts(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"project/devbox.json": `{
+			"packages": ["jq@1.7", "go@1.22"],
+			"shell": {"scripts": {"setup": "echo project-setup"}},
+			"include": ["path:./tools/plugin.json"],
+			"profiles": {"ci": {"packages": ["jq@1.6"], "remove_packages": ["go"]}}
+		}`,
+		"project/tools/plugin.json": `{
+			"name": "tools",
+			"version": "0.0.1",
+			"packages": ["jq@1.6", "ripgrep@latest"],
+			"shell": {"scripts": {"setup": "echo tools-setup", "lint": "echo tools-lint"}},
+			"include": ["path:../../base/devbox.json"]
+		}`,
+		"base/devbox.json": `{
+			"packages": ["hello@latest"],
+			"shell": {"scripts": {"greet": "echo base-greet"}}
+		}`,
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := LoadForTest(filepath.Join(root, "project", configfile.DefaultName))
+	if err != nil {
+		t.Fatal("got load error:", err)
+	}
+	lockfile, err := lock.GetFile(&testProject{dir: filepath.Join(root, "project")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadRecursive(context.Background(), lockfile, nil); err != nil {
+		t.Fatal("got LoadRecursive error:", err)
+	}
+
+	// Plugin scripts can always be run by their qualified name, but the
+	// project replaces unqualified names. Included devbox.json files aren't
+	// named, so their scripts aren't qualified.
+	gotScripts := map[string]string{}
+	for name, script := range cfg.Scripts() {
+		gotScripts[name] = script.String()
+	}
+	wantScripts := map[string]string{
+		"setup":       "echo project-setup",
+		"lint":        "echo tools-lint",
+		"greet":       "echo base-greet",
+		"tools:setup": "echo tools-setup",
+		"tools:lint":  "echo tools-lint",
+	}
+	if diff := cmp.Diff(wantScripts, gotScripts); diff != "" {
+		t.Errorf("wrong scripts (-want +got):\n%s", diff)
+	}
+
+	// Packages from plugins are included transitively.
+	gotPackages := []string{}
+	for _, p := range cfg.Packages(false) {
+		gotPackages = append(gotPackages, p.VersionedName())
+	}
+	wantPackages := []string{"hello@latest", "ripgrep@latest", "jq@1.7", "go@1.22"}
+	if diff := cmp.Diff(wantPackages, gotPackages); diff != "" {
+		t.Errorf("wrong packages (-want +got):\n%s", diff)
+	}
+
+	cfg.SetProfile("ci")
+	wantTree := &PackageTree{
+		Source: "devbox.json",
+		Packages: []*PackageTreeItem{
+			{Name: "jq@1.7", ReplacedBy: "jq@1.6"},
+			{Name: "go@1.22", ReplacedBy: "profile ci"},
+		},
+		Includes: []*PackageTree{
+			{
+				Source: filepath.Join("tools", "plugin.json"),
+				Plugin: "tools",
+				Packages: []*PackageTreeItem{
+					{Name: "jq@1.6"},
+					{Name: "ripgrep@latest"},
+				},
+				Includes: []*PackageTree{{
+					Source:   filepath.Join("..", "base", "devbox.json"),
+					Packages: []*PackageTreeItem{{Name: "hello@latest"}},
+				}},
+			},
+			{Source: "profile ci", Packages: []*PackageTreeItem{{Name: "jq@1.6"}}},
+		},
+	}
+	if diff := cmp.Diff(wantTree, cfg.PackageTree()); diff != "" {
+		t.Errorf("wrong package tree (-want +got):\n%s", diff)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/devbox/providers/nixcache"
+	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/goutil"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
@@ -25,6 +26,10 @@ import (
 // It is used as FromStore in builtins.fetchClosure.
 const binaryCache = "https://cache.nixos.org"
 
+// localStore is the cache URI of outputs that are already in the local nix
+// store. It's only used in offline mode.
+const localStore = "local"
+
 // useDefaultOutputs is a special value for the outputName parameter of
 // fetchNarInfoStatusOnce, which indicates that the default outputs should be
 // used.
@@ -132,13 +137,16 @@ func (p *Package) fetchNarInfoStatusOnce(
 ) (map[string]string, error) {
 	ctx := context.TODO()
 
-	outputToCache := map[string]string{}
-	caches, err := readCaches(ctx)
+	outputs, err := p.outputsForOutputName(outputName)
 	if err != nil {
 		return nil, err
 	}
+	if envir.IsOffline() {
+		return outputsInLocalStore(ctx, outputs)
+	}
 
-	outputs, err := p.outputsForOutputName(outputName)
+	outputToCache := map[string]string{}
+	caches, err := readCaches(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -170,6 +178,27 @@ func (p *Package) fetchNarInfoStatusOnce(
 	return outputToCache, nil
 }
 
+// outputsInLocalStore is the offline counterpart of fetchNarInfoStatusOnce. It
+// treats the local nix store as the only cache, so that packages that are
+// already in the store are installed from their store paths.
+func outputsInLocalStore(ctx context.Context, outputs []lock.Output) (map[string]string, error) {
+	paths := make([]string, len(outputs))
+	for i, output := range outputs {
+		paths[i] = output.Path
+	}
+	inStore, err := nix.StorePathsAreInStore(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	outputToCache := map[string]string{}
+	for _, output := range outputs {
+		if inStore[output.Path] {
+			outputToCache[output.Name] = localStore
+		}
+	}
+	return outputToCache, nil
+}
+
 func (p *Package) AreAllOutputsInCache(
 	ctx context.Context, w io.Writer, cacheURI string,
 ) (bool, error) {
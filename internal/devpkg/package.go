@@ -297,6 +297,12 @@ func (p *Package) FlakeInstallable() (flake.Installable, error) {
 	return flake.ParseInstallable(p.Raw)
 }
 
+// InstallableForEval returns the installable that nix evaluates for the
+// package. Unlike Raw, relative flake paths are made absolute.
+func (p *Package) InstallableForEval() (string, error) {
+	return p.urlForInstall()
+}
+
 // urlForInstall is used during `nix profile install`.
 // The key difference with URLForFlakeInput is that it has a suffix of
 // `#attributePath`
@@ -9,12 +9,18 @@ const (
 	DevboxGateway       = "DEVBOX_GATEWAY"
 	// DevboxLatestVersion is the latest version available of the devbox CLI binary.
 	// NOTE: it should NOT start with v (like 0.4.8)
-	DevboxLatestVersion  = "DEVBOX_LATEST_VERSION"
-	DevboxRegion         = "DEVBOX_REGION"
-	DevboxSearchHost     = "DEVBOX_SEARCH_HOST"
-	DevboxShellEnabled   = "DEVBOX_SHELL_ENABLED"
-	DevboxShellStartTime = "DEVBOX_SHELL_START_TIME"
-	DevboxVM             = "DEVBOX_VM"
+	DevboxLatestVersion = "DEVBOX_LATEST_VERSION"
+	DevboxOffline       = "DEVBOX_OFFLINE"
+	DevboxRegion        = "DEVBOX_REGION"
+	DevboxSearchDump    = "DEVBOX_SEARCH_DUMP"
+	DevboxSearchHost    = "DEVBOX_SEARCH_HOST"
+	DevboxSearchNixpkgs = "DEVBOX_SEARCH_NIXPKGS"
+	// DevboxServicesSupervisor selects what runs services when the
+	// --supervisor flag isn't set: process-compose (the default) or native.
+	DevboxServicesSupervisor = "DEVBOX_SERVICES_SUPERVISOR"
+	DevboxShellEnabled       = "DEVBOX_SHELL_ENABLED"
+	DevboxShellStartTime     = "DEVBOX_SHELL_START_TIME"
+	DevboxVM                 = "DEVBOX_VM"
 
 	LauncherVersion = "LAUNCHER_VERSION"
 	LauncherPath    = "LAUNCHER_PATH"
@@ -19,6 +19,13 @@ func IsDevboxShellEnabled() bool {
 	return inDevboxShell
 }
 
+// IsOffline returns true if devbox must not access the network and should
+// rely solely on devbox.lock and the local nix store.
+func IsOffline() bool {
+	offline, _ := strconv.ParseBool(os.Getenv(DevboxOffline))
+	return offline
+}
+
 func DoNotTrack() bool {
 	// https://consoledonottrack.com/
 	doNotTrack, _ := strconv.ParseBool(os.Getenv("DO_NOT_TRACK"))
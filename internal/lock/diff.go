@@ -0,0 +1,243 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/cuecfg"
+)
+
+// DiffKind describes how a lockfile entry changed.
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// Diff is the difference between two lockfiles.
+type Diff struct {
+	Packages []PackageDiff `json:"packages" yaml:"packages"`
+}
+
+// PackageDiff is the difference between two lockfile entries for the same
+// package.
+type PackageDiff struct {
+	Package string   `json:"package" yaml:"package"`
+	Kind    DiffKind `json:"kind" yaml:"kind"`
+
+	// Profile is the devbox.json profile whose lockfile section has the
+	// package. It's empty for packages in the main section.
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+
+	OldVersion  string `json:"old_version,omitempty" yaml:"old_version,omitempty"`
+	NewVersion  string `json:"new_version,omitempty" yaml:"new_version,omitempty"`
+	OldResolved string `json:"old_resolved,omitempty" yaml:"old_resolved,omitempty"`
+	NewResolved string `json:"new_resolved,omitempty" yaml:"new_resolved,omitempty"`
+
+	// Systems are the systems whose outputs changed, sorted by name.
+	Systems []SystemDiff `json:"systems,omitempty" yaml:"systems,omitempty"`
+}
+
+// SystemDiff is the difference between the outputs of a package for a single
+// system.
+type SystemDiff struct {
+	System  string         `json:"system" yaml:"system"`
+	Added   []Output       `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed []Output       `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Changed []OutputChange `json:"changed,omitempty" yaml:"changed,omitempty"`
+}
+
+// OutputChange is an output whose store path changed.
+type OutputChange struct {
+	Name    string `json:"name" yaml:"name"`
+	OldPath string `json:"old_path" yaml:"old_path"`
+	NewPath string `json:"new_path" yaml:"new_path"`
+}
+
+// Parse parses the contents of a devbox.lock file. Empty data is an empty
+// lockfile. The returned File isn't associated with a project, so it can only
+// be read or diffed.
+func Parse(data []byte) (*File, error) {
+	f := &File{Packages: map[string]*Package{}}
+	if len(data) == 0 {
+		return f, nil
+	}
+	if err := cuecfg.Unmarshal(data, ".lock", f); err != nil {
+		return nil, err
+	}
+	ensurePackagesHaveOutputs(f.Packages)
+	for _, section := range f.Profiles {
+		ensurePackagesHaveOutputs(section.Packages)
+	}
+	return f, nil
+}
+
+// DiffFiles compares two lockfiles and returns the entries that were added,
+// removed, or changed from before to after. Either file may be nil, which is
+// the same as an empty lockfile.
+func DiffFiles(before, after *File) *Diff {
+	diff := &Diff{Packages: []PackageDiff{}}
+	diff.Packages = append(diff.Packages, diffPackages("", packagesOf(before), packagesOf(after))...)
+
+	profiles := lo.Uniq(append(lo.Keys(profilesOf(before)), lo.Keys(profilesOf(after))...))
+	slices.Sort(profiles)
+	for _, profile := range profiles {
+		diff.Packages = append(diff.Packages, diffPackages(
+			profile,
+			profilePackagesOf(before, profile),
+			profilePackagesOf(after, profile),
+		)...)
+	}
+	return diff
+}
+
+// Empty returns true if the lockfiles are the same.
+func (d *Diff) Empty() bool {
+	return len(d.Packages) == 0
+}
+
+// Print writes a human-readable summary of d to w.
+func (d *Diff) Print(w io.Writer) {
+	if d.Empty() {
+		fmt.Fprintln(w, "No changes to devbox.lock.")
+		return
+	}
+	for _, pkg := range d.Packages {
+		name := pkg.Package
+		if pkg.Profile != "" {
+			name += fmt.Sprintf(" (profile %s)", pkg.Profile)
+		}
+		switch pkg.Kind {
+		case DiffAdded:
+			fmt.Fprintf(w, "+ %s %s\n", name, pkg.NewVersion)
+		case DiffRemoved:
+			fmt.Fprintf(w, "- %s %s\n", name, pkg.OldVersion)
+		case DiffChanged:
+			if pkg.OldVersion != pkg.NewVersion {
+				fmt.Fprintf(w, "~ %s %s -> %s\n", name, pkg.OldVersion, pkg.NewVersion)
+			} else {
+				fmt.Fprintf(w, "~ %s %s\n", name, pkg.NewVersion)
+			}
+		}
+		if pkg.Kind == DiffChanged && pkg.OldResolved != pkg.NewResolved {
+			fmt.Fprintf(w, "    resolved: %s -> %s\n", pkg.OldResolved, pkg.NewResolved)
+		}
+		for _, sys := range pkg.Systems {
+			fmt.Fprintf(w, "    %s:\n", sys.System)
+			for _, out := range sys.Added {
+				fmt.Fprintf(w, "      + %s %s\n", out.Name, out.Path)
+			}
+			for _, out := range sys.Removed {
+				fmt.Fprintf(w, "      - %s %s\n", out.Name, out.Path)
+			}
+			for _, out := range sys.Changed {
+				fmt.Fprintf(w, "      ~ %s %s -> %s\n", out.Name, out.OldPath, out.NewPath)
+			}
+		}
+	}
+}
+
+func diffPackages(profile string, before, after map[string]*Package) []PackageDiff {
+	names := lo.Uniq(append(lo.Keys(before), lo.Keys(after)...))
+	slices.Sort(names)
+
+	diffs := []PackageDiff{}
+	for _, name := range names {
+		oldPkg, newPkg := before[name], after[name]
+		diff := PackageDiff{Package: name, Profile: profile}
+		switch {
+		case oldPkg == nil:
+			diff.Kind = DiffAdded
+			oldPkg = &Package{}
+		case newPkg == nil:
+			diff.Kind = DiffRemoved
+			newPkg = &Package{}
+		default:
+			diff.Kind = DiffChanged
+		}
+		diff.OldVersion, diff.NewVersion = oldPkg.Version, newPkg.Version
+		diff.OldResolved, diff.NewResolved = oldPkg.Resolved, newPkg.Resolved
+		diff.Systems = diffSystems(oldPkg.Systems, newPkg.Systems)
+
+		if diff.Kind == DiffChanged && diff.OldVersion == diff.NewVersion &&
+			diff.OldResolved == diff.NewResolved && len(diff.Systems) == 0 {
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+func diffSystems(before, after map[string]*SystemInfo) []SystemDiff {
+	systems := lo.Uniq(append(lo.Keys(before), lo.Keys(after)...))
+	slices.Sort(systems)
+
+	diffs := []SystemDiff{}
+	for _, system := range systems {
+		oldOutputs := outputsByName(before[system])
+		newOutputs := outputsByName(after[system])
+		diff := SystemDiff{System: system}
+
+		names := lo.Uniq(append(lo.Keys(oldOutputs), lo.Keys(newOutputs)...))
+		slices.Sort(names)
+		for _, name := range names {
+			oldOut, inOld := oldOutputs[name]
+			newOut, inNew := newOutputs[name]
+			switch {
+			case !inOld:
+				diff.Added = append(diff.Added, newOut)
+			case !inNew:
+				diff.Removed = append(diff.Removed, oldOut)
+			case oldOut.Path != newOut.Path:
+				diff.Changed = append(diff.Changed, OutputChange{
+					Name:    name,
+					OldPath: oldOut.Path,
+					NewPath: newOut.Path,
+				})
+			}
+		}
+		if len(diff.Added) > 0 || len(diff.Removed) > 0 || len(diff.Changed) > 0 {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+func outputsByName(info *SystemInfo) map[string]Output {
+	outputs := map[string]Output{}
+	if info == nil {
+		return outputs
+	}
+	for _, out := range info.Outputs {
+		outputs[out.Name] = out
+	}
+	return outputs
+}
+
+func packagesOf(f *File) map[string]*Package {
+	if f == nil {
+		return nil
+	}
+	return f.Packages
+}
+
+func profilesOf(f *File) map[string]*ProfilePackages {
+	if f == nil {
+		return nil
+	}
+	return f.Profiles
+}
+
+func profilePackagesOf(f *File, profile string) map[string]*Package {
+	if section := profilesOf(f)[profile]; section != nil {
+		return section.Packages
+	}
+	return nil
+}
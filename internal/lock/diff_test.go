@@ -0,0 +1,149 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffFiles(t *testing.T) {
+	before, err := Parse([]byte(`{
+  "lockfile_version": "1",
+  "packages": {
+    "go@1.21": {
+      "resolved": "github:NixOS/nixpkgs/aaa#go_1_21",
+      "version": "1.21.5",
+      "systems": {
+        "x86_64-linux": {
+          "outputs": [{"name": "out", "path": "/nix/store/a-go-1.21.5", "default": true}]
+        }
+      }
+    },
+    "jq@1.7": {
+      "resolved": "github:NixOS/nixpkgs/aaa#jq",
+      "version": "1.7.1",
+      "systems": {
+        "x86_64-linux": {"store_path": "/nix/store/a-jq-1.7.1-bin"}
+      }
+    },
+    "ripgrep@14": {
+      "resolved": "github:NixOS/nixpkgs/aaa#ripgrep",
+      "version": "14.1.0"
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := Parse([]byte(`{
+  "lockfile_version": "1",
+  "packages": {
+    "go@1.21": {
+      "resolved": "github:NixOS/nixpkgs/bbb#go_1_21",
+      "version": "1.21.10",
+      "systems": {
+        "x86_64-linux": {
+          "outputs": [{"name": "out", "path": "/nix/store/b-go-1.21.10", "default": true}]
+        },
+        "aarch64-darwin": {
+          "outputs": [{"name": "out", "path": "/nix/store/c-go-1.21.10", "default": true}]
+        }
+      }
+    },
+    "jq@1.7": {
+      "resolved": "github:NixOS/nixpkgs/aaa#jq",
+      "version": "1.7.1",
+      "systems": {
+        "x86_64-linux": {
+          "outputs": [
+            {"name": "bin", "path": "/nix/store/a-jq-1.7.1-bin", "default": true},
+            {"name": "man", "path": "/nix/store/a-jq-1.7.1-man"}
+          ]
+        }
+      }
+    }
+  },
+  "profiles": {
+    "ci": {
+      "packages": {
+        "chromium@latest": {"resolved": "github:NixOS/nixpkgs/bbb#chromium", "version": "125.0"}
+      }
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := DiffFiles(before, after)
+	want := &Diff{Packages: []PackageDiff{
+		{
+			Package:     "go@1.21",
+			Kind:        DiffChanged,
+			OldVersion:  "1.21.5",
+			NewVersion:  "1.21.10",
+			OldResolved: "github:NixOS/nixpkgs/aaa#go_1_21",
+			NewResolved: "github:NixOS/nixpkgs/bbb#go_1_21",
+			Systems: []SystemDiff{
+				{
+					System: "aarch64-darwin",
+					Added:  []Output{{Name: "out", Path: "/nix/store/c-go-1.21.10", Default: true}},
+				},
+				{
+					System: "x86_64-linux",
+					Changed: []OutputChange{
+						{Name: "out", OldPath: "/nix/store/a-go-1.21.5", NewPath: "/nix/store/b-go-1.21.10"},
+					},
+				},
+			},
+		},
+		{
+			Package:     "jq@1.7",
+			Kind:        DiffChanged,
+			OldVersion:  "1.7.1",
+			NewVersion:  "1.7.1",
+			OldResolved: "github:NixOS/nixpkgs/aaa#jq",
+			NewResolved: "github:NixOS/nixpkgs/aaa#jq",
+			Systems: []SystemDiff{{
+				System: "x86_64-linux",
+				Added: []Output{
+					{Name: "bin", Path: "/nix/store/a-jq-1.7.1-bin", Default: true},
+					{Name: "man", Path: "/nix/store/a-jq-1.7.1-man"},
+				},
+				Removed: []Output{{Name: "out", Path: "/nix/store/a-jq-1.7.1-bin", Default: true}},
+			}},
+		},
+		{
+			Package:     "ripgrep@14",
+			Kind:        DiffRemoved,
+			OldVersion:  "14.1.0",
+			OldResolved: "github:NixOS/nixpkgs/aaa#ripgrep",
+			Systems:     []SystemDiff{},
+		},
+		{
+			Package:     "chromium@latest",
+			Kind:        DiffAdded,
+			Profile:     "ci",
+			NewVersion:  "125.0",
+			NewResolved: "github:NixOS/nixpkgs/bbb#chromium",
+			Systems:     []SystemDiff{},
+		},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong diff (-want +got):\n%s", diff)
+	}
+
+	if !DiffFiles(after, after).Empty() {
+		t.Error("got non-empty diff for identical lockfiles")
+	}
+
+	buf := &bytes.Buffer{}
+	got.Print(buf)
+	if !bytes.Contains(buf.Bytes(), []byte("~ go@1.21 1.21.5 -> 1.21.10\n")) {
+		t.Errorf("printed diff is missing version change:\n%s", buf)
+	}
+}
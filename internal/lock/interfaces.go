@@ -3,11 +3,17 @@
 
 package lock
 
+import "go.jetpack.io/devbox/internal/searcher"
+
 type devboxProject interface {
 	ConfigHash() (string, error)
 	NixPkgsCommitHash() string
 	AllPackageNamesIncludingRemovedTriggerPackages() []string
 	ProjectDir() string
+	// ActiveProfile returns nil if no devbox.json profile is selected.
+	ActiveProfile() *Profile
+	// Searcher resolves the packages that aren't in the lockfile yet.
+	Searcher() searcher.Searcher
 }
 
 type Locker interface {
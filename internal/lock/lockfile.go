@@ -5,6 +5,7 @@ package lock
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"path/filepath"
@@ -31,6 +32,15 @@ type File struct {
 
 	// Packages is keyed by "canonicalName@version"
 	Packages map[string]*Package `json:"packages"`
+
+	// Profiles is keyed by profile name and holds the packages that only that
+	// profile uses. The active profile's packages are also present in Packages
+	// while the file is in memory.
+	Profiles map[string]*ProfilePackages `json:"profiles,omitempty"`
+
+	// Plugins is keyed by the include reference of plugins that devbox
+	// downloads, such as plugins in GitHub or git repositories and tarballs.
+	Plugins map[string]*Plugin `json:"plugins,omitempty"`
 }
 
 func GetFile(project devboxProject) (*File, error) {
@@ -50,6 +60,7 @@ func GetFile(project devboxProject) (*File, error) {
 
 	// If the lockfile has legacy StorePath fields, we need to convert them to the new format
 	ensurePackagesHaveOutputs(lockFile.Packages)
+	lockFile.mergeActiveProfile()
 
 	return lockFile, nil
 }
@@ -70,6 +81,24 @@ func (f *File) Remove(pkgs ...string) error {
 	return f.Save()
 }
 
+// Clone returns a deep copy of f that belongs to the same project. Changes to
+// the copy don't affect f.
+func (f *File) Clone() (*File, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	clone := &File{devboxProject: f.devboxProject}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if clone.Packages == nil {
+		clone.Packages = map[string]*Package{}
+	}
+	ensurePackagesHaveOutputs(clone.Packages)
+	return clone, nil
+}
+
 // Resolve updates the in memory copy for performance but does not write to disk
 // This avoids writing values that may need to be removed in case of error.
 func (f *File) Resolve(pkg string) (*Package, error) {
@@ -130,7 +159,11 @@ func (f *File) Save() error {
 	// users of the `lock.File` struct will have the correct data.
 	defer ensurePackagesHaveOutputs(f.Packages)
 
-	return cuecfg.WriteFile(lockFilePath(f.devboxProject.ProjectDir()), f)
+	toSave := f.fileToSave()
+	// Keep the in-memory profile sections in sync with what we write so that
+	// isDirty compares like with like.
+	f.Profiles = toSave.Profiles
+	return cuecfg.WriteFile(lockFilePath(f.devboxProject.ProjectDir()), toSave)
 }
 
 func (f *File) LegacyNixpkgsPath(pkg string) string {
@@ -174,10 +207,11 @@ func IsLegacyPackage(pkg string) bool {
 // Tidy ensures that the lockfile has the set of packages corresponding to the devbox.json config.
 // It gets rid of older packages that are no longer needed.
 func (f *File) Tidy() {
-	f.Packages = lo.PickByKeys(
-		f.Packages,
-		f.devboxProject.AllPackageNamesIncludingRemovedTriggerPackages(),
-	)
+	keep := f.devboxProject.AllPackageNamesIncludingRemovedTriggerPackages()
+	if profile := f.devboxProject.ActiveProfile(); profile != nil {
+		keep = append(keep, profile.RemovedPackages...)
+	}
+	f.Packages = lo.PickByKeys(f.Packages, keep)
 }
 
 // IsUpToDateAndInstalled returns true if the lockfile is up to date and the
@@ -193,11 +227,15 @@ func (f *File) IsUpToDateAndInstalled(isFish bool) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return isStateUpToDate(UpdateStateHashFileArgs{
+	args := UpdateStateHashFileArgs{
 		ProjectDir: f.devboxProject.ProjectDir(),
 		ConfigHash: configHash,
 		IsFish:     isFish,
-	})
+	}
+	if profile := f.devboxProject.ActiveProfile(); profile != nil {
+		args.Profile = profile.Name
+	}
+	return isStateUpToDate(args)
 }
 
 func (f *File) SetOutputsForPackage(pkg string, outputs []Output) error {
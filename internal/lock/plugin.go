@@ -0,0 +1,40 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import "github.com/samber/lo"
+
+// Plugin pins a plugin that devbox downloads, such as a plugin in a GitHub
+// repository or a tarball, to the content it had when it was locked. This
+// keeps changes to the plugin upstream from silently changing the
+// environment. `devbox plugin update` bumps the pin.
+type Plugin struct {
+	// Rev is the commit that plugins in GitHub and git repositories were
+	// fetched from. It's empty for other plugins.
+	Rev string `json:"rev,omitempty"`
+
+	// Hash is the SHA-256 hash of the plugin's files in SRI format, such as
+	// sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=.
+	Hash string `json:"hash"`
+}
+
+// GetPlugin returns the locked plugin with an include reference, or nil if it
+// isn't locked.
+func (f *File) GetPlugin(ref string) *Plugin {
+	return f.Plugins[ref]
+}
+
+// LockPlugin pins the plugin with an include reference. Like Resolve, it
+// doesn't write to disk.
+func (f *File) LockPlugin(ref string, plugin *Plugin) {
+	if f.Plugins == nil {
+		f.Plugins = map[string]*Plugin{}
+	}
+	f.Plugins[ref] = plugin
+}
+
+// TidyPlugins removes the pins of plugins that aren't included anymore.
+func (f *File) TidyPlugins(keep []string) {
+	f.Plugins = lo.PickByKeys(f.Plugins, keep)
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"maps"
+	"slices"
+)
+
+// Profile describes the selected devbox.json profile to the lockfile.
+type Profile struct {
+	Name string
+
+	// Packages are the packages that only the profile uses. They are stored
+	// in the profile's own section of the lockfile.
+	Packages []string
+
+	// RemovedPackages are base packages that the profile removes. They are
+	// kept in the lockfile so that the base environment doesn't change.
+	RemovedPackages []string
+}
+
+// ProfilePackages is the lockfile section of a single profile.
+type ProfilePackages struct {
+	// Packages is keyed by "canonicalName@version"
+	Packages map[string]*Package `json:"packages"`
+}
+
+// mergeActiveProfile copies the packages of the active profile into
+// f.Packages so that the rest of devbox can treat them like any other
+// package. Save splits them back out.
+func (f *File) mergeActiveProfile() {
+	profile := f.devboxProject.ActiveProfile()
+	if profile == nil || f.Profiles[profile.Name] == nil {
+		return
+	}
+	section := f.Profiles[profile.Name].Packages
+	ensurePackagesHaveOutputs(section)
+	for name, pkg := range section {
+		if _, ok := f.Packages[name]; !ok {
+			f.Packages[name] = pkg
+		}
+	}
+}
+
+// fileToSave returns a shallow copy of f where the active profile's packages
+// are moved from Packages into their own section.
+func (f *File) fileToSave() *File {
+	profile := f.devboxProject.ActiveProfile()
+	if profile == nil {
+		return f
+	}
+
+	toSave := *f
+	toSave.Packages = maps.Clone(f.Packages)
+	toSave.Profiles = maps.Clone(f.Profiles)
+	section := &ProfilePackages{Packages: map[string]*Package{}}
+	for name, pkg := range f.Packages {
+		if slices.Contains(profile.Packages, name) {
+			section.Packages[name] = pkg
+			delete(toSave.Packages, name)
+		}
+	}
+	if len(section.Packages) == 0 {
+		delete(toSave.Profiles, profile.Name)
+	} else {
+		if toSave.Profiles == nil {
+			toSave.Profiles = map[string]*ProfilePackages{}
+		}
+		toSave.Profiles[profile.Name] = section
+	}
+	return &toSave
+}
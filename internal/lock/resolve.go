@@ -15,6 +15,7 @@ import (
 	"go.jetpack.io/devbox/internal/boxcli/featureflag"
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/redact"
 	"go.jetpack.io/devbox/internal/searcher"
@@ -37,6 +38,19 @@ func (f *File) FetchResolvedPackage(pkg string) (*Package, error) {
 		return nil, usererr.New("No version specified for %q.", name)
 	}
 
+	if searcher.IsVersionRange(version) && !pkgtype.IsRunX(pkg) {
+		if _, err := searcher.ParseVersionConstraint(version); err != nil {
+			return nil, usererr.New("Package %q has an %v", name, err)
+		}
+	}
+
+	if envir.IsOffline() {
+		return nil, usererr.New(
+			"Cannot resolve %s in offline mode because it isn't in devbox.lock. "+
+				"Run this command with network access first.", pkg,
+		)
+	}
+
 	if pkgtype.IsRunX(pkg) {
 		ref, err := ResolveRunXPackage(context.TODO(), pkg)
 		if err != nil {
@@ -47,11 +61,14 @@ func (f *File) FetchResolvedPackage(pkg string) (*Package, error) {
 			Version:  ref.Version,
 		}, nil
 	}
-	if featureflag.ResolveV2.Enabled() {
-		return resolveV2(context.TODO(), name, version)
+	// The local index resolves to its own nixpkgs, which the v1 response
+	// can't express.
+	s := f.devboxProject.Searcher()
+	if featureflag.ResolveV2.Enabled() || searcher.IsLocalIndex(s) {
+		return resolveV2(context.TODO(), s, name, version)
 	}
 
-	packageVersion, err := searcher.Client().Resolve(name, version)
+	packageVersion, err := s.Resolve(context.TODO(), name, version)
 	if err != nil {
 		return nil, errors.Wrapf(nix.ErrPackageNotFound, "%s@%s", name, version)
 	}
@@ -83,8 +100,8 @@ func (f *File) FetchResolvedPackage(pkg string) (*Package, error) {
 	}, nil
 }
 
-func resolveV2(ctx context.Context, name, version string) (*Package, error) {
-	resolved, err := searcher.Client().ResolveV2(ctx, name, version)
+func resolveV2(ctx context.Context, s searcher.Searcher, name, version string) (*Package, error) {
+	resolved, err := s.ResolveV2(ctx, name, version)
 	if errors.Is(err, searcher.ErrNotFound) {
 		return nil, redact.Errorf("%s@%s: %w", name, version, nix.ErrPackageNotFound)
 	}
@@ -0,0 +1,26 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"strings"
+	"testing"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestFetchResolvedPackageOffline(t *testing.T) {
+	t.Setenv(envir.DevboxOffline, "1")
+	t.Setenv(envir.DevboxSearchHost, "http://devbox-search.invalid")
+
+	f := &File{devboxProject: &testProject{}, Packages: map[string]*Package{}}
+	_, err := f.FetchResolvedPackage("hello@2.12")
+	if _, ok := usererr.Extract(err); !ok {
+		t.Fatalf("got error %v, want a user error", err)
+	}
+	if !strings.Contains(err.Error(), "hello@2.12") {
+		t.Errorf("got error %q, want it to mention the package", err)
+	}
+}
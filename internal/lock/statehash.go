@@ -5,8 +5,10 @@ package lock
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"path/filepath"
+	"strings"
 
 	"go.jetpack.io/devbox/internal/build"
 	"go.jetpack.io/devbox/internal/cachehash"
@@ -37,6 +39,9 @@ type UpdateStateHashFileArgs struct {
 	// IsFish is an arg because in the future we may allow the user
 	// to specify shell in devbox.json which should be passed in here.
 	IsFish bool
+	// Profile is the selected devbox.json profile, if any. Each profile has
+	// its own state file so switching between them is detected.
+	Profile string
 }
 
 func UpdateAndSaveStateHashFile(args UpdateStateHashFileArgs) error {
@@ -45,7 +50,11 @@ func UpdateAndSaveStateHashFile(args UpdateStateHashFileArgs) error {
 		return err
 	}
 
-	return cuecfg.WriteFile(stateHashFilePath(args.ProjectDir), newLock)
+	path, err := stateHashFilePath(args.ProjectDir, args.Profile)
+	if err != nil {
+		return err
+	}
+	return cuecfg.WriteFile(path, newLock)
 }
 
 // SetIgnoreShellMismatch is used to disable the shell comparison when checking
@@ -55,7 +64,7 @@ func SetIgnoreShellMismatch(ignore bool) {
 }
 
 func isStateUpToDate(args UpdateStateHashFileArgs) (bool, error) {
-	filesystemStateHash, err := readStateHashFile(args.ProjectDir)
+	filesystemStateHash, err := readStateHashFile(args.ProjectDir, args.Profile)
 	if err != nil {
 		return false, err
 	}
@@ -71,9 +80,13 @@ func isStateUpToDate(args UpdateStateHashFileArgs) (bool, error) {
 	return *filesystemStateHash == *newStateHash, nil
 }
 
-func readStateHashFile(projectDir string) (*stateHashFile, error) {
+func readStateHashFile(projectDir, profile string) (*stateHashFile, error) {
+	path, err := stateHashFilePath(projectDir, profile)
+	if err != nil {
+		return nil, err
+	}
 	hashFile := &stateHashFile{}
-	err := cuecfg.ParseFile(stateHashFilePath(projectDir), hashFile)
+	err = cuecfg.ParseFile(path, hashFile)
 	if errors.Is(err, fs.ErrNotExist) {
 		return hashFile, nil
 	}
@@ -111,8 +124,17 @@ func getCurrentStateHash(args UpdateStateHashFileArgs) (*stateHashFile, error) {
 	return newLock, nil
 }
 
-func stateHashFilePath(projectDir string) string {
-	return filepath.Join(projectDir, ".devbox", "state.json")
+// stateHashFilePath returns the state file of profile. It refuses profile
+// names that aren't a plain file name component so that the file can't be
+// outside of .devbox.
+func stateHashFilePath(projectDir, profile string) (string, error) {
+	if profile == "" {
+		return filepath.Join(projectDir, ".devbox", "state.json"), nil
+	}
+	if strings.ContainsAny(profile, `/\`) || strings.Contains(profile, "..") {
+		return "", fmt.Errorf("invalid profile name %q", profile)
+	}
+	return filepath.Join(projectDir, ".devbox", "state."+profile+".json"), nil
 }
 
 func manifestHash(profileDir string) (string, error) {
@@ -0,0 +1,84 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// LockSystem adds the outputs of pkg for system to its lockfile entry if the
+// entry doesn't have them yet. The outputs are evaluated with
+// `nix eval --system`, so nothing is built or downloaded and system doesn't
+// need to match the current one. It returns false if there was nothing to do,
+// such as when the system is already locked or pkg isn't resolved to a nix
+// installable. The lockfile isn't saved.
+func (f *File) LockSystem(ctx context.Context, pkg, system string) (bool, error) {
+	entry, err := f.Resolve(pkg)
+	if err != nil {
+		return false, err
+	}
+	if entry.Resolved == "" {
+		return false, nil
+	}
+	return lockOutputs(ctx, entry, installableForSystem(entry.Resolved, system), system)
+}
+
+// LockFlakeSystem is like LockSystem, but for flake packages, which don't
+// have a resolved reference. installable is the flake installable of pkg, and
+// nix finds its attribute for system using the --system setting. It must be
+// pinned to a commit so that every system is locked at the same revision.
+func (f *File) LockFlakeSystem(ctx context.Context, pkg, installable, system string) (bool, error) {
+	entry, ok := f.Packages[pkg]
+	if !ok || entry == nil {
+		entry = &Package{}
+		f.Packages[pkg] = entry
+	}
+	return lockOutputs(ctx, entry, installable, system)
+}
+
+// lockOutputs evaluates the outputs of installable for system and records
+// them in entry. It returns false if entry already has outputs for system.
+func lockOutputs(ctx context.Context, entry *Package, installable, system string) (bool, error) {
+	if entry.Systems[system] != nil && len(entry.Systems[system].Outputs) > 0 {
+		return false, nil
+	}
+
+	evaluated, err := nix.EvalPackageOutputs(ctx, installable, system, entry.AllowInsecure)
+	if err != nil {
+		return false, err
+	}
+	outputs := make([]Output, len(evaluated))
+	for i, out := range evaluated {
+		outputs[i] = Output{Name: out.Name, Path: out.Path, Default: out.Default}
+	}
+	if entry.Systems == nil {
+		entry.Systems = map[string]*SystemInfo{}
+	}
+	entry.Systems[system] = &SystemInfo{Outputs: outputs}
+	return true, nil
+}
+
+// installableForSystem rewrites a resolved reference with a system-specific
+// attribute path (such as legacyPackages.x86_64-linux.hello) so that it
+// points at system instead. Other references are returned as-is because nix
+// looks them up using the --system setting.
+func installableForSystem(resolved, system string) string {
+	ref, attrPath, ok := strings.Cut(resolved, "#")
+	if !ok {
+		return resolved
+	}
+	for _, prefix := range []string{"legacyPackages.", "packages."} {
+		rest, ok := strings.CutPrefix(attrPath, prefix)
+		if !ok {
+			continue
+		}
+		if _, name, ok := strings.Cut(rest, "."); ok {
+			return ref + "#" + prefix + system + "." + name
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,26 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import "testing"
+
+func TestInstallableForSystem(t *testing.T) {
+	cases := []struct{ resolved, want string }{
+		{"github:NixOS/nixpkgs/abc#hello", "github:NixOS/nixpkgs/abc#hello"},
+		{
+			"github:NixOS/nixpkgs/abc#legacyPackages.x86_64-linux.hello",
+			"github:NixOS/nixpkgs/abc#legacyPackages.aarch64-darwin.hello",
+		},
+		{
+			"github:NixOS/nixpkgs/abc#packages.x86_64-linux.python3Packages.numpy",
+			"github:NixOS/nixpkgs/abc#packages.aarch64-darwin.python3Packages.numpy",
+		},
+		{"github:NixOS/nixpkgs/abc", "github:NixOS/nixpkgs/abc"},
+	}
+	for _, tc := range cases {
+		if got := installableForSystem(tc.resolved, "aarch64-darwin"); got != tc.want {
+			t.Errorf("installableForSystem(%q) = %q, want %q", tc.resolved, got, tc.want)
+		}
+	}
+}
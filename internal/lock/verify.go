@@ -0,0 +1,179 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// VerifyIssueKind classifies a problem found by Verify.
+type VerifyIssueKind string
+
+const (
+	// VerifyMismatch means the store paths recorded in the lockfile are not
+	// the ones that the resolved reference evaluates to. This happens when the
+	// lockfile was edited by hand or when the paths were tampered with.
+	VerifyMismatch VerifyIssueKind = "mismatch"
+
+	// VerifyMissing means a package has no lockfile entry, no resolved
+	// reference, or no outputs for the current system.
+	VerifyMissing VerifyIssueKind = "missing"
+
+	// VerifyStale means the lockfile has an entry for a package that
+	// devbox.json no longer references.
+	VerifyStale VerifyIssueKind = "stale"
+
+	// VerifyEvalError means the resolved reference could not be evaluated.
+	VerifyEvalError VerifyIssueKind = "eval_error"
+)
+
+// VerifyIssue is a single problem with a lockfile entry.
+type VerifyIssue struct {
+	Package string          `json:"package" yaml:"package"`
+	Kind    VerifyIssueKind `json:"kind" yaml:"kind"`
+	Message string          `json:"message" yaml:"message"`
+
+	// Locked and Evaluated are the sorted store paths from the lockfile and
+	// from nix. They're only set for mismatches.
+	Locked    []string `json:"locked,omitempty" yaml:"locked,omitempty"`
+	Evaluated []string `json:"evaluated,omitempty" yaml:"evaluated,omitempty"`
+}
+
+// VerifyReport is the result of verifying a lockfile.
+type VerifyReport struct {
+	System string `json:"system" yaml:"system"`
+
+	// Verified is the number of packages whose store paths were evaluated
+	// and matched the lockfile.
+	Verified int           `json:"verified" yaml:"verified"`
+	Issues   []VerifyIssue `json:"issues" yaml:"issues"`
+}
+
+// OK returns true if the lockfile has no issues.
+func (r *VerifyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Verify checks the lockfile against devbox.json and nix. Every package in
+// required must have a resolved entry with outputs for the current system, and
+// those outputs must match the store paths that nix evaluates the resolved
+// reference to. Entries that devbox.json doesn't use are reported as stale.
+//
+// Verify only returns an error if ctx is canceled. Evaluation failures are
+// reported as issues.
+func (f *File) Verify(ctx context.Context, required []string) (*VerifyReport, error) {
+	return f.verify(ctx, nix.System(), required, nix.StorePathsFromInstallable)
+}
+
+type storePathsFunc func(ctx context.Context, installable string, allowInsecure bool) ([]string, error)
+
+func (f *File) verify(
+	ctx context.Context,
+	system string,
+	required []string,
+	storePaths storePathsFunc,
+) (*VerifyReport, error) {
+	report := &VerifyReport{System: system, Issues: []VerifyIssue{}}
+
+	keep := f.devboxProject.AllPackageNamesIncludingRemovedTriggerPackages()
+	if profile := f.devboxProject.ActiveProfile(); profile != nil {
+		keep = append(keep, profile.RemovedPackages...)
+	}
+	names := lo.Keys(f.Packages)
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.Contains(keep, name) {
+			report.Issues = append(report.Issues, VerifyIssue{
+				Package: name,
+				Kind:    VerifyStale,
+				Message: "not referenced by devbox.json",
+			})
+		}
+	}
+
+	for _, name := range lo.Uniq(required) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		issue := verifyPackage(ctx, system, name, f.Packages[name], storePaths)
+		if issue != nil {
+			report.Issues = append(report.Issues, *issue)
+		} else {
+			report.Verified++
+		}
+	}
+	return report, nil
+}
+
+func verifyPackage(
+	ctx context.Context,
+	system string,
+	name string,
+	pkg *Package,
+	storePaths storePathsFunc,
+) *VerifyIssue {
+	missing := func(msg string, args ...any) *VerifyIssue {
+		return &VerifyIssue{Package: name, Kind: VerifyMissing, Message: fmt.Sprintf(msg, args...)}
+	}
+	if pkg == nil {
+		return missing("no lockfile entry")
+	}
+	if pkg.Resolved == "" {
+		return missing("no resolved reference")
+	}
+
+	sysInfo := pkg.Systems[system]
+	if sysInfo == nil || len(sysInfo.Outputs) == 0 {
+		if pkg.Source != devboxSearchSource {
+			// Legacy nixpkgs entries never recorded store paths, so there's
+			// nothing to compare against.
+			return nil
+		}
+		return missing("no outputs for system %s", system)
+	}
+
+	locked := make([]string, 0, len(sysInfo.Outputs))
+	names := make([]string, 0, len(sysInfo.Outputs))
+	for _, out := range sysInfo.Outputs {
+		locked = append(locked, out.Path)
+		if out.Name != "" {
+			names = append(names, out.Name)
+		}
+	}
+	slices.Sort(locked)
+
+	// Ask nix for exactly the outputs that the lockfile records so that
+	// non-default outputs are compared too.
+	installable := pkg.Resolved
+	if len(names) == len(sysInfo.Outputs) {
+		installable += "^" + strings.Join(names, ",")
+	}
+	evaluated, err := storePaths(ctx, installable, pkg.AllowInsecure)
+	if err != nil {
+		return &VerifyIssue{
+			Package: name,
+			Kind:    VerifyEvalError,
+			Message: fmt.Sprintf("evaluating %s: %v", installable, err),
+		}
+	}
+	evaluated = slices.Clone(evaluated)
+	slices.Sort(evaluated)
+
+	if !slices.Equal(locked, evaluated) {
+		return &VerifyIssue{
+			Package:   name,
+			Kind:      VerifyMismatch,
+			Message:   fmt.Sprintf("store paths do not match %s", pkg.Resolved),
+			Locked:    locked,
+			Evaluated: evaluated,
+		}
+	}
+	return nil
+}
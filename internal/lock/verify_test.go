@@ -0,0 +1,94 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+type testProject struct{ packages []string }
+
+func (p *testProject) ConfigHash() (string, error) { return "", nil }
+func (p *testProject) NixPkgsCommitHash() string   { return "" }
+func (p *testProject) AllPackageNamesIncludingRemovedTriggerPackages() []string {
+	return p.packages
+}
+func (p *testProject) ProjectDir() string          { return "" }
+func (p *testProject) ActiveProfile() *Profile     { return nil }
+func (p *testProject) Searcher() searcher.Searcher { return searcher.Client() }
+
+func TestVerify(t *testing.T) {
+	const system = "x86_64-linux"
+	searchPackage := func(resolved string, outputs ...Output) *Package {
+		return &Package{
+			Resolved: resolved,
+			Source:   devboxSearchSource,
+			Systems:  map[string]*SystemInfo{system: {Outputs: outputs}},
+		}
+	}
+	f := &File{
+		devboxProject: &testProject{packages: []string{
+			"ok@1", "tampered@1", "nosystem@1", "broken@1", "unlocked@1",
+		}},
+		Packages: map[string]*Package{
+			"ok@1": searchPackage("github:NixOS/nixpkgs/abc#ok",
+				Output{Name: "out", Path: "/nix/store/ok-out"},
+				Output{Name: "man", Path: "/nix/store/ok-man"},
+			),
+			"tampered@1": searchPackage("github:NixOS/nixpkgs/abc#tampered",
+				Output{Name: "out", Path: "/nix/store/evil"},
+			),
+			"nosystem@1": {Resolved: "github:NixOS/nixpkgs/abc#nosystem", Source: devboxSearchSource},
+			"broken@1": searchPackage("github:NixOS/nixpkgs/abc#broken",
+				Output{Name: "out", Path: "/nix/store/broken"},
+			),
+			"unused@1": {Resolved: "github:NixOS/nixpkgs/abc#unused"},
+		},
+	}
+	evaluated := map[string][]string{
+		"github:NixOS/nixpkgs/abc#ok^out,man":   {"/nix/store/ok-man", "/nix/store/ok-out"},
+		"github:NixOS/nixpkgs/abc#tampered^out": {"/nix/store/tampered"},
+	}
+	storePaths := func(_ context.Context, installable string, _ bool) ([]string, error) {
+		if paths, ok := evaluated[installable]; ok {
+			return paths, nil
+		}
+		return nil, errors.New("attribute missing")
+	}
+
+	required := []string{"ok@1", "tampered@1", "nosystem@1", "broken@1", "unlocked@1"}
+	report, err := f.verify(context.Background(), system, required, storePaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &VerifyReport{
+		System:   system,
+		Verified: 1,
+		Issues: []VerifyIssue{
+			{Package: "unused@1", Kind: VerifyStale, Message: "not referenced by devbox.json"},
+			{
+				Package:   "tampered@1",
+				Kind:      VerifyMismatch,
+				Message:   "store paths do not match github:NixOS/nixpkgs/abc#tampered",
+				Locked:    []string{"/nix/store/evil"},
+				Evaluated: []string{"/nix/store/tampered"},
+			},
+			{Package: "nosystem@1", Kind: VerifyMissing, Message: "no outputs for system x86_64-linux"},
+			{
+				Package: "broken@1",
+				Kind:    VerifyEvalError,
+				Message: "evaluating github:NixOS/nixpkgs/abc#broken^out: attribute missing",
+			},
+			{Package: "unlocked@1", Kind: VerifyMissing, Message: "no lockfile entry"},
+		},
+	}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("wrong report (-want +got):\n%s", diff)
+	}
+}
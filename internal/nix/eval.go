@@ -3,8 +3,11 @@ package nix
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 func EvalPackageName(path string) (string, error) {
@@ -55,6 +58,69 @@ func Eval(path string) ([]byte, error) {
 	return cmd.CombinedOutput(context.TODO())
 }
 
+// PackageOutput is an output of a package as evaluated by EvalPackageOutputs.
+type PackageOutput struct {
+	Name    string
+	Path    string
+	Default bool
+}
+
+// evalOutputsExpr maps a derivation to its output store paths and the outputs
+// that nix installs by default.
+const evalOutputsExpr = `drv: {
+  paths = builtins.listToAttrs (map
+    (name: { inherit name; value = drv.${name}.outPath; })
+    (drv.outputs or [ "out" ]));
+  defaults = drv.meta.outputsToInstall or [ (builtins.head (drv.outputs or [ "out" ])) ];
+}`
+
+// EvalPackageOutputs evaluates the output store paths of installable for
+// system without building or downloading anything. The system doesn't need to
+// match the current one.
+func EvalPackageOutputs(ctx context.Context, installable, system string, allowInsecure bool) ([]PackageOutput, error) {
+	// --impure for NIXPKGS_ALLOW_UNFREE
+	cmd := command("eval", "--impure", "--json", "--system", system, installable, "--apply", evalOutputsExpr)
+	cmd.Env = allowUnfreeEnv(os.Environ())
+	if allowInsecure {
+		cmd.Env = allowInsecureEnv(cmd.Env)
+	}
+
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseEvalPackageOutputs(out)
+}
+
+func parseEvalPackageOutputs(data []byte) ([]PackageOutput, error) {
+	result := struct {
+		Paths    map[string]string `json:"paths"`
+		Defaults []string          `json:"defaults"`
+	}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse package outputs: %w", err)
+	}
+	outputs := make([]PackageOutput, 0, len(result.Paths))
+	for name, path := range result.Paths {
+		outputs = append(outputs, PackageOutput{
+			Name:    name,
+			Path:    path,
+			Default: slices.Contains(result.Defaults, name),
+		})
+	}
+	// Default outputs first, then by name, so that the result is stable.
+	slices.SortFunc(outputs, func(a, b PackageOutput) int {
+		if a.Default != b.Default {
+			if a.Default {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return outputs, nil
+}
+
 func IsInsecureAllowed() bool {
 	allowed, _ := strconv.ParseBool(os.Getenv("NIXPKGS_ALLOW_INSECURE"))
 	return allowed
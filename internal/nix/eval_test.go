@@ -0,0 +1,32 @@
+package nix
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseEvalPackageOutputs(t *testing.T) {
+	out := []byte(`{
+		"defaults": ["bin", "man"],
+		"paths": {
+			"bin": "/nix/store/aaa-curl-8.4.0-bin",
+			"dev": "/nix/store/bbb-curl-8.4.0-dev",
+			"man": "/nix/store/ccc-curl-8.4.0-man",
+			"out": "/nix/store/ddd-curl-8.4.0"
+		}
+	}`)
+	got, err := parseEvalPackageOutputs(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PackageOutput{
+		{Name: "bin", Path: "/nix/store/aaa-curl-8.4.0-bin", Default: true},
+		{Name: "man", Path: "/nix/store/ccc-curl-8.4.0-man", Default: true},
+		{Name: "dev", Path: "/nix/store/bbb-curl-8.4.0-dev"},
+		{Name: "out", Path: "/nix/store/ddd-curl-8.4.0"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong outputs (-want +got):\n%s", diff)
+	}
+}
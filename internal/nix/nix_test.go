@@ -2,6 +2,8 @@
 package nix
 
 import (
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 )
@@ -269,3 +271,61 @@ func TestVersionInfoAtLeast(t *testing.T) {
 		info.AtLeast(v)
 	})
 }
+
+func TestCommandOffline(t *testing.T) {
+	if slices.Contains(command("eval").Args, "--offline") {
+		t.Error("got --offline without DEVBOX_OFFLINE")
+	}
+	t.Setenv("DEVBOX_OFFLINE", "1")
+	if !slices.Contains(command("eval").Args, "--offline") {
+		t.Error("got no --offline with DEVBOX_OFFLINE=1")
+	}
+}
+
+func TestReadFlakeLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flake.lock")
+	err := os.WriteFile(path, []byte(`{
+  "nodes": {
+    "gh-numtide-flake-utils": {
+      "locked": {"lastModified": 1710146030, "owner": "numtide", "repo": "flake-utils", "rev": "b1d9ab70662946ef0850d488da1c9019f3a9752a", "type": "github"},
+      "original": {"owner": "numtide", "repo": "flake-utils", "type": "github"}
+    },
+    "local-flake": {
+      "locked": {"lastModified": 1, "narHash": "sha256-AAAA", "path": "./flake", "type": "path"}
+    },
+    "root": {
+      "inputs": {
+        "gh-numtide-flake-utils": "gh-numtide-flake-utils",
+        "local-flake": "local-flake",
+        "follows": ["gh-numtide-flake-utils"]
+      }
+    }
+  },
+  "root": "root",
+  "version": 7
+}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := ReadFlakeLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, ok := lock.Input("gh-numtide-flake-utils")
+	if !ok {
+		t.Fatal("got no input for gh-numtide-flake-utils")
+	}
+	if got, want := input.Locked.Rev, "b1d9ab70662946ef0850d488da1c9019f3a9752a"; got != want {
+		t.Errorf("got rev %q, want %q", got, want)
+	}
+	if input, ok := lock.Input("local-flake"); !ok || input.Locked.Rev != "" {
+		t.Errorf("got input %v, %v for local-flake, want one without a rev", input, ok)
+	}
+	if _, ok := lock.Input("follows"); ok {
+		t.Error("got input for follows list, want none")
+	}
+	if _, ok := lock.Input("missing"); ok {
+		t.Error("got input for missing input, want none")
+	}
+}
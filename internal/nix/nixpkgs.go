@@ -131,3 +131,71 @@ func HashFromNixPkgsURL(url string) string {
 	}
 	return ""
 }
+
+// FlakeMetadata is the subset of `nix flake metadata --json` that devbox uses.
+type FlakeMetadata struct {
+	// URL is the locked flake reference.
+	URL          string `json:"url"`
+	LastModified int64  `json:"lastModified"`
+
+	// Revision is the locked git revision. It's empty for flakes that
+	// aren't in a git repository, such as path flakes.
+	Revision string `json:"revision"`
+}
+
+// GetFlakeMetadata locks ref and returns its metadata.
+func GetFlakeMetadata(ctx context.Context, ref string) (*FlakeMetadata, error) {
+	out, err := command("flake", "metadata", "--json", ref).Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &FlakeMetadata{}
+	if err := json.Unmarshal(out, metadata); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return metadata, nil
+}
+
+// FlakeLock is the subset of a flake.lock file that devbox uses.
+type FlakeLock struct {
+	Nodes map[string]FlakeLockNode `json:"nodes"`
+	Root  string                   `json:"root"`
+}
+
+// FlakeLockNode is a node in a flake.lock file.
+type FlakeLockNode struct {
+	// Inputs maps input names to node names. Inputs that follow another
+	// input are lists of input names instead.
+	Inputs map[string]json.RawMessage `json:"inputs"`
+	Locked struct {
+		Rev          string `json:"rev"`
+		LastModified int64  `json:"lastModified"`
+	} `json:"locked"`
+}
+
+// ReadFlakeLock reads the flake.lock file at path.
+func ReadFlakeLock(path string) (*FlakeLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	lock := &FlakeLock{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, errors.Wrapf(err, "parse %s", path)
+	}
+	return lock, nil
+}
+
+// Input returns the locked node of one of the root flake's direct inputs.
+func (l *FlakeLock) Input(name string) (*FlakeLockNode, bool) {
+	root, ok := l.Nodes[l.Root]
+	if !ok {
+		return nil, false
+	}
+	var nodeName string
+	if err := json.Unmarshal(root.Inputs[name], &nodeName); err != nil {
+		return nil, false
+	}
+	node, ok := l.Nodes[nodeName]
+	return &node, ok
+}
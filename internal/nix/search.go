@@ -41,21 +41,27 @@ func Search(url string) (map[string]*Info, error) {
 	return searchSystem(url, "" /* system */)
 }
 
-func parseSearchResults(data []byte) map[string]*Info {
-	var results map[string]map[string]any
-	err := json.Unmarshal(data, &results)
-	if err != nil {
-		panic(err)
+// ParseSearchResults parses the output of `nix search --json` into a map of
+// attribute paths to package info.
+func ParseSearchResults(data []byte) (map[string]*Info, error) {
+	var results map[string]struct {
+		PName       string `json:"pname"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, errors.WithStack(err)
 	}
 	infos := map[string]*Info{}
 	for key, result := range results {
 		infos[key] = &Info{
 			AttributeKey: key,
-			PName:        result["pname"].(string),
-			Version:      result["version"].(string),
+			PName:        result.PName,
+			Summary:      result.Description,
+			Version:      result.Version,
 		}
 	}
-	return infos
+	return infos, nil
 }
 
 // PkgExistsForAnySystem is a bit slow (~600ms). Only use it if there's already
@@ -109,7 +115,7 @@ func searchSystem(url, system string) (map[string]*Info, error) {
 		// return ErrPackageNotFound only for that case.
 		return nil, fmt.Errorf("error searching for pkg %s: %w", url, err)
 	}
-	return parseSearchResults(out), nil
+	return ParseSearchResults(out)
 }
 
 // allowableQuery specifies the regex that queries for SearchNixpkgsAttribute must match.
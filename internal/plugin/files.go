@@ -4,6 +4,7 @@
 package plugin
 
 import (
+	"context"
 	"io/fs"
 	"os"
 
@@ -14,12 +15,12 @@ import (
 	"go.jetpack.io/devbox/plugins"
 )
 
-func getConfigIfAny(inc Includable, projectDir string) (*Config, error) {
+func getConfigIfAny(ctx context.Context, inc Includable, projectDir string) (*Config, error) {
 	switch includable := inc.(type) {
 	case *devpkg.Package:
-		return getBuiltinPluginConfigIfExists(includable, projectDir)
-	case *githubPlugin:
-		content, err := includable.Fetch()
+		return getBuiltinPluginConfigIfExists(includable, projectDir, nil /*builtins*/)
+	case *githubPlugin, *remotePlugin:
+		content, err := includable.(fetcher).Fetch(ctx)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -29,19 +30,34 @@ func getConfigIfAny(inc Includable, projectDir string) (*Config, error) {
 		if err != nil && !os.IsNotExist(err) {
 			return nil, errors.WithStack(err)
 		}
+		if includable.IsDevboxConfig() {
+			// Template variables such as {{ .Virtenv }} only make sense in plugins,
+			// so included devbox.json files are parsed as-is.
+			return parseConfig(includable, content)
+		}
 		return buildConfig(includable, projectDir, string(content))
 	}
 	return nil, errors.Errorf("unknown plugin type %T", inc)
 }
 
+// getBuiltinPluginConfigIfExists returns the built-in plugin that pkg
+// activates, or nil if it doesn't activate one. If builtins isn't nil, the
+// plugins are read from it instead of the ones embedded in devbox.
 func getBuiltinPluginConfigIfExists(
 	pkg *devpkg.Package,
 	projectDir string,
+	builtins fs.FS,
 ) (*Config, error) {
 	if pkg.DisablePlugin {
 		return nil, nil
 	}
-	content, err := plugins.BuiltInForPackage(pkg.CanonicalName())
+	var content []byte
+	var err error
+	if builtins == nil {
+		content, err = plugins.BuiltInForPackage(pkg.CanonicalName())
+	} else {
+		content, err = plugins.ForPackage(builtins, pkg.CanonicalName())
+	}
 	if errors.Is(err, fs.ErrNotExist) {
 		return nil, nil
 	}
@@ -51,13 +67,17 @@ func getBuiltinPluginConfigIfExists(
 	return buildConfig(pkg, projectDir, string(content))
 }
 
+// GetBuiltinsForPackages returns the built-in plugins that packages
+// activate. If builtins isn't nil, the plugins are read from it instead of
+// the ones embedded in devbox.
 func GetBuiltinsForPackages(
 	packages []configfile.Package,
 	lockfile *lock.File,
+	builtins fs.FS,
 ) ([]*Config, error) {
 	builtIns := []*Config{}
 	for _, pkg := range devpkg.PackagesFromConfig(packages, lockfile) {
-		config, err := getBuiltinPluginConfigIfExists(pkg, lockfile.ProjectDir())
+		config, err := getBuiltinPluginConfigIfExists(pkg, lockfile.ProjectDir(), builtins)
 		if err != nil {
 			return nil, err
 		}
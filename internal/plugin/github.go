@@ -2,39 +2,61 @@ package plugin
 
 import (
 	"cmp"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/nix/flake"
-	"go.jetpack.io/pkg/filecache"
 )
 
-var githubCache = filecache.New[[]byte]("devbox/plugin/github")
+// githubAPIURL and githubRawURL are variables so that tests can use a fake
+// server.
+var (
+	githubAPIURL = "https://api.github.com/"
+	githubRawURL = "https://raw.githubusercontent.com/"
+)
 
 type githubPlugin struct {
 	ref  flake.Ref
 	name string
+
+	// rev is the commit that the plugin was fetched from, and root is the
+	// directory in the cache with its files.
+	rev  string
+	root string
 }
 
 // Github only allows alphanumeric, hyphen, underscore, and period in repo names.
 // but we clean up just in case.
 var githubNameRegexp = regexp.MustCompile("[^a-zA-Z0-9-_.]+")
 
-func newGithubPlugin(ref flake.Ref) (*githubPlugin, error) {
+func newGithubPlugin(
+	ctx context.Context,
+	ref flake.Ref,
+	lockfile *lock.File,
+	refetch *Refetch,
+) (*githubPlugin, error) {
 	plugin := &githubPlugin{ref: ref}
+	root, rev, err := fetchPinned(ctx, plugin.LockfileKey(), lockfile, refetch, plugin.download)
+	if err != nil {
+		return nil, err
+	}
+	plugin.root, plugin.rev = root, rev
+
 	// For backward compatibility, we don't strictly require name to be present
 	// in github plugins. If it's missing, we just use the directory as the name.
-	name, err := getPluginNameFromContent(plugin)
+	name, err := getPluginNameFromContent(ctx, plugin)
 	if err != nil && !errors.Is(err, errNameMissing) {
 		return nil, err
 	}
@@ -48,8 +70,80 @@ func newGithubPlugin(ref flake.Ref) (*githubPlugin, error) {
 	return plugin, nil
 }
 
-func (p *githubPlugin) Fetch() ([]byte, error) {
-	content, err := p.FileContent(pluginConfigName)
+// download resolves the plugin's ref to a commit, unless rev is already one,
+// and downloads the plugin's files at that commit.
+func (p *githubPlugin) download(ctx context.Context, tmp, rev string) (string, string, error) {
+	rev = cmp.Or(rev, p.ref.Rev)
+	if rev == "" {
+		var err error
+		if rev, err = p.resolveRev(ctx); err != nil {
+			return "", "", err
+		}
+	}
+	p.rev = rev
+	pluginURL, err := p.url(pluginConfigName)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := url.Parse(pluginURL)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	req, err := p.request(ctx, pluginURL)
+	if err != nil {
+		return "", "", err
+	}
+	root := filepath.Join(tmp, "root")
+	if err := downloadPluginFiles(ctx, root, u, req.Header); err != nil {
+		return "", "", usererr.WithUserMessage(err,
+			"Failed to get plugin %s @ %s. \nPlease make sure a plugin.json file "+
+				"exists in plugin directory.", p.LockfileKey(), pluginURL)
+	}
+	return root, rev, nil
+}
+
+// resolveRev returns the commit that the plugin's ref, or the repository's
+// default branch, points to.
+func (p *githubPlugin) resolveRev(ctx context.Context) (string, error) {
+	commitURL, err := url.JoinPath(
+		githubAPIURL,
+		"repos",
+		p.ref.Owner,
+		p.ref.Repo,
+		"commits",
+		cmp.Or(p.ref.Ref, "HEAD"),
+	)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req, err := p.request(ctx, commitURL)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", usererr.New(
+			"failed to resolve plugin %s to a commit (Status code %d). \nPlease make "+
+				"sure the repository and ref exist.",
+			p.LockfileKey(),
+			res.StatusCode,
+		)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (p *githubPlugin) Fetch(ctx context.Context) ([]byte, error) {
+	content, err := p.FileContent(ctx, pluginConfigName)
 	if err != nil {
 		return nil, err
 	}
@@ -64,61 +158,69 @@ func (p *githubPlugin) Hash() string {
 	return cachehash.Bytes([]byte(p.ref.String()))
 }
 
-func (p *githubPlugin) FileContent(subpath string) ([]byte, error) {
+// FileContent returns the content of a file in the plugin. Only the
+// plugin.json and the files in its create_files are downloaded and pinned with
+// the plugin, so other files are downloaded from the same commit when they're
+// first read.
+func (p *githubPlugin) FileContent(ctx context.Context, subpath string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(p.root, subpath))
+	if errors.Is(err, os.ErrNotExist) {
+		return p.lazyFileContent(ctx, subpath)
+	}
+	return content, errors.WithStack(err)
+}
+
+// lazyFileContent downloads a file that isn't pinned with the plugin. Files
+// are cached by commit, so they're only downloaded once.
+func (p *githubPlugin) lazyFileContent(ctx context.Context, subpath string) ([]byte, error) {
+	if !filepath.IsLocal(subpath) || p.rev == "" {
+		return nil, usererr.New("Plugin %s doesn't have a file %s.", p.LockfileKey(), subpath)
+	}
+	path := filepath.Join(p.root+".files", p.rev, subpath)
+	if content, err := os.ReadFile(path); err == nil {
+		return content, nil
+	}
+
 	contentURL, err := p.url(subpath)
 	if err != nil {
 		return nil, err
 	}
-	return githubCache.GetOrSet(
-		contentURL,
-		func() ([]byte, time.Duration, error) {
-			req, err := p.request(contentURL)
-			if err != nil {
-				return nil, 0, err
-			}
-
-			client := &http.Client{}
-			res, err := client.Do(req)
-			if err != nil {
-				return nil, 0, err
-			}
-			defer res.Body.Close()
-			if res.StatusCode != http.StatusOK {
-				return nil, 0, usererr.New(
-					"failed to get plugin %s @ %s (Status code %d). \nPlease make "+
-						"sure a plugin.json file exists in plugin directory.",
-					p.LockfileKey(),
-					req.URL.String(),
-					res.StatusCode,
-				)
-			}
-			body, err := io.ReadAll(res.Body)
-			if err != nil {
-				return nil, 0, err
-			}
-			// Cache for 24 hours. Once we store the plugin in the lockfile, we
-			// should cache this indefinitely and only invalidate if the plugin
-			// is updated.
-			return body, 24 * time.Hour, nil
-		},
-	)
+	req, err := p.request(ctx, contentURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Download to a temporary file so that a failed download isn't cached.
+	tmp := path + ".download"
+	if err := downloadURL(ctx, contentURL, tmp, req.Header); err != nil {
+		return nil, usererr.WithUserMessage(err,
+			"Failed to get file %s of plugin %s @ %s. \nPlease make sure the file "+
+				"exists in the plugin directory.", subpath, p.LockfileKey(), contentURL)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	content, err := os.ReadFile(path)
+	return content, errors.WithStack(err)
 }
 
 func (p *githubPlugin) url(subpath string) (string, error) {
 	// Github redirects "master" to "main" in new repos. They don't do the reverse
 	// so setting master here is better.
 	return url.JoinPath(
-		"https://raw.githubusercontent.com/",
+		githubRawURL,
 		p.ref.Owner,
 		p.ref.Repo,
-		cmp.Or(p.ref.Rev, p.ref.Ref, "master"),
+		cmp.Or(p.rev, p.ref.Rev, p.ref.Ref, "master"),
 		p.ref.Dir,
 		subpath,
 	)
 }
 
-func (p *githubPlugin) request(contentURL string) (*http.Request, error) {
-	req, err := http.NewRequest(http.MethodGet, contentURL, nil)
+func (p *githubPlugin) request(ctx context.Context, contentURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, contentURL, nil)
 	if err != nil {
 		return nil, err
 	}
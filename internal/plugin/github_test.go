@@ -1,11 +1,16 @@
 package plugin
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/nix/flake"
 )
 
@@ -118,7 +123,7 @@ func TestGithubPluginAuth(t *testing.T) {
 	t.Run("generate request for public Github repository", func(t *testing.T) {
 		url, err := githubPlugin.url("test")
 		assert.NoError(t, err)
-		actual, err := githubPlugin.request(url)
+		actual, err := githubPlugin.request(context.Background(), url)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedURL, actual.URL.String())
 		assert.Equal(t, "", actual.Header.Get("Authorization"))
@@ -128,9 +133,83 @@ func TestGithubPluginAuth(t *testing.T) {
 		t.Setenv("GITHUB_TOKEN", "gh_abcd")
 		url, err := githubPlugin.url("test")
 		assert.NoError(t, err)
-		actual, err := githubPlugin.request(url)
+		actual, err := githubPlugin.request(context.Background(), url)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedURL, actual.URL.String())
 		assert.Equal(t, "token gh_abcd", actual.Header.Get("Authorization"))
 	})
 }
+
+func TestGithubPluginPinning(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	// The fake repository has a commit for each version of the plugin, and
+	// head is the commit that the default branch points to.
+	commits := map[string]string{
+		"1111111111111111111111111111111111111111": "version: 1",
+		"2222222222222222222222222222222222222222": "version: 2",
+	}
+	head := "1111111111111111111111111111111111111111"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/repos/org/plugins/commits/HEAD" {
+			assert.Equal(t, "application/vnd.github.sha", r.Header.Get("Accept"))
+			w.Write([]byte(head))
+			return
+		}
+		for sha, processCompose := range commits {
+			switch r.URL.Path {
+			case "/raw/org/plugins/" + sha + "/postgres/plugin.json":
+				w.Write([]byte(testRemotePluginJSON))
+				return
+			case "/raw/org/plugins/" + sha + "/postgres/config/process-compose.yaml":
+				w.Write([]byte(processCompose))
+				return
+			case "/raw/org/plugins/" + sha + "/postgres/README.md":
+				w.Write([]byte("README at " + sha))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	oldAPIURL, oldRawURL := githubAPIURL, githubRawURL
+	githubAPIURL, githubRawURL = server.URL+"/api/", server.URL+"/raw/"
+	t.Cleanup(func() { githubAPIURL, githubRawURL = oldAPIURL, oldRawURL })
+
+	include := "github:org/plugins?dir=postgres"
+	lockfile := &lock.File{}
+	plugin, err := parseIncludable(context.Background(), include, "", lockfile, nil)
+	assert.NoError(t, err)
+	got, err := plugin.FileContent(context.Background(), "config/process-compose.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(got))
+	locked := lockfile.GetPlugin(plugin.LockfileKey())
+	assert.NotNil(t, locked)
+	assert.Equal(t, "1111111111111111111111111111111111111111", locked.Rev)
+
+	// Files that aren't in create_files are downloaded from the locked commit
+	// when they're read.
+	got, err = plugin.FileContent(context.Background(), "README.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "README at "+locked.Rev, string(got))
+	_, err = plugin.FileContent(context.Background(), "missing.txt")
+	assert.Error(t, err)
+
+	// The branch moves, but the plugin is still fetched at the locked commit.
+	head = "2222222222222222222222222222222222222222"
+	assert.NoError(t, os.RemoveAll(remoteCacheDir()))
+	plugin, err = parseIncludable(context.Background(), include, "", lockfile, nil)
+	assert.NoError(t, err)
+	got, err = plugin.FileContent(context.Background(), "config/process-compose.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(got))
+
+	// Updating locks the new commit.
+	refetch := NewRefetch(false, plugin.LockfileKey())
+	plugin, err = parseIncludable(context.Background(), include, "", lockfile, refetch)
+	assert.NoError(t, err)
+	got, err = plugin.FileContent(context.Background(), "config/process-compose.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(got))
+	assert.Equal(t, head, lockfile.GetPlugin(plugin.LockfileKey()).Rev)
+	assert.NotEqual(t, locked.Hash, lockfile.GetPlugin(plugin.LockfileKey()).Hash)
+}
@@ -1,31 +1,40 @@
 package plugin
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/nix/flake"
 )
 
 type Includable interface {
 	CanonicalName() string
-	FileContent(subpath string) ([]byte, error)
+	FileContent(ctx context.Context, subpath string) ([]byte, error)
 	Hash() string
 	LockfileKey() string
 }
 
-func parseIncludable(includableRef, workingDir string) (Includable, error) {
+func parseIncludable(
+	ctx context.Context,
+	includableRef, workingDir string,
+	lockfile *lock.File,
+	refetch *Refetch,
+) (Includable, error) {
 	ref, err := flake.ParseRef(includableRef)
 	if err != nil {
 		return nil, err
 	}
 	switch ref.Type {
 	case flake.TypePath:
-		return newLocalPlugin(ref, workingDir)
+		return newLocalPlugin(ctx, ref, workingDir)
 	case flake.TypeGitHub:
-		return newGithubPlugin(ref)
+		return newGithubPlugin(ctx, ref, lockfile, refetch)
+	case flake.TypeGit, flake.TypeTarball, flake.TypeFile:
+		return newRemotePlugin(ctx, ref, lockfile, refetch)
 	default:
 		return nil, fmt.Errorf("unsupported ref type %q", ref.Type)
 	}
@@ -33,7 +42,7 @@ func parseIncludable(includableRef, workingDir string) (Includable, error) {
 
 type fetcher interface {
 	Includable
-	Fetch() ([]byte, error)
+	Fetch(ctx context.Context) ([]byte, error)
 }
 
 var (
@@ -41,8 +50,8 @@ var (
 	errNameMissing = usererr.New("'name' is missing")
 )
 
-func getPluginNameFromContent(plugin fetcher) (string, error) {
-	content, err := plugin.Fetch()
+func getPluginNameFromContent(ctx context.Context, plugin fetcher) (string, error) {
+	content, err := plugin.Fetch(ctx)
 	if err != nil {
 		return "", err
 	}
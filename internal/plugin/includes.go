@@ -1,13 +1,23 @@
 package plugin
 
 import (
+	"context"
 	"strings"
 
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
 )
 
-func LoadConfigFromInclude(include string, lockfile *lock.File, workingDir string) (*Config, error) {
+// LoadConfigFromInclude loads the plugin with an include reference. Plugins
+// that devbox downloads are downloaded again if refetch selects them, and
+// downloads stop when ctx is done.
+func LoadConfigFromInclude(
+	ctx context.Context,
+	include string,
+	lockfile *lock.File,
+	refetch *Refetch,
+	workingDir string,
+) (*Config, error) {
 	var includable Includable
 	var err error
 	if t, name, _ := strings.Cut(include, ":"); t == "plugin" {
@@ -16,10 +26,10 @@ func LoadConfigFromInclude(include string, lockfile *lock.File, workingDir strin
 			lockfile,
 		)
 	} else {
-		includable, err = parseIncludable(include, workingDir)
+		includable, err = parseIncludable(ctx, include, workingDir, lockfile, refetch)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return getConfigIfAny(includable, lockfile.ProjectDir())
+	return getConfigIfAny(ctx, includable, lockfile.ProjectDir())
 }
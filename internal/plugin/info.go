@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"io"
 	"runtime/trace"
+	"slices"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
@@ -23,7 +25,7 @@ func Readme(ctx context.Context,
 ) (string, error) {
 	defer trace.StartRegion(ctx, "Readme").End()
 
-	cfg, err := getConfigIfAny(pkg, projectDir)
+	cfg, err := getConfigIfAny(ctx, pkg, projectDir)
 	if err != nil {
 		return "", err
 	}
@@ -40,7 +42,7 @@ func Readme(ctx context.Context,
 		return "", err
 	}
 
-	if err = printServices(cfg, pkg, buf, markdown); err != nil {
+	if err = printServices(ctx, cfg, pkg, buf, markdown); err != nil {
 		return "", err
 	}
 
@@ -73,12 +75,12 @@ func printReadme(cfg *Config, w io.Writer, markdown bool) error {
 	return errors.WithStack(err)
 }
 
-func printServices(cfg *Config, pkg *devpkg.Package, w io.Writer, markdown bool) error {
+func printServices(ctx context.Context, cfg *Config, pkg *devpkg.Package, w io.Writer, markdown bool) error {
 	_, contentPath := cfg.ProcessComposeYaml()
 	if contentPath == "" {
 		return nil
 	}
-	content, err := pkg.FileContent(contentPath)
+	content, err := pkg.FileContent(ctx, contentPath)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -151,3 +153,73 @@ func printInfoInstructions(pkg string, w io.Writer) error {
 	)
 	return errors.WithStack(err)
 }
+
+// BuiltinPluginName returns the name of the built-in plugin that is used for
+// pkg, or an empty string if there isn't one.
+func BuiltinPluginName(pkg *devpkg.Package, projectDir string) (string, error) {
+	cfg, err := getBuiltinPluginConfigIfExists(pkg, projectDir, nil /*builtins*/)
+	if err != nil || cfg == nil {
+		return "", err
+	}
+	return cfg.Name, nil
+}
+
+// SourceType returns where an included plugin comes from: builtin, local,
+// github, git, tarball, or file.
+func SourceType(inc Includable) string {
+	switch inc := inc.(type) {
+	case *devpkg.Package:
+		return "builtin"
+	case *LocalPlugin:
+		return "local"
+	case *githubPlugin:
+		return "github"
+	case *remotePlugin:
+		return inc.ref.Type
+	}
+	return ""
+}
+
+// ServiceNames returns the names of the services in the plugin's
+// process-compose.yaml, without creating it.
+func (c *Config) ServiceNames(ctx context.Context) ([]string, error) {
+	_, contentPath := c.ProcessComposeYaml()
+	if contentPath == "" {
+		return nil, nil
+	}
+	content, err := c.Source.FileContent(ctx, contentPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names, err := services.NamesFromProcessCompose(content)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// ScriptPrefix returns the prefix of the names that a plugin's scripts can be
+// run with, such as "postgresql:". It returns an empty string for plugins
+// without a name, or with a name that can't be part of a script file name.
+func ScriptPrefix(pluginName string) string {
+	if pluginName == "" || strings.ContainsAny(pluginName, "/\\ \t\n") {
+		return ""
+	}
+	return pluginName + ":"
+}
+
+// ScriptNames returns the qualified names of the plugin's scripts, such as
+// postgresql:setup. See ScriptPrefix.
+func (c *Config) ScriptNames() []string {
+	prefix := ScriptPrefix(c.Name)
+	if prefix == "" {
+		return nil
+	}
+	names := []string{}
+	for name := range c.Scripts() {
+		names = append(names, prefix+name)
+	}
+	slices.Sort(names)
+	return names
+}
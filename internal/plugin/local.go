@@ -1,12 +1,16 @@
 package plugin
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
 	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/fileutil"
 	"go.jetpack.io/devbox/nix/flake"
 )
 
@@ -16,18 +20,29 @@ type LocalPlugin struct {
 	pluginDir string
 }
 
-func newLocalPlugin(ref flake.Ref, pluginDir string) (*LocalPlugin, error) {
+// localNameRegexp matches characters that are not allowed in plugin names. It
+// is used to derive a name for included devbox.json files that don't set one.
+var localNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9_\- ]+`)
+
+func newLocalPlugin(ctx context.Context, ref flake.Ref, pluginDir string) (*LocalPlugin, error) {
 	plugin := &LocalPlugin{ref: ref, pluginDir: pluginDir}
-	name, err := getPluginNameFromContent(plugin)
-	if err != nil {
+	name, err := getPluginNameFromContent(ctx, plugin)
+	// Unlike plugins, devbox.json files rarely have a name, so we fall back to
+	// the name of the directory that contains them.
+	if errors.Is(err, errNameMissing) && plugin.IsDevboxConfig() {
+		name = localNameRegexp.ReplaceAllString(
+			filepath.Base(filepath.Dir(plugin.Path())),
+			"-",
+		)
+	} else if err != nil {
 		return nil, err
 	}
 	plugin.name = name
 	return plugin, nil
 }
 
-func (l *LocalPlugin) Fetch() ([]byte, error) {
-	content, err := os.ReadFile(addFilenameIfMissing(l.Path()))
+func (l *LocalPlugin) Fetch(_ context.Context) ([]byte, error) {
+	content, err := os.ReadFile(l.Path())
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -42,11 +57,17 @@ func (l *LocalPlugin) IsLocal() bool {
 	return true
 }
 
+// IsDevboxConfig returns true if the include points to a devbox.json file
+// rather than a plugin.json.
+func (l *LocalPlugin) IsDevboxConfig() bool {
+	return filepath.Base(l.Path()) == configfile.DefaultName
+}
+
 func (l *LocalPlugin) Hash() string {
 	return cachehash.Bytes([]byte(filepath.Clean(l.Path())))
 }
 
-func (l *LocalPlugin) FileContent(subpath string) ([]byte, error) {
+func (l *LocalPlugin) FileContent(_ context.Context, subpath string) ([]byte, error) {
 	return os.ReadFile(filepath.Join(filepath.Dir(l.Path()), subpath))
 }
 
@@ -54,20 +75,21 @@ func (l *LocalPlugin) LockfileKey() string {
 	return l.ref.String()
 }
 
+// Path returns the absolute path to the included file. The ref may point
+// directly to a .json file or to a directory. Directories must contain a
+// plugin.json or, failing that, a devbox.json.
 func (l *LocalPlugin) Path() string {
 	path := l.ref.Path
-	if !strings.HasSuffix(path, pluginConfigName) {
-		path = filepath.Join(path, pluginConfigName)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.pluginDir, path)
 	}
-	if filepath.IsAbs(path) {
+	if strings.HasSuffix(path, ".json") {
 		return path
 	}
-	return filepath.Join(l.pluginDir, path)
-}
-
-func addFilenameIfMissing(s string) string {
-	if strings.HasSuffix(s, pluginConfigName) {
-		return s
+	devboxConfigPath := filepath.Join(path, configfile.DefaultName)
+	if !fileutil.Exists(filepath.Join(path, pluginConfigName)) &&
+		fileutil.Exists(devboxConfigPath) {
+		return devboxConfigPath
 	}
-	return filepath.Join(s, pluginConfigName)
+	return filepath.Join(path, pluginConfigName)
 }
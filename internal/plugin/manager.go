@@ -11,6 +11,7 @@ type Manager struct {
 	devboxProject
 
 	lockfile *lock.File
+	refetch  *Refetch
 }
 
 type devboxProject interface {
@@ -21,7 +22,7 @@ type devboxProject interface {
 type managerOption func(*Manager)
 
 func NewManager(opts ...managerOption) *Manager {
-	m := &Manager{}
+	m := &Manager{refetch: NewRefetch(false)}
 	m.ApplyOptions(opts...)
 	return m
 }
@@ -32,6 +33,14 @@ func WithLockfile(lockfile *lock.File) managerOption {
 	}
 }
 
+// WithRefetch selects the plugins that are downloaded again when they're
+// loaded.
+func WithRefetch(refetch *Refetch) managerOption {
+	return func(m *Manager) {
+		m.refetch = refetch
+	}
+}
+
 func WithDevbox(provider devboxProject) managerOption {
 	return func(m *Manager) {
 		m.devboxProject = provider
@@ -43,3 +52,8 @@ func (m *Manager) ApplyOptions(opts ...managerOption) {
 		opt(m)
 	}
 }
+
+// Refetch returns the plugins that are downloaded again when they're loaded.
+func (m *Manager) Refetch() *Refetch {
+	return m.refetch
+}
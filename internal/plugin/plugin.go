@@ -6,6 +6,7 @@ package plugin
 import (
 	"bytes"
 	"cmp"
+	"context"
 	"encoding/json"
 	"io/fs"
 	"log/slog"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/tailscale/hujson"
+	"go.jetpack.io/devbox/internal/devbox/shellcmd"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
@@ -46,23 +48,91 @@ type PluginOnlyData struct {
 	DeprecatedDescription string            `json:"readme"`
 	// If true, we remove the package that triggered this plugin from the environment
 	// Useful when we want to replace with flake
+	//
+	// Only built-in plugins use this. Plugins can add packages, but a
+	// built-in plugin like php replaces the package that activated it
+	// with a flake that it builds from that package, so the package must
+	// stay in devbox.json and devbox.lock to pin the version that the
+	// flake uses, without being installed itself. Packages from other
+	// includes can't be removed this way.
 	RemoveTriggerPackage bool   `json:"__remove_trigger_package,omitempty"`
 	Version              string `json:"version"`
+	// Hooks are commands that run when the project's packages change.
+	Hooks Hooks `json:"hooks,omitempty"`
+	// ports are the ports that plugin.json uses with the port template
+	// function, keyed by name, with their preferred port as the value.
+	ports map[string]int
 	// Source is the includable that triggered this plugin. There are two ways to include a plugin:
 	// 1. Built-in plugins are triggered by packages (See plugins.builtInMap)
 	// 2. Plugins can be added via the "include" field in devbox.json or plugin.json
 	Source Includable
 }
 
+// HookEvent is a change to a project that runs plugin hooks.
+type HookEvent string
+
+const (
+	// OnInstall runs every time `devbox add`, `devbox update` or
+	// `devbox install` installs the project's packages.
+	OnInstall HookEvent = "on_install"
+	// OnAdd runs once when a plugin is added to a project, such as by adding
+	// a package with a built-in plugin.
+	OnAdd HookEvent = "on_add"
+	// OnRemove runs once when a plugin is removed from a project.
+	OnRemove HookEvent = "on_remove"
+	// OnUpdate runs when `devbox update` or `devbox plugin update` updates
+	// a project.
+	OnUpdate HookEvent = "on_update"
+)
+
+// Hooks are the commands that a plugin runs for each HookEvent. They run in
+// the devbox environment from the project directory.
+type Hooks struct {
+	OnInstall *shellcmd.Commands `json:"on_install,omitempty"`
+	OnAdd     *shellcmd.Commands `json:"on_add,omitempty"`
+	OnRemove  *shellcmd.Commands `json:"on_remove,omitempty"`
+	OnUpdate  *shellcmd.Commands `json:"on_update,omitempty"`
+}
+
+// Hook returns the commands that the plugin runs for event, or nil if it
+// doesn't have any.
+func (c *Config) Hook(event HookEvent) *shellcmd.Commands {
+	var hook *shellcmd.Commands
+	switch event {
+	case OnInstall:
+		hook = c.Hooks.OnInstall
+	case OnAdd:
+		hook = c.Hooks.OnAdd
+	case OnRemove:
+		hook = c.Hooks.OnRemove
+	case OnUpdate:
+		hook = c.Hooks.OnUpdate
+	}
+	if hook == nil || len(hook.Cmds) == 0 {
+		return nil
+	}
+	return hook
+}
+
+// Ports returns the ports that the plugin uses, keyed by name, with their
+// preferred port as the value. They are assigned with AllocatePorts.
+func (c *Config) Ports() map[string]int {
+	return c.ports
+}
+
 func (c *Config) ProcessComposeYaml() (string, string) {
 	for file, contentPath := range c.CreateFiles {
-		if strings.HasSuffix(file, "process-compose.yaml") || strings.HasSuffix(file, "process-compose.yml") {
+		if isProcessComposeFile(file) {
 			return file, contentPath
 		}
 	}
 	return "", ""
 }
 
+func isProcessComposeFile(path string) bool {
+	return strings.HasSuffix(path, "process-compose.yaml") || strings.HasSuffix(path, "process-compose.yml")
+}
+
 func (c *Config) Services() (services.Services, error) {
 	if file, _ := c.ProcessComposeYaml(); file != "" {
 		return services.FromProcessCompose(file)
@@ -70,7 +140,7 @@ func (c *Config) Services() (services.Services, error) {
 	return nil, nil
 }
 
-func (m *Manager) CreateFilesForConfig(cfg *Config) error {
+func (m *Manager) CreateFilesForConfig(ctx context.Context, cfg *Config) error {
 	virtenvPath := filepath.Join(m.ProjectDir(), VirtenvPath)
 	pkg := cfg.Source
 	locked := m.lockfile.Packages[pkg.LockfileKey()]
@@ -100,7 +170,7 @@ func (m *Manager) CreateFilesForConfig(cfg *Config) error {
 			continue
 		}
 
-		if err := m.createFile(pkg, filePath, contentPath, virtenvPath); err != nil {
+		if err := m.createFile(ctx, pkg, filePath, contentPath, virtenvPath); err != nil {
 			return err
 		}
 
@@ -114,79 +184,205 @@ func (m *Manager) CreateFilesForConfig(cfg *Config) error {
 }
 
 func (m *Manager) createFile(
+	ctx context.Context,
 	pkg Includable,
 	filePath, contentPath, virtenvPath string,
 ) error {
-	name := pkg.CanonicalName()
 	slog.Debug("Creating file %q from contentPath: %q", filePath, contentPath)
-	content, err := pkg.FileContent(contentPath)
+	content, err := m.renderFile(ctx, pkg, filePath, contentPath, virtenvPath)
 	if err != nil {
+		return err
+	}
+	var fileMode fs.FileMode = 0o644
+	if strings.Contains(filePath, "bin/") {
+		fileMode = 0o755
+	}
+
+	if err := os.WriteFile(filePath, content, fileMode); err != nil {
 		return errors.WithStack(err)
 	}
-	tmpl, err := template.New(filePath + "-template").Parse(string(content))
+	if fileMode == 0o755 {
+		if err := createSymlink(m.ProjectDir(), filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderFile returns the content of a file in create_files after templating.
+func (m *Manager) renderFile(
+	ctx context.Context,
+	pkg Includable,
+	filePath, contentPath, virtenvPath string,
+) ([]byte, error) {
+	content, err := pkg.FileContent(ctx, contentPath)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
+	data, err := fileTemplateData(
+		pkg,
+		m.ProjectDir(),
+		virtenvPath,
+		m.AllPackageNamesIncludingRemovedTriggerPackages(),
+		nix.System(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(filePath, string(content), m.ProjectDir(), data, nil, false /*strict*/)
+}
 
+// fileTemplateData returns the variables that files in create_files can use.
+func fileTemplateData(
+	pkg Includable,
+	projectDir, virtenvPath string,
+	packages []string,
+	system string,
+) (map[string]any, error) {
 	var urlForInput, attributePath string
-
 	if pkg, ok := pkg.(*devpkg.Package); ok {
+		var err error
 		attributePath, err = pkg.PackageAttributePath()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		urlForInput = pkg.URLForFlakeInput()
 	}
 
-	var buf bytes.Buffer
-	if err = tmpl.Execute(&buf, map[string]any{
-		"DevboxDir":            filepath.Join(m.ProjectDir(), devboxDirName, name),
-		"DevboxDirRoot":        filepath.Join(m.ProjectDir(), devboxDirName),
-		"DevboxProfileDefault": filepath.Join(m.ProjectDir(), nix.ProfilePath),
+	name := pkg.CanonicalName()
+	return map[string]any{
+		"DevboxDir":            filepath.Join(projectDir, devboxDirName, name),
+		"DevboxDirRoot":        filepath.Join(projectDir, devboxDirName),
+		"DevboxProfileDefault": filepath.Join(projectDir, nix.ProfilePath),
 		"PackageAttributePath": attributePath,
-		"Packages":             m.AllPackageNamesIncludingRemovedTriggerPackages(),
-		"System":               nix.System(),
+		"Packages":             packages,
+		"System":               system,
 		"URLForInput":          urlForInput,
 		"Virtenv":              filepath.Join(virtenvPath, name),
-	}); err != nil {
-		return errors.WithStack(err)
-	}
-	var fileMode fs.FileMode = 0o644
-	if strings.Contains(filePath, "bin/") {
-		fileMode = 0o755
-	}
-
-	if err := os.WriteFile(filePath, buf.Bytes(), fileMode); err != nil {
-		return errors.WithStack(err)
-	}
-	if fileMode == 0o755 {
-		if err := createSymlink(m.ProjectDir(), filePath); err != nil {
-			return err
-		}
-	}
-	return nil
+	}, nil
 }
 
 // buildConfig returns a plugin.Config
 func buildConfig(pkg Includable, projectDir, content string) (*Config, error) {
-	cfg := &Config{PluginOnlyData: PluginOnlyData{Source: pkg}}
-	name := pkg.CanonicalName()
-	t, err := template.New(name + "-template").Parse(content)
+	ports := map[string]int{}
+	rendered, err := renderTemplate(
+		pkg.CanonicalName(),
+		content,
+		projectDir,
+		configTemplateData(pkg.CanonicalName(), projectDir),
+		ports,
+		false, /*strict*/
+	)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
-	var buf bytes.Buffer
-	if err = t.Execute(&buf, map[string]string{
+	cfg, err := parseConfig(pkg, rendered)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ports = ports
+	return cfg, nil
+}
+
+// configTemplateData returns the variables that plugin.json can use.
+func configTemplateData(name, projectDir string) map[string]any {
+	return map[string]any{
 		"DevboxProjectDir":     projectDir,
 		"DevboxDir":            filepath.Join(projectDir, devboxDirName, name),
 		"DevboxDirRoot":        filepath.Join(projectDir, devboxDirName),
 		"DevboxProfileDefault": filepath.Join(projectDir, nix.ProfilePath),
 		"Virtenv":              filepath.Join(projectDir, VirtenvPath, name),
-	}); err != nil {
+	}
+}
+
+// renderTemplate executes a plugin template with data. The ports that the
+// template uses are recorded in ports, if it isn't nil. If strict is true,
+// using a variable that isn't in data is an error instead of printing
+// "<no value>", which `devbox plugin validate` uses to catch typos.
+func renderTemplate(
+	name, content, projectDir string,
+	data map[string]any,
+	ports map[string]int,
+	strict bool,
+) ([]byte, error) {
+	tmpl := template.New(name + "-template").Funcs(templateFuncs(projectDir, ports))
+	if strict {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+	tmpl, err := tmpl.Parse(content)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
 		return nil, errors.WithStack(err)
 	}
+	return buf.Bytes(), nil
+}
 
-	jsonb, err := jsonPurifyPluginContent(buf.Bytes())
+// templateFuncs returns the functions available to plugin templates. Calls
+// to port are recorded in ports, if it isn't nil.
+func templateFuncs(projectDir string, ports map[string]int) template.FuncMap {
+	return template.FuncMap{
+		// port returns the port assigned to the project for a name, such as
+		// {{ port "PGPORT" 5432 }}, so that it doesn't clash with other
+		// projects. Rendering never assigns ports, so that read-only commands
+		// don't change the port registry. Until AllocatePorts assigns one,
+		// port returns the preferred port.
+		"port": func(name string, preferred int) int {
+			if ports != nil {
+				ports[name] = preferred
+			}
+			if projectDir == "" {
+				return preferred
+			}
+			if port, ok := services.LookupPort(projectDir, name); ok {
+				return port
+			}
+			return preferred
+		},
+	}
+}
+
+// AllocatePorts assigns a port in the port registry to every port that
+// configs use, and releases the ports that projectDir no longer uses, except
+// for the process-compose port. It returns true if any port was newly
+// assigned, in which case configs must be reloaded to use it. Only commands
+// that install or start the project call it, so that read-only commands
+// don't change the registry.
+func AllocatePorts(projectDir string, configs []*Config) (bool, error) {
+	used := map[string]bool{services.ProcessComposePortName: true}
+	allocated := false
+	for _, cfg := range configs {
+		for name, preferred := range cfg.Ports() {
+			used[name] = true
+			if _, ok := services.LookupPort(projectDir, name); ok {
+				continue
+			}
+			if _, err := services.AllocatePort(projectDir, name, preferred); err != nil {
+				return false, err
+			}
+			allocated = true
+		}
+	}
+	stale := map[string]bool{}
+	for name := range services.ProjectPorts(projectDir) {
+		if !used[name] {
+			stale[name] = true
+		}
+	}
+	if len(stale) == 0 {
+		return allocated, nil
+	}
+	_, err := services.ReleasePorts(projectDir, func(name string) bool { return stale[name] })
+	return allocated, err
+}
+
+// parseConfig returns a plugin.Config from content that has already been
+// templated (or doesn't need to be).
+func parseConfig(pkg Includable, content []byte) (*Config, error) {
+	cfg := &Config{PluginOnlyData: PluginOnlyData{Source: pkg}}
+	jsonb, err := jsonPurifyPluginContent(content)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,51 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"testing"
+
+	"go.jetpack.io/devbox/internal/services"
+)
+
+func TestPortTemplateIsReadOnly(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	projectDir := t.TempDir()
+	pkg := &LocalPlugin{name: "db"}
+
+	cfg, err := buildConfig(pkg, projectDir,
+		`{"name": "db", "env": {"DB_PORT": "{{ port "DB_PORT" 15432 }}"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Env["DB_PORT"]; got != "15432" {
+		t.Errorf("got DB_PORT=%s before allocating, want the preferred port 15432", got)
+	}
+	if ports := services.ProjectPorts(projectDir); len(ports) != 0 {
+		t.Fatalf("got ports %v after rendering, want none", ports)
+	}
+
+	allocated, err := AllocatePorts(projectDir, []*Config{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !allocated {
+		t.Error("got allocated=false for a new port, want true")
+	}
+	port, ok := services.LookupPort(projectDir, "DB_PORT")
+	if !ok {
+		t.Fatal("DB_PORT isn't assigned after AllocatePorts")
+	}
+	if allocated, _ := AllocatePorts(projectDir, []*Config{cfg}); allocated {
+		t.Error("got allocated=true for an assigned port, want false")
+	}
+
+	// Ports that no plugin uses anymore are released.
+	if _, err := AllocatePorts(projectDir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := services.LookupPort(projectDir, "DB_PORT"); ok {
+		t.Errorf("DB_PORT is still assigned to port %d after its plugin was removed", port)
+	}
+}
@@ -0,0 +1,498 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/pullbox/tar"
+	"go.jetpack.io/devbox/internal/xdg"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// Refetch selects plugins that devbox downloads, such as plugins in GitHub
+// repositories or tarballs, that should be downloaded again when they're
+// loaded and locked at their new revision and content, instead of using the
+// cache and failing to load if their content changed. Plugins are only
+// downloaded again once, even if they're loaded multiple times. A nil
+// *Refetch doesn't download any plugins again.
+type Refetch struct {
+	all  bool
+	refs map[string]bool
+	done map[string]bool
+}
+
+// NewRefetch returns a Refetch for the plugins with the given include
+// references, or for every plugin if all is true.
+func NewRefetch(all bool, refs ...string) *Refetch {
+	r := &Refetch{all: all, refs: map[string]bool{}, done: map[string]bool{}}
+	r.Add(refs...)
+	return r
+}
+
+// Add selects more plugins to download again by their include references.
+func (r *Refetch) Add(refs ...string) {
+	for _, ref := range refs {
+		r.refs[ref] = true
+	}
+}
+
+func (r *Refetch) should(ref string) bool {
+	return r != nil && (r.all || r.refs[ref]) && !r.done[ref]
+}
+
+// remoteCacheDir is where remote plugins are downloaded to. Each plugin has
+// its own directory, named after a hash of its include reference.
+func remoteCacheDir() string {
+	return xdg.CacheSubpath("devbox/plugin/remote")
+}
+
+// remotePlugin is a plugin in a git repository, a tarball, or a plugin.json
+// served over HTTP. All of a remote plugin's files are downloaded when it's
+// loaded so that they can be hashed and pinned in devbox.lock.
+type remotePlugin struct {
+	ref  flake.Ref
+	name string
+
+	// root is the directory in the cache with the plugin's files.
+	root string
+}
+
+func newRemotePlugin(
+	ctx context.Context,
+	ref flake.Ref,
+	lockfile *lock.File,
+	refetch *Refetch,
+) (*remotePlugin, error) {
+	plugin := &remotePlugin{ref: ref}
+	root, _, err := fetchPinned(ctx, plugin.LockfileKey(), lockfile, refetch, plugin.download)
+	if err != nil {
+		return nil, err
+	}
+	plugin.root = root
+	name, err := getPluginNameFromContent(ctx, plugin)
+	if err != nil {
+		return nil, err
+	}
+	plugin.name = name
+	return plugin, nil
+}
+
+// downloadFunc downloads a plugin's files to a directory in tmp. If rev
+// isn't empty, the files must come from that commit. It returns the
+// directory and the commit that the files came from, if the plugin has
+// commits.
+type downloadFunc func(ctx context.Context, tmp, rev string) (root, resolvedRev string, err error)
+
+// fetchPinned makes sure that a plugin's files are in the cache and match
+// the revision and hash in devbox.lock, downloading them if needed. Plugins
+// that aren't locked yet are locked, and plugins that refetch selects are
+// downloaded and locked again. It returns the directory in the cache with
+// the plugin's files and the revision they came from.
+func fetchPinned(
+	ctx context.Context,
+	key string,
+	lockfile *lock.File,
+	refetch *Refetch,
+	download downloadFunc,
+) (string, string, error) {
+	var locked *lock.Plugin
+	if lockfile != nil {
+		locked = lockfile.GetPlugin(key)
+	}
+	setPin := func(rev, hash string) {
+		if lockfile != nil && (locked == nil || locked.Rev != rev || locked.Hash != hash) {
+			lockfile.LockPlugin(key, &lock.Plugin{Rev: rev, Hash: hash})
+		}
+	}
+
+	refetching := refetch.should(key)
+	cached := filepath.Join(remoteCacheDir(), cachehash.Bytes([]byte(key)))
+	// The revision isn't part of the files, so it's stored next to them.
+	revFile := cached + ".rev"
+	if !refetching && fileutil.IsDir(cached) {
+		rev, _ := os.ReadFile(revFile)
+		hash, err := contentHash(cached)
+		if err == nil && (locked == nil || (locked.Hash == hash && locked.Rev == string(rev))) {
+			setPin(string(rev), hash)
+			return cached, string(rev), nil
+		}
+	}
+	if envir.IsOffline() {
+		return "", "", usererr.New("Cannot fetch plugin %s in offline mode because it isn't cached.", key)
+	}
+
+	if err := os.MkdirAll(remoteCacheDir(), 0o755); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	tmp, err := os.MkdirTemp(remoteCacheDir(), ".download-")
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	pinnedRev := ""
+	if locked != nil && !refetching {
+		pinnedRev = locked.Rev
+	}
+	root, rev, err := download(ctx, tmp, pinnedRev)
+	if err != nil {
+		return "", "", usererr.WithUserMessage(err, "Failed to fetch plugin %s", key)
+	}
+	hash, err := contentHash(root)
+	if err != nil {
+		return "", "", err
+	}
+	if locked != nil && locked.Hash != hash && !refetching {
+		return "", "", usererr.New(
+			"Plugin %s has changed since it was locked in devbox.lock (expected %s, got %s). "+
+				"Run `devbox plugin update %s` to use the new version.",
+			key, locked.Hash, hash, key)
+	}
+
+	if err := os.RemoveAll(cached); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	if err := os.Rename(root, cached); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	if err := os.WriteFile(revFile, []byte(rev), 0o644); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	if refetching {
+		refetch.done[key] = true
+	}
+	setPin(rev, hash)
+	return cached, rev, nil
+}
+
+// download downloads the plugin's files depending on the type of its
+// include reference.
+func (p *remotePlugin) download(ctx context.Context, tmp, rev string) (string, string, error) {
+	switch p.ref.Type {
+	case flake.TypeGit:
+		return p.downloadGit(ctx, tmp, rev)
+	case flake.TypeTarball:
+		root, err := p.downloadTarball(ctx, tmp)
+		return root, "", err
+	default:
+		root, err := p.downloadFile(ctx, tmp)
+		return root, "", err
+	}
+}
+
+// downloadGit does a shallow fetch of the plugin's repository at rev, or
+// its rev or ref if rev is empty, or the default branch if neither is set.
+func (p *remotePlugin) downloadGit(ctx context.Context, tmp, rev string) (string, string, error) {
+	repoURL, err := withoutDirParam(p.ref.URL)
+	if err != nil {
+		return "", "", err
+	}
+	root := filepath.Join(tmp, "root")
+	target := cmp.Or(rev, p.ref.Rev, p.ref.Ref, "HEAD")
+	git := func(args ...string) (string, error) {
+		out, err := exec.CommandContext(ctx, "git", args...).CombinedOutput()
+		if err != nil {
+			return "", errors.Errorf("git %s: %v: %s", args[len(args)-1], err, out)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	if _, err := git("init", "--quiet", root); err != nil {
+		return "", "", err
+	}
+	if _, err := git("-C", root, "fetch", "--quiet", "--depth", "1", repoURL, target); err != nil {
+		return "", "", err
+	}
+	if _, err := git("-C", root, "checkout", "--quiet", "FETCH_HEAD"); err != nil {
+		return "", "", err
+	}
+	resolvedRev, err := git("-C", root, "rev-parse", "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+	return root, resolvedRev, errors.WithStack(os.RemoveAll(filepath.Join(root, ".git")))
+}
+
+// downloadTarball downloads and extracts the plugin's archive. Like nix, if
+// the archive has a single top-level directory, that directory is the root.
+func (p *remotePlugin) downloadTarball(ctx context.Context, tmp string) (string, error) {
+	archiveURL, err := withoutDirParam(p.ref.URL)
+	if err != nil {
+		return "", err
+	}
+	archive := filepath.Join(tmp, "archive")
+	if err := downloadURL(ctx, archiveURL, archive, nil); err != nil {
+		return "", err
+	}
+	root := filepath.Join(tmp, "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		return "", errors.WithStack(err)
+	}
+	if err := tar.ExtractFile(archive, root); err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(root, entries[0].Name()), nil
+	}
+	return root, nil
+}
+
+// downloadFile downloads a plugin.json from its URL, along with the files
+// in its create_files, which are relative to it.
+func (p *remotePlugin) downloadFile(ctx context.Context, tmp string) (string, error) {
+	pluginURL, err := url.Parse(p.ref.URL)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if !strings.HasSuffix(pluginURL.Path, ".json") {
+		pluginURL = pluginURL.JoinPath(pluginConfigName)
+	}
+	root := filepath.Join(tmp, "root")
+	return root, downloadPluginFiles(ctx, root, pluginURL, nil)
+}
+
+// downloadPluginFiles downloads the plugin.json at pluginURL to dir, along
+// with the files in its create_files, which are relative to it.
+func downloadPluginFiles(
+	ctx context.Context,
+	dir string,
+	pluginURL *url.URL,
+	header http.Header,
+) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	pluginPath := filepath.Join(dir, pluginConfigName)
+	if err := downloadURL(ctx, pluginURL.String(), pluginPath, header); err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(pluginPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	content, err = jsonPurifyPluginContent(content)
+	if err != nil {
+		return err
+	}
+	cfg := struct {
+		CreateFiles map[string]string `json:"create_files"`
+	}{}
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, contentPath := range cfg.CreateFiles {
+		if contentPath == "" {
+			continue
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(contentPath))
+		if !strings.HasPrefix(dst, dir+string(filepath.Separator)) {
+			return errors.Errorf("create_files path %q is outside of the plugin", contentPath)
+		}
+		rel, err := url.Parse(contentPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// header can have credentials for the plugin's host, so files must
+		// come from the same place as the plugin.json.
+		fileURL := pluginURL.ResolveReference(rel)
+		if fileURL.Scheme != pluginURL.Scheme || fileURL.Host != pluginURL.Host {
+			return errors.Errorf("create_files path %q is outside of the plugin", contentPath)
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := downloadURL(ctx, fileURL.String(), dst, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadTimeout is how long a plugin download can take, including reading
+// the response. It's long enough for plugin tarballs on a slow connection,
+// but keeps an unresponsive server from hanging devbox.
+const downloadTimeout = 2 * time.Minute
+
+// httpClient downloads plugins and their files.
+var httpClient = &http.Client{Timeout: downloadTimeout}
+
+// downloadURL writes the contents of an http, https, or file URL to path.
+// header is added to http requests.
+func downloadURL(ctx context.Context, rawURL, path string, header http.Header) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var body io.ReadCloser
+	if u.Scheme == "file" {
+		if body, err = os.Open(u.Path); err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		if envir.IsOffline() {
+			return usererr.New("Cannot download %s in offline mode.", rawURL)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		maps.Copy(req.Header, header)
+		res, err := httpClient.Do(req)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return errors.Errorf("GET %s: %s", rawURL, res.Status)
+		}
+		body = res.Body
+	}
+	defer body.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+// withoutDirParam removes the dir query parameter, which is part of the
+// include reference rather than the URL to download.
+func withoutDirParam(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	q := u.Query()
+	if !q.Has("dir") {
+		return rawURL, nil
+	}
+	q.Del("dir")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// contentHash returns the SHA-256 hash of the files in dir in SRI format. It
+// covers each file's path, whether it's executable, and its contents, so it
+// doesn't change when the files are downloaded again.
+func contentHash(dir string) (string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	slices.Sort(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		fi, err := os.Lstat(path)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		switch {
+		case fi.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			fmt.Fprintf(h, "symlink %s %s\x00", filepath.ToSlash(rel), target)
+		case fi.Mode().IsRegular():
+			fmt.Fprintf(h, "file %s %t %d\x00", filepath.ToSlash(rel), fi.Mode()&0o111 != 0, fi.Size())
+			f, err := os.Open(path)
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+		}
+	}
+	return "sha256-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// dir returns the directory with the plugin's plugin.json.
+func (p *remotePlugin) dir() string {
+	if p.ref.Type == flake.TypeFile {
+		return p.root
+	}
+	return filepath.Join(p.root, filepath.FromSlash(p.ref.Dir))
+}
+
+func (p *remotePlugin) Fetch(ctx context.Context) ([]byte, error) {
+	content, err := p.FileContent(ctx, pluginConfigName)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPurifyPluginContent(content)
+}
+
+func (p *remotePlugin) CanonicalName() string {
+	return p.name
+}
+
+func (p *remotePlugin) Hash() string {
+	return cachehash.Bytes([]byte(p.ref.String()))
+}
+
+func (p *remotePlugin) FileContent(_ context.Context, subpath string) ([]byte, error) {
+	if !filepath.IsLocal(subpath) {
+		return nil, usererr.New("Plugin %s doesn't have a file %s.", p.LockfileKey(), subpath)
+	}
+	content, err := os.ReadFile(filepath.Join(p.dir(), subpath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, usererr.New(
+			"Plugin %s doesn't have a file %s. \nPlease make sure a plugin.json file "+
+				"exists in the plugin directory.", p.LockfileKey(), subpath)
+	}
+	return content, errors.WithStack(err)
+}
+
+func (p *remotePlugin) LockfileKey() string {
+	return p.ref.String()
+}
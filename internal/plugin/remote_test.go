@@ -0,0 +1,223 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+const testRemotePluginJSON = `{
+  "name": "my-postgres",
+  "version": "0.0.1",
+  // Comments are allowed, like in other plugins.
+  "create_files": {
+    "{{ .Virtenv }}/process-compose.yaml": "config/process-compose.yaml"
+  }
+}`
+
+// writeTestPlugin writes a plugin with a process-compose.yaml to dir.
+func writeTestPlugin(t *testing.T, dir, processCompose string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, pluginConfigName), []byte(testRemotePluginJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(filepath.Join(dir, "config", "process-compose.yaml"), []byte(processCompose), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runCommand(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func assertProcessCompose(t *testing.T, plugin Includable, want string) {
+	t.Helper()
+	if name := plugin.CanonicalName(); name != "my-postgres" {
+		t.Errorf("got plugin name %q, want my-postgres", name)
+	}
+	got, err := plugin.FileContent(context.Background(), "config/process-compose.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got process-compose.yaml %q, want %q", got, want)
+	}
+	if _, err := plugin.FileContent(context.Background(), "../plugin.json"); err == nil {
+		t.Error("got no error for a file outside of the plugin")
+	}
+}
+
+func TestRemotePluginTarball(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	src := t.TempDir()
+	archive := filepath.Join(t.TempDir(), "plugins.tar.gz")
+	writeTestPlugin(t, filepath.Join(src, "plugins-1.0", "postgres"), "version: 1")
+	runCommand(t, src, "tar", "-czf", archive, ".")
+
+	include := "file://" + archive + "?dir=postgres"
+	lockfile := &lock.File{}
+	plugin, err := parseIncludable(context.Background(), include, "", lockfile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertProcessCompose(t, plugin, "version: 1")
+	locked := lockfile.GetPlugin(plugin.LockfileKey())
+	if locked == nil || !strings.HasPrefix(locked.Hash, "sha256-") {
+		t.Fatalf("got locked plugin %+v, want a sha256 hash", locked)
+	}
+
+	// The plugin changes upstream. The cached copy matches the lockfile, so
+	// it's still used.
+	writeTestPlugin(t, filepath.Join(src, "plugins-1.0", "postgres"), "version: 2")
+	runCommand(t, src, "tar", "-czf", archive, ".")
+	if plugin, err = parseIncludable(context.Background(), include, "", lockfile, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertProcessCompose(t, plugin, "version: 1")
+
+	// Without the cache, the new content doesn't match the lockfile.
+	if err := os.RemoveAll(remoteCacheDir()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseIncludable(context.Background(), include, "", lockfile, nil); err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Fatalf("got error %v loading a changed plugin, want a lockfile mismatch", err)
+	}
+
+	// Updating locks the new content.
+	refetch := NewRefetch(false, plugin.LockfileKey())
+	if plugin, err = parseIncludable(context.Background(), include, "", lockfile, refetch); err != nil {
+		t.Fatal(err)
+	}
+	assertProcessCompose(t, plugin, "version: 2")
+	if lockfile.GetPlugin(plugin.LockfileKey()).Hash == locked.Hash {
+		t.Error("got the same hash after updating a changed plugin, want a new hash")
+	}
+}
+
+func TestRemotePluginGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	repo := t.TempDir()
+	writeTestPlugin(t, filepath.Join(repo, "postgres"), "version: 1")
+	runCommand(t, repo, "git", "init", "--quiet", "--initial-branch", "main")
+	runCommand(t, repo, "git", "add", ".")
+	runCommand(t, repo, "git", "-c", "user.name=test", "-c", "user.email=test@example.com",
+		"commit", "--quiet", "-m", "Add plugin")
+
+	include := "git+file://" + repo + "?ref=main&dir=postgres"
+	lockfile := &lock.File{}
+	plugin, err := parseIncludable(context.Background(), include, "", lockfile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plugin.(*remotePlugin); !ok {
+		t.Fatalf("got plugin type %T, want *remotePlugin", plugin)
+	}
+	assertProcessCompose(t, plugin, "version: 1")
+	locked := lockfile.GetPlugin(plugin.LockfileKey())
+	if locked == nil || len(locked.Rev) != 40 {
+		t.Fatalf("got locked plugin %+v, want a commit SHA", locked)
+	}
+
+	// A new commit upstream doesn't change the plugin, even without the
+	// cache, because it's fetched at the locked commit.
+	writeTestPlugin(t, filepath.Join(repo, "postgres"), "version: 2")
+	runCommand(t, repo, "git", "-c", "user.name=test", "-c", "user.email=test@example.com",
+		"commit", "--quiet", "--all", "-m", "Update plugin")
+	if err := os.RemoveAll(remoteCacheDir()); err != nil {
+		t.Fatal(err)
+	}
+	if plugin, err = parseIncludable(context.Background(), include, "", lockfile, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertProcessCompose(t, plugin, "version: 1")
+
+	// Updating locks the new commit.
+	refetch := NewRefetch(false, plugin.LockfileKey())
+	if plugin, err = parseIncludable(context.Background(), include, "", lockfile, refetch); err != nil {
+		t.Fatal(err)
+	}
+	assertProcessCompose(t, plugin, "version: 2")
+	if updated := lockfile.GetPlugin(plugin.LockfileKey()); updated.Rev == locked.Rev {
+		t.Errorf("got the same rev %s after updating, want the new commit", updated.Rev)
+	}
+}
+
+func TestRemotePluginFile(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	writeTestPlugin(t, filepath.Join(root, "postgres"), "version: 1")
+	server := httptest.NewServer(http.FileServer(http.Dir(root)))
+	defer server.Close()
+
+	for _, include := range []string{server.URL + "/postgres/plugin.json", server.URL + "/postgres"} {
+		plugin, err := parseIncludable(context.Background(), include, "", &lock.File{}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", include, err)
+		}
+		assertProcessCompose(t, plugin, "version: 1")
+	}
+
+	if _, err := parseIncludable(context.Background(), server.URL+"/missing/plugin.json", "", &lock.File{}, nil); err == nil {
+		t.Error("got nil error including a missing plugin, want error")
+	}
+}
+
+func TestRemotePluginFileOtherHost(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("got request to another host for %s", r.URL)
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "evil", "create_files": {"file": "` + other.URL + `/file"}}`))
+	}))
+	defer server.Close()
+
+	_, err := parseIncludable(context.Background(), server.URL+"/plugin.json", "", &lock.File{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "outside of the plugin") {
+		t.Errorf("got error %v including a plugin with files on another host, want an error", err)
+	}
+}
+
+func TestDownloadURLContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("got request for %s, want none", r.URL)
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "plugin.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := downloadURL(ctx, server.URL+"/plugin.json", path, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v downloading with a canceled context, want context.Canceled", err)
+	}
+
+	t.Setenv(envir.DevboxOffline, "1")
+	if err := downloadURL(context.Background(), server.URL+"/plugin.json", path, nil); err == nil {
+		t.Error("got nil error downloading in offline mode, want error")
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// RenderedFile is a file in a plugin's create_files after templating.
+type RenderedFile struct {
+	// Path is where the plugin creates the file.
+	Path string `json:"path" yaml:"path"`
+
+	// ContentPath is the file in the plugin that Content is templated from.
+	ContentPath string `json:"content_path" yaml:"content_path"`
+
+	Content string `json:"content" yaml:"content"`
+}
+
+// RenderFiles returns the files that CreateFilesForConfig creates for a
+// plugin, without writing them. Directories, which don't have content, are
+// skipped.
+func (m *Manager) RenderFiles(ctx context.Context, cfg *Config) ([]RenderedFile, error) {
+	virtenvPath := filepath.Join(m.ProjectDir(), VirtenvPath)
+	files := []RenderedFile{}
+	for filePath, contentPath := range cfg.CreateFiles {
+		if contentPath == "" {
+			continue
+		}
+		content, err := m.renderFile(ctx, cfg.Source, filePath, contentPath, virtenvPath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, RenderedFile{
+			Path:        filePath,
+			ContentPath: contentPath,
+			Content:     string(content),
+		})
+	}
+	slices.SortFunc(files, func(a, b RenderedFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return files, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/fileutil"
+)
+
+const scaffoldConfig = `{
+  "$schema": "https://raw.githubusercontent.com/jetify-com/devbox/%[1]s/.schema/devbox-plugin.schema.json",
+  "name": "%[2]s",
+  "version": "0.0.1",
+  "description": "Describe what %[2]s does and how to use it. Devbox shows this when the plugin is installed.",
+  "packages": [],
+  "env": {
+    "%[3]s_DATA": "{{ .Virtenv }}/data"
+  },
+  "create_files": {
+    "{{ .Virtenv }}/data": "",
+    "{{ .Virtenv }}/process-compose.yaml": "config/process-compose.yaml"
+  }
+}
+`
+
+const scaffoldProcessCompose = `version: "0.5"
+
+processes:
+  %[1]s:
+    command: "echo \"Replace this command to run %[1]s with data in $%[2]s_DATA\" && sleep infinity"
+    availability:
+      restart: on_failure
+      max_restarts: 5
+`
+
+// envNameRegexp matches characters that aren't allowed in the environment
+// variables that Init derives from the plugin name.
+var envNameRegexp = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// Init creates a plugin named name in dir, with a plugin.json that creates a
+// process-compose.yaml for the plugin's services. If name is empty, the name
+// of dir is used. It returns the paths of the files it created.
+func Init(dir, name string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if name == "" {
+		name = localNameRegexp.ReplaceAllString(filepath.Base(dir), "-")
+	}
+	if !nameRegex.MatchString(name) {
+		return nil, usererr.New("Invalid plugin name %q. Name must match %s", name, nameRegex)
+	}
+	envName := envNameRegexp.ReplaceAllString(strings.ToUpper(name), "_")
+
+	files := map[string]string{
+		filepath.Join(dir, pluginConfigName): fmt.Sprintf(
+			scaffoldConfig, lo.Ternary(build.IsDev, "main", build.Version), name, envName),
+		filepath.Join(dir, "config", "process-compose.yaml"): fmt.Sprintf(
+			scaffoldProcessCompose, name, envName),
+	}
+	for path := range files {
+		if fileutil.Exists(path) {
+			return nil, usererr.New("%s already exists", path)
+		}
+	}
+	created := []string{}
+	for _, path := range sortedKeys(files) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := os.WriteFile(path, []byte(files[path]), 0o644); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		created = append(created, path)
+	}
+	return created, nil
+}
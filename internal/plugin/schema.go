@@ -0,0 +1,155 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox"
+)
+
+// jsonSchema is the subset of JSON schema that
+// .schema/devbox-plugin.schema.json uses.
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	PatternProperties    map[string]*jsonSchema `json:"patternProperties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+}
+
+// schemaTypes is the type keyword, which is either a type or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var types []string
+	if err := json.Unmarshal(data, &types); err == nil {
+		*t = types
+		return nil
+	}
+	var typ string
+	if err := json.Unmarshal(data, &typ); err != nil {
+		return err
+	}
+	*t = []string{typ}
+	return nil
+}
+
+// pluginSchema returns the parsed plugin.json schema.
+var pluginSchema = sync.OnceValues(func() (*jsonSchema, error) {
+	s := &jsonSchema{}
+	return s, errors.WithStack(json.Unmarshal(devbox.PluginSchema, s))
+})
+
+// validate returns a problem for every way that value, which is the result of
+// unmarshaling JSON into an any, doesn't match s. path is the location of
+// value in the document, for error messages.
+func (s *jsonSchema) validate(path string, value any) []error {
+	problem := func(format string, a ...any) error {
+		msg := fmt.Sprintf(format, a...)
+		if path == "" {
+			return errors.New(msg)
+		}
+		return errors.New(path + ": " + msg)
+	}
+
+	if len(s.Type) > 0 && !slices.Contains(s.Type, jsonType(value)) {
+		return []error{problem("got %s, want %s", jsonType(value), joinTypes(s.Type))}
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		return []error{problem("invalid value %v", value)}
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			if len(sub.validate(path, value)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []error{problem("doesn't match exactly one of the allowed formats")}
+		}
+	}
+
+	problems := []error{}
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				problems = append(problems, problem("%q is required", name))
+			}
+		}
+		for _, name := range sortedKeys(value) {
+			sub := s.propertySchema(name)
+			if sub == nil {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					problems = append(problems, problem("unknown field %q", name))
+				}
+				continue
+			}
+			problems = append(problems, sub.validate(joinPath(path, name), value[name])...)
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range value {
+				problems = append(problems, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+	}
+	return problems
+}
+
+// propertySchema returns the schema of the property name, or nil if s
+// doesn't have one.
+func (s *jsonSchema) propertySchema(name string) *jsonSchema {
+	if sub, ok := s.Properties[name]; ok {
+		return sub
+	}
+	for pattern, sub := range s.PatternProperties {
+		if matched, err := regexp.MatchString(pattern, name); err == nil && matched {
+			return sub
+		}
+	}
+	return nil
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("one of %v", types)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -1,5 +0,0 @@
-package plugin
-
-func Update() error {
-	return githubCache.Clear()
-}
@@ -0,0 +1,136 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/services"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// Validate checks the plugin.json at path, or in the directory at path,
+// against .schema/devbox-plugin.schema.json, and checks that it and the files in its
+// create_files only use template variables that devbox provides. It returns
+// every problem it finds so that they can be fixed at once.
+func Validate(ctx context.Context, path string) []error {
+	if fileutil.IsDir(path) {
+		path = filepath.Join(path, pluginConfigName)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []error{errors.WithStack(err)}
+	}
+	dir := filepath.Dir(path)
+	problems := []error{}
+	addProblem := func(format string, a ...any) {
+		problems = append(problems, fmt.Errorf(format, a...))
+	}
+
+	// Like buildConfig, plugin.json is templated before it's parsed. Template
+	// variables depend on the name, so read it first if possible.
+	name := filepath.Base(dir)
+	if raw, err := jsonPurifyPluginContent(content); err == nil {
+		named := struct {
+			Name string `json:"name"`
+		}{}
+		if json.Unmarshal(raw, &named) == nil && named.Name != "" {
+			name = named.Name
+		}
+	}
+	rendered, err := renderTemplate(
+		pluginConfigName,
+		string(content),
+		"", /*projectDir*/
+		configTemplateData(name, ""),
+		nil,  /*ports*/
+		true, /*strict*/
+	)
+	if err != nil {
+		return append(problems, err)
+	}
+	rendered, err = jsonPurifyPluginContent(rendered)
+	if err != nil {
+		return append(problems, errors.Wrap(err, "invalid JSON"))
+	}
+	var document any
+	if err := json.Unmarshal(rendered, &document); err != nil {
+		return append(problems, errors.Wrap(err, "invalid JSON"))
+	}
+	schema, err := pluginSchema()
+	if err != nil {
+		return append(problems, err)
+	}
+	problems = append(problems, schema.validate("", document)...)
+	cfg := &Config{}
+	if err := json.Unmarshal(rendered, cfg); err != nil {
+		// The schema problems already explain why the fields are invalid.
+		if len(problems) > 0 {
+			return problems
+		}
+		return append(problems, errors.Wrap(err, "invalid field"))
+	}
+
+	if cfg.Name != "" && !nameRegex.MatchString(cfg.Name) {
+		addProblem("invalid name %q, it must match %s", cfg.Name, nameRegex)
+	}
+	for _, include := range cfg.Include {
+		if t, _, _ := strings.Cut(include, ":"); t == "plugin" {
+			continue
+		}
+		if _, err := flake.ParseRef(include); err != nil {
+			addProblem("include %q: %v", include, err)
+		}
+	}
+
+	plugin := &LocalPlugin{
+		ref:  flake.Ref{Type: flake.TypePath, Path: path},
+		name: cfg.Name,
+	}
+	for _, filePath := range sortedKeys(cfg.CreateFiles) {
+		contentPath := cfg.CreateFiles[filePath]
+		if contentPath == "" {
+			continue
+		}
+		if !strings.HasPrefix(filepath.Join(dir, contentPath), dir+string(filepath.Separator)) {
+			addProblem("create_files %q: %s is outside of the plugin directory", filePath, contentPath)
+			continue
+		}
+		content, err := plugin.FileContent(ctx, contentPath)
+		if err != nil {
+			addProblem("create_files %q: %s doesn't exist", filePath, contentPath)
+			continue
+		}
+		data, err := fileTemplateData(plugin, "", VirtenvPath, []string{}, "")
+		if err != nil {
+			return append(problems, err)
+		}
+		rendered, err := renderTemplate(filePath, string(content), "", data, nil /*ports*/, true /*strict*/)
+		if err != nil {
+			addProblem("create_files %q: %v", filePath, err)
+			continue
+		}
+		if isProcessComposeFile(filePath) {
+			if _, err := services.NamesFromProcessCompose(rendered); err != nil {
+				addProblem("create_files %q: invalid process-compose file: %v", filePath, err)
+			}
+		}
+	}
+	return problems
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := lo.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
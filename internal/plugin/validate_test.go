@@ -0,0 +1,115 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitIsValid(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-service")
+	created, err := Init(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 {
+		t.Errorf("got created files %v, want plugin.json and process-compose.yaml", created)
+	}
+	if problems := Validate(context.Background(), dir); len(problems) > 0 {
+		t.Errorf("got problems %v validating a new plugin, want none", problems)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, pluginConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"name": "my-service"`) {
+		t.Errorf("got plugin.json %s, want the name of the directory", content)
+	}
+
+	if _, err := Init(dir, ""); err == nil {
+		t.Error("got nil error creating a plugin where one exists, want error")
+	}
+	if _, err := Init(t.TempDir(), "my/plugin"); err == nil {
+		t.Error("got nil error creating a plugin with an invalid name, want error")
+	}
+}
+
+func TestValidateBuiltins(t *testing.T) {
+	paths, err := filepath.Glob("../../plugins/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if problems := Validate(context.Background(), path); len(problems) > 0 {
+			t.Errorf("got problems validating built-in plugin %s, want none: %v", path, problems)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("plugin.json", `{
+  "name": "broken",
+  "packges": ["redis"],
+  "env": {"REDIS_CONF": "{{ .DevboxDir }}/redis.conf"},
+  "create_files": {
+    "{{ .DevboxDir }}/redis.conf": "redis.conf",
+    "{{ .Virtenv }}/process-compose.yaml": "process-compose.yaml",
+    "{{ .Virtenv }}/start.sh": "missing.sh"
+  }
+}`)
+	writeFile("redis.conf", "dir {{ .VirtEnv }}\n")
+	writeFile("process-compose.yaml", "processes: [\n")
+
+	want := []string{
+		`unknown field "packges"`,
+		`"version" is required`,
+		`create_files "devbox.d/broken/redis.conf": template: `,
+		`create_files ".devbox/virtenv/broken/process-compose.yaml": invalid process-compose file`,
+		`create_files ".devbox/virtenv/broken/start.sh": missing.sh doesn't exist`,
+	}
+	problems := Validate(context.Background(), dir)
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems %v, want %d", len(problems), problems, len(want))
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			found = found || strings.HasPrefix(p.Error(), w)
+		}
+		if !found {
+			t.Errorf("got problems %v, want one that starts with %q", problems, w)
+		}
+	}
+
+	writeFile("plugin.json", `{"name": "broken", "version": "1", "env": {"A": "{{ .Virtual }}"}}`)
+	if problems := Validate(context.Background(), dir); len(problems) != 1 || !strings.Contains(problems[0].Error(), "Virtual") {
+		t.Errorf("got problems %v, want an unknown template variable", problems)
+	}
+	writeFile("plugin.json", `{"name": "broken", "version": "1", "env": {"A": 1}, "hooks": {"on_start": "true"}}`)
+	problems = Validate(context.Background(), dir)
+	want = []string{`env.A: got number, want string`, `hooks: unknown field "on_start"`}
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems %v, want %d", len(problems), problems, len(want))
+	}
+	for i, w := range want {
+		if problems[i].Error() != w {
+			t.Errorf("got problem %q, want %q", problems[i], w)
+		}
+	}
+}
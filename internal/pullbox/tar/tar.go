@@ -34,21 +34,28 @@ func Extract(data []byte) (string, error) {
 		return "", err
 	}
 
-	cmd := exec.Command("tar", "-xf", tempFile.Name(), "-C", tempDir)
+	if err := ExtractFile(tempFile.Name(), tempDir); err != nil {
+		return "", err
+	}
+	return tempDir, nil
+}
 
-	if err = cmd.Run(); err != nil {
+// ExtractFile extracts a tar file into dir, which must already exist.
+func ExtractFile(archive, dir string) error {
+	cmd := exec.Command("tar", "-xf", archive, "-C", dir)
+
+	if err := cmd.Run(); err != nil {
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
 			waitStatus := exitErr.Sys().(syscall.WaitStatus)
-			return "", fmt.Errorf(
+			return fmt.Errorf(
 				"tar extraction failed with exit code: %d",
 				waitStatus.ExitStatus(),
 			)
 		}
-		return "", err
+		return err
 	}
-
-	return tempDir, nil
+	return nil
 }
 
 func Compress(dir string) (string, error) {
@@ -57,13 +64,18 @@ func Compress(dir string) (string, error) {
 		return "", err
 	}
 	target := filepath.Join(tmpDir, "archive.tar.gz")
+	if err := CompressTo(dir, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// CompressTo writes a gzipped tar file of the contents of dir to target and
+// waits for it to finish.
+func CompressTo(dir, target string) error {
 	cmd := cmdutil.CommandTTY("tar", "-czf", target, ".")
 
 	cmd.Dir = dir
 
-	if err = cmd.Start(); err != nil {
-		return "", errors.WithStack(err)
-	}
-
-	return target, nil
+	return errors.WithStack(cmd.Run())
 }
@@ -12,6 +12,7 @@ import (
 	"net/url"
 
 	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/redact"
 )
@@ -20,17 +21,31 @@ const searchAPIEndpoint = "https://search.devbox.sh"
 
 var ErrNotFound = errors.New("Not found")
 
+// Searcher finds packages and resolves package versions.
+type Searcher interface {
+	Search(ctx context.Context, query string) (*SearchResults, error)
+	Resolve(ctx context.Context, name, version string) (*PackageVersion, error)
+	ResolveV2(ctx context.Context, name, version string) (*ResolveResponse, error)
+}
+
+var (
+	_ Searcher = (*client)(nil)
+	_ Searcher = (*localIndex)(nil)
+)
+
+// client is a Searcher backed by the Devbox search API.
 type client struct {
 	host string
 }
 
-func Client() *client {
-	return &client{
-		host: envir.GetValueOrDefault(envir.DevboxSearchHost, searchAPIEndpoint),
-	}
+// Client returns a Searcher for the Devbox search API, or for a local index of
+// nixpkgs if DEVBOX_SEARCH_NIXPKGS is set. Use New to select a local index
+// from devbox.json.
+func Client() Searcher {
+	return New(LocalIndexConfig{})
 }
 
-func (c *client) Search(query string) (*SearchResults, error) {
+func (c *client) Search(ctx context.Context, query string) (*SearchResults, error) {
 	if query == "" {
 		return nil, fmt.Errorf("query should not be empty")
 	}
@@ -41,16 +56,21 @@ func (c *client) Search(query string) (*SearchResults, error) {
 	}
 	searchURL := endpoint + "?q=" + url.QueryEscape(query)
 
-	return execGet[SearchResults](context.TODO(), searchURL)
+	return execGet[SearchResults](ctx, searchURL)
 }
 
 // Resolve calls the /resolve endpoint of the search service. This returns
 // the latest version of the package that matches the version constraint.
-func (c *client) Resolve(name, version string) (*PackageVersion, error) {
+func (c *client) Resolve(ctx context.Context, name, version string) (*PackageVersion, error) {
 	if name == "" || version == "" {
 		return nil, fmt.Errorf("name and version should not be empty")
 	}
 
+	version, err := c.resolveRange(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoint, err := url.JoinPath(c.host, "v1/resolve")
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -59,7 +79,7 @@ func (c *client) Resolve(name, version string) (*PackageVersion, error) {
 		"?name=" + url.QueryEscape(name) +
 		"&version=" + url.QueryEscape(version)
 
-	return execGet[PackageVersion](context.TODO(), searchURL)
+	return execGet[PackageVersion](ctx, searchURL)
 }
 
 // Resolve calls the /resolve endpoint of the search service. This returns
@@ -72,6 +92,11 @@ func (c *client) ResolveV2(ctx context.Context, name, version string) (*ResolveR
 		return nil, redact.Errorf("version is empty")
 	}
 
+	version, err := c.resolveRange(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoint, err := url.JoinPath(c.host, "v2/resolve")
 	if err != nil {
 		return nil, redact.Errorf("invalid search endpoint host %q: %w", redact.Safe(c.host), redact.Safe(err))
@@ -83,7 +108,42 @@ func (c *client) ResolveV2(ctx context.Context, name, version string) (*ResolveR
 	return execGet[ResolveResponse](ctx, searchURL)
 }
 
+// resolveRange returns the highest version of a package that satisfies a
+// version range. The search service only resolves exact versions and version
+// prefixes, so ranges are matched against the versions that search returns.
+// Versions that aren't ranges are returned unchanged.
+func (c *client) resolveRange(ctx context.Context, name, version string) (string, error) {
+	if !IsVersionRange(version) {
+		return version, nil
+	}
+	constraint, err := ParseVersionConstraint(version)
+	if err != nil {
+		return "", err
+	}
+	results, err := c.Search(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	versions := []string{}
+	for _, pkg := range results.Packages {
+		if pkg.Name != name {
+			continue
+		}
+		for _, v := range pkg.Versions {
+			versions = append(versions, v.Version)
+		}
+	}
+	best, ok := constraint.Max(versions)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return best, nil
+}
+
 func execGet[T any](ctx context.Context, url string) (*T, error) {
+	if envir.IsOffline() {
+		return nil, usererr.New("Cannot reach the Devbox search service in offline mode.")
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, redact.Errorf("GET %s: %w", redact.Safe(url), redact.Safe(err))
@@ -0,0 +1,343 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// VersionConstraint is an npm-style version range such as ^20.10, ~1.22, or
+// >=3.11 <3.13. A constraint is a set of comparator lists separated by ||.
+// A version satisfies the constraint if it satisfies every comparator in any
+// one of the lists.
+//
+// Versions are ordered the same way as Nix's builtins.compareVersions.
+// Pre-release versions (versions with non-numeric components such as 1.22rc1)
+// only satisfy a constraint if one of its comparators is also a pre-release.
+type VersionConstraint struct {
+	raw        string
+	sets       [][]comparator
+	prerelease bool
+}
+
+type comparator struct {
+	// op is one of >=, >, <=, <, =, or * (any version). The = operator
+	// matches any version that starts with the comparator's components, so
+	// =1.22 matches 1.22.3.
+	op      string
+	version string
+}
+
+// IsVersionRange returns true if version is a range rather than an exact
+// version, a version prefix, or "latest".
+func IsVersionRange(version string) bool {
+	if strings.ContainsAny(version, "^~<>=|* ") {
+		return true
+	}
+	for _, c := range strings.Split(version, ".") {
+		if c == "x" || c == "X" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseVersionConstraint parses an npm-style version range. It supports
+// comparators (>=, >, <=, <, =), caret (^1.2) and tilde (~1.2) ranges,
+// wildcards (1.x, 1.2.*), hyphen ranges (1.2 - 1.4), and alternatives
+// separated by ||. Versions without an operator match as a prefix.
+func ParseVersionConstraint(s string) (*VersionConstraint, error) {
+	c := &VersionConstraint{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(alt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version range %q: %w", s, err)
+		}
+		for _, cmp := range set {
+			if isPrerelease(cmp.version) {
+				c.prerelease = true
+			}
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	// Join operators that are separated from their version by spaces, so
+	// that ">= 3.11" is the same as ">=3.11".
+	tokens := []string{}
+	for _, field := range strings.Fields(s) {
+		if n := len(tokens); n > 0 && strings.Trim(tokens[n-1], "<>=^~") == "" && tokens[n-1] != "-" {
+			tokens[n-1] += field
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty range")
+	}
+
+	set := []comparator{}
+	for i := 0; i < len(tokens); i++ {
+		// Hyphen range: 1.2 - 1.4 is >=1.2 <=1.4.
+		if i+2 < len(tokens) && tokens[i+1] == "-" {
+			set = append(set,
+				comparator{op: ">=", version: trimWildcard(tokens[i])},
+				comparator{op: "<=", version: trimWildcard(tokens[i+2])},
+			)
+			i += 2
+			continue
+		}
+		cmps, err := parseComparator(tokens[i])
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cmps...)
+	}
+	return set, nil
+}
+
+func parseComparator(token string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(token, prefix) {
+			op = prefix
+			break
+		}
+	}
+	version := trimWildcard(token[len(op):])
+	if version == "" {
+		if strings.ContainsAny(token[len(op):], "xX*") {
+			return []comparator{{op: "*"}}, nil
+		}
+		return nil, fmt.Errorf("missing version in %q", token)
+	}
+	if strings.ContainsAny(version, "<>=^~") {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+
+	switch op {
+	case "", "=":
+		return []comparator{{op: "=", version: version}}, nil
+	case "^", "~":
+		upper, err := bumpVersion(version, op)
+		if err != nil {
+			return nil, err
+		}
+		return []comparator{{op: ">=", version: version}, {op: "<", version: upper}}, nil
+	default:
+		return []comparator{{op: op, version: version}}, nil
+	}
+}
+
+// trimWildcard removes the first x, X, or * component and everything after
+// it, so 1.2.x becomes 1.2.
+func trimWildcard(version string) string {
+	components := strings.Split(version, ".")
+	for i, c := range components {
+		if c == "x" || c == "X" || c == "*" {
+			return strings.Join(components[:i], ".")
+		}
+	}
+	return version
+}
+
+// bumpVersion returns the exclusive upper bound of a caret or tilde range.
+func bumpVersion(version, op string) (string, error) {
+	parts := strings.Split(version, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return "", fmt.Errorf("%s requires a numeric version, got %q", op, version)
+		}
+		nums[i] = n
+	}
+
+	// Tilde allows changes below the minor version, or below the major
+	// version if there's no minor version. Caret allows changes below the
+	// first non-zero component.
+	bump := 0
+	if op == "~" {
+		bump = min(1, len(nums)-1)
+	} else {
+		for bump < len(nums)-1 && nums[bump] == 0 {
+			bump++
+		}
+	}
+	upper := make([]string, bump+1)
+	for i := 0; i < bump; i++ {
+		upper[i] = strconv.Itoa(nums[i])
+	}
+	upper[bump] = strconv.Itoa(nums[bump] + 1)
+	return strings.Join(upper, "."), nil
+}
+
+// String returns the constraint as it was written.
+func (c *VersionConstraint) String() string {
+	return c.raw
+}
+
+// Matches returns true if version satisfies the constraint.
+func (c *VersionConstraint) Matches(version string) bool {
+	if !c.prerelease && isPrerelease(version) {
+		return false
+	}
+	for _, set := range c.sets {
+		ok := true
+		for _, cmp := range set {
+			if !cmp.matches(version) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Max returns the highest version that satisfies the constraint.
+func (c *VersionConstraint) Max(versions []string) (string, bool) {
+	best, found := "", false
+	for _, v := range versions {
+		if c.Matches(v) && (!found || CompareVersions(v, best) > 0) {
+			best, found = v, true
+		}
+	}
+	return best, found
+}
+
+func (c comparator) matches(version string) bool {
+	switch c.op {
+	case "*":
+		return true
+	case "=":
+		return hasVersionPrefix(version, c.version)
+	case ">=":
+		return CompareVersions(version, c.version) >= 0
+	case ">":
+		// >1.22 excludes every 1.22.x version.
+		return CompareVersions(version, c.version) > 0 && !hasVersionPrefix(version, c.version)
+	case "<=":
+		return CompareVersions(version, c.version) <= 0 || hasVersionPrefix(version, c.version)
+	case "<":
+		return CompareVersions(version, c.version) < 0
+	}
+	return false
+}
+
+// hasVersionPrefix returns true if the components of prefix are the first
+// components of version.
+func hasVersionPrefix(version, prefix string) bool {
+	v, p := versionComponents(version), versionComponents(prefix)
+	if len(p) > len(v) {
+		return false
+	}
+	for i := range p {
+		if v[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrerelease(version string) bool {
+	for _, c := range versionComponents(version) {
+		if !isNumeric(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareVersions compares two versions the same way as Nix's
+// builtins.compareVersions. It returns -1 if a < b, 0 if a == b, and 1 if
+// a > b.
+func CompareVersions(a, b string) int {
+	ac, bc := versionComponents(a), versionComponents(b)
+	for i := 0; i < max(len(ac), len(bc)); i++ {
+		var c1, c2 string
+		if i < len(ac) {
+			c1 = ac[i]
+		}
+		if i < len(bc) {
+			c2 = bc[i]
+		}
+		if componentLess(c1, c2) {
+			return -1
+		}
+		if componentLess(c2, c1) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func componentLess(c1, c2 string) bool {
+	n1, n2 := isNumeric(c1), isNumeric(c2)
+	switch {
+	case n1 && n2:
+		i1, _ := strconv.ParseUint(c1, 10, 64)
+		i2, _ := strconv.ParseUint(c2, 10, 64)
+		return i1 < i2
+	case c1 == "" && n2:
+		return true
+	case c1 == "pre" && c2 != "pre":
+		return true
+	case c2 == "pre":
+		return false
+	case n2:
+		// 2.3a < 2.3.1
+		return true
+	case n1:
+		return false
+	default:
+		return c1 < c2
+	}
+}
+
+// versionComponents splits a version into components at dots and dashes, and
+// between runs of digits and non-digits.
+func versionComponents(version string) []string {
+	components := []string{}
+	start := -1
+	for i, r := range version {
+		if r == '.' || r == '-' {
+			if start >= 0 {
+				components = append(components, version[start:i])
+			}
+			start = -1
+			continue
+		}
+		if start >= 0 && unicode.IsDigit(r) != unicode.IsDigit(rune(version[start])) {
+			components = append(components, version[start:i])
+			start = -1
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		components = append(components, version[start:])
+	}
+	return components
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package searcher
+
+import (
+	"testing"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	versions := []string{
+		"1.21.10", "1.22.0", "1.22.3", "1.23rc1", "1.23.0",
+		"3.10.14", "3.11.9", "3.12.3", "3.13.0",
+		"20.9.0", "20.10.0", "20.12.2", "21.7.3",
+		"0.2.3", "0.2.9", "0.3.0",
+	}
+	testCases := []struct {
+		constraint string
+		want       string
+	}{
+		{"^20.10", "20.12.2"},
+		{"^20", "20.12.2"},
+		{"^0.2.3", "0.2.9"},
+		{"~1.22", "1.22.3"},
+		{"~1", "1.23.0"},
+		{">=3.11 <3.13", "3.12.3"},
+		{">= 3.11 < 3.13", "3.12.3"},
+		{">3.11 <4", "3.13.0"},
+		{"<=3.11", "3.11.9"},
+		{"1.22.x", "1.22.3"},
+		{"3.*", "3.13.0"},
+		{"3.10 - 3.11", "3.11.9"},
+		{"~1.21 || ~3.10", "3.10.14"},
+		{"=1.22.0", "1.22.0"},
+		{">=1.23rc1 <1.24", "1.23.0"},
+		{"^22", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.constraint, func(t *testing.T) {
+			if !IsVersionRange(tc.constraint) {
+				t.Errorf("IsVersionRange(%q) = false, want true", tc.constraint)
+			}
+			c, err := ParseVersionConstraint(tc.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := c.Max(versions)
+			if got != tc.want {
+				t.Errorf("got max version %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVersionConstraintPrerelease(t *testing.T) {
+	c, err := ParseVersionConstraint(">=1.22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Matches("1.23rc1") {
+		t.Error("got pre-release match for range without pre-release")
+	}
+}
+
+func TestParseVersionConstraintErrors(t *testing.T) {
+	for _, s := range []string{"^abc", ">=", "1.0 ||", "^1.2rc1"} {
+		if _, err := ParseVersionConstraint(s); err == nil {
+			t.Errorf("ParseVersionConstraint(%q) got nil error", s)
+		}
+	}
+}
+
+func TestIsVersionRange(t *testing.T) {
+	for _, v := range []string{"latest", "1.22", "1.22.3", "3", "0-unstable-2024-01-01"} {
+		if IsVersionRange(v) {
+			t.Errorf("IsVersionRange(%q) = true, want false", v)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "2.3", -1},
+		{"2.1", "2.3", -1},
+		{"2.3", "2.3", 0},
+		{"2.5", "2.3", 1},
+		{"3.1", "2.3", 1},
+		{"2.3.1", "2.3", 1},
+		{"2.3.1", "2.3a", 1},
+		{"2.3pre1", "2.3", -1},
+		{"2.3pre3", "2.3pre12", -1},
+		{"2.3a", "2.3c", -1},
+		{"2.3pre1", "2.3c", -1},
+		{"2.3pre1", "2.3q", -1},
+		{"1.10", "1.9", 1},
+	}
+	for _, tc := range testCases {
+		if got := CompareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
+	"go.jetpack.io/devbox/internal/xdg"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// localIndexTTL is how long a local index built from a flake reference is
+// used before it's rebuilt. Indexes built from a dump are rebuilt when the
+// dump changes instead.
+const localIndexTTL = 24 * time.Hour
+
+// localIndex is a Searcher that answers queries from an index of a single
+// nixpkgs revision instead of the Devbox search API. The index is built from
+// a nixpkgs flake reference or checkout, or from the output of
+// `nix search --json`, and stored under the XDG cache directory.
+//
+// `nix search` only evaluates the current system, so the index only has
+// packages and versions for the system it was built on. Packages resolved
+// from it are locked for that system only.
+type localIndex struct {
+	// nixpkgs is the nixpkgs flake reference or checkout directory that
+	// packages resolve to.
+	nixpkgs string
+
+	// dump is an optional path to the output of `nix search --json` for
+	// nixpkgs. It avoids evaluating all of nixpkgs to build the index.
+	dump string
+
+	// stderr receives progress messages while the index is built.
+	stderr io.Writer
+}
+
+// LocalIndexConfig selects a local index of nixpkgs for package search and
+// version resolution.
+type LocalIndexConfig struct {
+	// Nixpkgs is a nixpkgs flake reference or an absolute path to a nixpkgs
+	// checkout. If it's empty, the Devbox search API is used.
+	Nixpkgs string
+
+	// Dump is an optional absolute path to the output of
+	// `nix search --json` for Nixpkgs.
+	Dump string
+
+	// Stderr receives progress messages while the index is built.
+	Stderr io.Writer
+}
+
+// New returns a Searcher that uses the local index that cfg selects, usually
+// from the search field of devbox.json, or the Devbox search API if cfg
+// doesn't select one. The DEVBOX_SEARCH_NIXPKGS and DEVBOX_SEARCH_DUMP env
+// vars override cfg.
+func New(cfg LocalIndexConfig) Searcher {
+	if l := newLocalIndex(cfg); l.nixpkgs != "" {
+		return l
+	}
+	return &client{
+		host: envir.GetValueOrDefault(envir.DevboxSearchHost, searchAPIEndpoint),
+	}
+}
+
+// indexFile is the on-disk format of a local index.
+type indexFile struct {
+	// Nixpkgs is the locked flake reference that the index was built from.
+	Nixpkgs      string       `json:"nixpkgs"`
+	LastModified int64        `json:"last_modified"`
+	System       string       `json:"system"`
+	Packages     []indexEntry `json:"packages"`
+}
+
+type indexEntry struct {
+	// AttrPath is the attribute path without the legacyPackages.<system>
+	// prefix. It's the package name that devbox.json uses.
+	AttrPath string `json:"attr_path"`
+	PName    string `json:"pname"`
+	Version  string `json:"version"`
+	Summary  string `json:"summary,omitempty"`
+}
+
+// IsLocalIndex returns true if s answers queries from a local index of
+// nixpkgs instead of the Devbox search API.
+func IsLocalIndex(s Searcher) bool {
+	_, ok := s.(*localIndex)
+	return ok
+}
+
+func newLocalIndex(cfg LocalIndexConfig) *localIndex {
+	l := &localIndex{
+		nixpkgs: cfg.Nixpkgs,
+		dump:    cfg.Dump,
+		stderr:  cfg.Stderr,
+	}
+	if l.stderr == nil {
+		l.stderr = io.Discard
+	}
+	if nixpkgs := os.Getenv(envir.DevboxSearchNixpkgs); nixpkgs != "" {
+		l.nixpkgs = nixpkgs
+		l.dump = os.Getenv(envir.DevboxSearchDump)
+	}
+	return l
+}
+
+// RebuildLocalIndex rebuilds the local index of s, even if it's up to date.
+func RebuildLocalIndex(ctx context.Context, s Searcher) error {
+	l, ok := s.(*localIndex)
+	if !ok {
+		return usererr.New("No local search index is configured. Set search.nixpkgs in devbox.json "+
+			"or %s to a nixpkgs flake reference or checkout.", envir.DevboxSearchNixpkgs)
+	}
+	idx, err := l.build(ctx)
+	if err != nil {
+		return err
+	}
+	loadedIndexesMu.Lock()
+	defer loadedIndexesMu.Unlock()
+	loadedIndexes[l.path()] = idx
+	return nil
+}
+
+func (l *localIndex) Search(ctx context.Context, query string) (*SearchResults, error) {
+	if query == "" {
+		return nil, fmt.Errorf("query should not be empty")
+	}
+	idx, err := l.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		entry *indexEntry
+		rank  int
+	}
+	query = strings.ToLower(query)
+	matches := []match{}
+	for i := range idx.Packages {
+		e := &idx.Packages[i]
+		attrPath, pname := strings.ToLower(e.AttrPath), strings.ToLower(e.PName)
+		rank := 0
+		switch {
+		case attrPath == query || pname == query:
+			rank = 0
+		case strings.HasPrefix(attrPath, query) || strings.HasPrefix(pname, query):
+			rank = 1
+		case strings.Contains(attrPath, query) || strings.Contains(pname, query):
+			rank = 2
+		case strings.Contains(strings.ToLower(e.Summary), query):
+			rank = 3
+		default:
+			continue
+		}
+		matches = append(matches, match{e, rank})
+	}
+	slices.SortStableFunc(matches, func(a, b match) int {
+		return cmp.Or(
+			cmp.Compare(a.rank, b.rank),
+			cmp.Compare(len(a.entry.AttrPath), len(b.entry.AttrPath)),
+			strings.Compare(a.entry.AttrPath, b.entry.AttrPath),
+		)
+	})
+
+	results := &SearchResults{NumResults: len(matches)}
+	for _, m := range matches {
+		results.Packages = append(results.Packages, Package{
+			Name:        m.entry.AttrPath,
+			NumVersions: 1,
+			Versions:    []PackageVersion{idx.packageVersion(m.entry)},
+		})
+	}
+	return results, nil
+}
+
+func (l *localIndex) Resolve(ctx context.Context, name, version string) (*PackageVersion, error) {
+	if name == "" || version == "" {
+		return nil, fmt.Errorf("name and version should not be empty")
+	}
+	idx, err := l.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := idx.find(name, version)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrNotFound
+	}
+	pkg := idx.packageVersion(entry)
+	return &pkg, nil
+}
+
+func (l *localIndex) ResolveV2(ctx context.Context, name, version string) (*ResolveResponse, error) {
+	if name == "" || version == "" {
+		return nil, fmt.Errorf("name and version should not be empty")
+	}
+	idx, err := l.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := idx.find(name, version)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrNotFound
+	}
+	installable, err := flake.ParseInstallable(idx.Nixpkgs + "#" + entry.AttrPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ResolveResponse{
+		Name:    entry.AttrPath,
+		Version: entry.Version,
+		Summary: entry.Summary,
+		// The index only has the system it was built on. Outputs for other
+		// systems can be added with `devbox lock --all-systems`.
+		Systems: map[string]ResolvedSystem{
+			idx.System: {
+				FlakeInstallable: installable,
+				LastUpdated:      time.Unix(idx.LastModified, 0).UTC(),
+			},
+		},
+	}, nil
+}
+
+// find returns the package with the given attribute path or pname whose
+// version matches version, or nil if there isn't one. If version is a range,
+// find returns the highest version in the range.
+func (idx *indexFile) find(name, version string) (*indexEntry, error) {
+	if IsVersionRange(version) {
+		constraint, err := ParseVersionConstraint(version)
+		if err != nil {
+			return nil, err
+		}
+		return idx.findRange(name, constraint), nil
+	}
+
+	var found *indexEntry
+	for i := range idx.Packages {
+		e := &idx.Packages[i]
+		if !versionMatches(e.Version, version) {
+			continue
+		}
+		if e.AttrPath == name {
+			return e, nil
+		}
+		// Fall back to the shortest attribute path with a matching pname.
+		if e.PName == name && (found == nil || len(e.AttrPath) < len(found.AttrPath)) {
+			found = e
+		}
+	}
+	return found, nil
+}
+
+func (idx *indexFile) findRange(name string, constraint *VersionConstraint) *indexEntry {
+	var found *indexEntry
+	for i := range idx.Packages {
+		e := &idx.Packages[i]
+		if (e.AttrPath != name && e.PName != name) || !constraint.Matches(e.Version) {
+			continue
+		}
+		if found == nil {
+			found = e
+			continue
+		}
+		// Prefer higher versions, then the attribute path that matches the
+		// name exactly, then the shortest attribute path.
+		order := CompareVersions(e.Version, found.Version)
+		if order > 0 || order == 0 && (e.AttrPath == name ||
+			found.AttrPath != name && len(e.AttrPath) < len(found.AttrPath)) {
+			found = e
+		}
+	}
+	return found
+}
+
+// versionMatches returns true if version is "latest", the exact version, or a
+// prefix of it that ends at a version component (1.22 matches 1.22.3).
+func versionMatches(version, constraint string) bool {
+	return constraint == "latest" ||
+		version == constraint ||
+		strings.HasPrefix(version, constraint+".")
+}
+
+func (idx *indexFile) packageVersion(e *indexEntry) PackageVersion {
+	info := PackageInfo{
+		System:      idx.System,
+		LastUpdated: int(idx.LastModified),
+		AttrPaths:   []string{e.AttrPath},
+		Version:     e.Version,
+		Summary:     e.Summary,
+	}
+	return PackageVersion{
+		PackageInfo: info,
+		Name:        e.AttrPath,
+		Systems:     map[string]PackageInfo{idx.System: info},
+	}
+}
+
+// loadedIndexes caches indexes by path so that they're only read once per
+// process. Indexes that fail to load aren't cached, so that they're retried.
+var (
+	loadedIndexesMu sync.Mutex
+	loadedIndexes   = map[string]*indexFile{}
+)
+
+func (l *localIndex) load(ctx context.Context) (*indexFile, error) {
+	// Holding the lock while the index is built keeps concurrent callers
+	// from building it more than once.
+	loadedIndexesMu.Lock()
+	defer loadedIndexesMu.Unlock()
+	if idx := loadedIndexes[l.path()]; idx != nil {
+		return idx, nil
+	}
+	idx, err := l.read()
+	if err == nil && idx == nil {
+		idx, err = l.build(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	loadedIndexes[l.path()] = idx
+	return idx, nil
+}
+
+// read returns the index at l.path, or nil if it's missing or stale.
+func (l *localIndex) read() (*indexFile, error) {
+	stat, err := os.Stat(l.path())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if l.dump != "" {
+		dumpStat, err := os.Stat(l.dump)
+		if err != nil {
+			return nil, usererr.WithUserMessage(err, "Unable to read search dump %s", l.dump)
+		}
+		if dumpStat.ModTime().After(stat.ModTime()) {
+			return nil, nil
+		}
+	} else if time.Since(stat.ModTime()) > localIndexTTL && !envir.IsOffline() {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(l.path())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	idx := &indexFile{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		// Rebuild corrupt indexes.
+		return nil, nil //nolint:nilerr
+	}
+	return idx, nil
+}
+
+func (l *localIndex) build(ctx context.Context) (*indexFile, error) {
+	ref := l.nixpkgs
+	if fileutil.IsDir(ref) {
+		abs, err := filepath.Abs(ref)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ref = "path:" + abs
+	}
+	ux.Finfo(l.stderr, "Building search index for %s. The index only has packages for %s.\n",
+		ref, nix.System())
+
+	metadata, err := nix.GetFlakeMetadata(ctx, ref)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to lock nixpkgs %s for the search index", ref)
+	}
+
+	var infos map[string]*nix.Info
+	if l.dump != "" {
+		data, err := os.ReadFile(l.dump)
+		if err != nil {
+			return nil, usererr.WithUserMessage(err, "Unable to read search dump %s", l.dump)
+		}
+		infos, err = nix.ParseSearchResults(data)
+		if err != nil {
+			return nil, usererr.WithUserMessage(err, "Search dump %s isn't valid `nix search --json` output", l.dump)
+		}
+	} else {
+		infos, err = nix.Search(metadata.URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	idx := &indexFile{
+		Nixpkgs:      metadata.URL,
+		LastModified: metadata.LastModified,
+		Packages:     make([]indexEntry, 0, len(infos)),
+	}
+	for key, info := range infos {
+		// Keys look like legacyPackages.x86_64-linux.python3Packages.numpy.
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		idx.System = parts[1]
+		idx.Packages = append(idx.Packages, indexEntry{
+			AttrPath: parts[2],
+			PName:    info.PName,
+			Version:  info.Version,
+			Summary:  info.Summary,
+		})
+	}
+	slices.SortFunc(idx.Packages, func(a, b indexEntry) int {
+		return strings.Compare(a.AttrPath, b.AttrPath)
+	})
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path()), 0o755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.WriteFile(l.path(), data, 0o644); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return idx, nil
+}
+
+func (l *localIndex) path() string {
+	key := cachehash.Bytes([]byte(l.nixpkgs + "\n" + l.dump))
+	return xdg.CacheSubpath(filepath.Join("devbox", "search", key+".json"))
+}
@@ -0,0 +1,163 @@
+package searcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestLocalIndex(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv(envir.DevboxSearchNixpkgs, "github:NixOS/nixpkgs/nixos-unstable")
+	t.Setenv(envir.DevboxSearchDump, "")
+
+	// Write a fresh index so that nothing is evaluated with nix.
+	l := newLocalIndex(LocalIndexConfig{})
+	idx := &indexFile{
+		Nixpkgs:      "github:NixOS/nixpkgs/abc123",
+		LastModified: 1700000000,
+		System:       "x86_64-linux",
+		Packages: []indexEntry{
+			{AttrPath: "go", PName: "go", Version: "1.22.3", Summary: "The Go Programming language"},
+			{AttrPath: "go_1_21", PName: "go", Version: "1.21.10", Summary: "The Go Programming language"},
+			{AttrPath: "gopls", PName: "gopls", Version: "0.15.3", Summary: "Official language server for Go"},
+			{AttrPath: "hugo", PName: "hugo", Version: "0.125.4", Summary: "A fast and modern static website engine"},
+			{AttrPath: "python3Packages.numpy", PName: "numpy", Version: "1.26.4"},
+		},
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(l.path(), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := Client()
+	if !IsLocalIndex(s) {
+		t.Fatalf("got Client() of type %T, want *localIndex", s)
+	}
+
+	ctx := context.Background()
+	results, err := s.Search(ctx, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotNames := []string{}
+	for _, p := range results.Packages {
+		gotNames = append(gotNames, p.Name)
+	}
+	wantNames := []string{"go", "go_1_21", "gopls", "hugo"}
+	if diff := cmp.Diff(wantNames, gotNames); diff != "" {
+		t.Errorf("wrong search results (-want +got):\n%s", diff)
+	}
+
+	resolveTests := []struct {
+		name, version, wantAttr string
+	}{
+		{"go", "latest", "go"},
+		{"go", "1.21", "go_1_21"},
+		{"go", "1.22.3", "go"},
+		{"numpy", "1", "python3Packages.numpy"},
+		{"python3Packages.numpy", "latest", "python3Packages.numpy"},
+		{"go", "^1.21", "go"},
+		{"go", "~1.21", "go_1_21"},
+		{"go", ">=1.20 <1.22", "go_1_21"},
+	}
+	for _, tc := range resolveTests {
+		pkg, err := s.Resolve(ctx, tc.name, tc.version)
+		if err != nil {
+			t.Errorf("Resolve(%q, %q) got error: %v", tc.name, tc.version, err)
+			continue
+		}
+		if pkg.Name != tc.wantAttr {
+			t.Errorf("Resolve(%q, %q) got %q, want %q", tc.name, tc.version, pkg.Name, tc.wantAttr)
+		}
+	}
+	if _, err := s.Resolve(ctx, "go", "1.2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v for go@1.2, want ErrNotFound", err)
+	}
+
+	resp, err := s.ResolveV2(ctx, "go", "1.21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys, ok := resp.Systems["x86_64-linux"]
+	if !ok {
+		t.Fatalf("got systems %v, want x86_64-linux", resp.Systems)
+	}
+	if got, want := sys.FlakeInstallable.String(), "github:NixOS/nixpkgs/abc123#go_1_21"; got != want {
+		t.Errorf("got installable %q, want %q", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv(envir.DevboxSearchNixpkgs, "")
+	t.Setenv(envir.DevboxSearchDump, "")
+
+	if s := New(LocalIndexConfig{}); IsLocalIndex(s) {
+		t.Fatalf("got New() of type %T without a local index, want *client", s)
+	}
+
+	cfg := LocalIndexConfig{Nixpkgs: "/src/nixpkgs", Dump: "/src/dump.json"}
+	l, ok := New(cfg).(*localIndex)
+	if !ok {
+		t.Fatalf("got New() of type %T, want *localIndex", New(cfg))
+	}
+	if l.nixpkgs != "/src/nixpkgs" || l.dump != "/src/dump.json" {
+		t.Errorf("got index of %q with dump %q, want %q with dump %q",
+			l.nixpkgs, l.dump, "/src/nixpkgs", "/src/dump.json")
+	}
+
+	// The env var overrides devbox.json, including its dump.
+	t.Setenv(envir.DevboxSearchNixpkgs, "github:NixOS/nixpkgs/nixos-unstable")
+	l = newLocalIndex(cfg)
+	if l.nixpkgs != "github:NixOS/nixpkgs/nixos-unstable" || l.dump != "" {
+		t.Errorf("got index of %q with dump %q, want %q without a dump",
+			l.nixpkgs, l.dump, "github:NixOS/nixpkgs/nixos-unstable")
+	}
+}
+
+func TestLocalIndexRetriesFailedLoads(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv(envir.DevboxSearchNixpkgs, "")
+	dump := filepath.Join(t.TempDir(), "dump.json")
+	l := newLocalIndex(LocalIndexConfig{Nixpkgs: "github:NixOS/nixpkgs/nixos-unstable", Dump: dump})
+
+	// The dump is missing, so the index can't be read.
+	if err := os.MkdirAll(filepath.Dir(l.path()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(l.path(), []byte(`{"system": "x86_64-linux"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.load(context.Background()); err == nil {
+		t.Fatal("got no error loading an index without its dump")
+	}
+
+	// Once the dump exists, and is older than the index, the index is read.
+	if err := os.WriteFile(dump, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(dump, old, old); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := l.load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.System != "x86_64-linux" {
+		t.Errorf("got index for system %q, want x86_64-linux", idx.System)
+	}
+}
@@ -58,39 +58,45 @@ type ResolveResponse struct {
 	// Systems contains information about the package that can vary across
 	// systems. It will always have at least one system. The keys match a
 	// Nix system identifier (aarch64-darwin, x86_64-linux, etc.).
-	Systems map[string]struct {
-		// FlakeInstallable is a Nix installable that specifies how to
-		// install the resolved package version.
-		//
-		// [Nix installable]: https://nixos.org/manual/nix/stable/command-ref/new-cli/nix#installables
-		FlakeInstallable flake.Installable `json:"flake_installable"`
-
-		// LastUpdated is the timestamp of the most recent change to the
-		// package.
-		LastUpdated time.Time `json:"last_updated"`
-
-		// Outputs provides additional information about the Nix store
-		// paths that this package installs. This field is not available
-		// for some (especially older) packages.
-		Outputs []struct {
-			// Name is the output's name. Nix appends the name to
-			// the output's store path unless it's the default name
-			// of "out". Output names can be anything, but
-			// conventionally they follow the various "make install"
-			// directories such as "bin", "lib", "src", "man", etc.
-			Name string `json:"name,omitempty"`
-
-			// Path is the absolute store path (with the /nix/store/
-			// prefix) of the output.
-			Path string `json:"path,omitempty"`
-
-			// Default indicates if Nix installs this output by
-			// default.
-			Default bool `json:"default,omitempty"`
-
-			// NAR is set to the package's NAR archive URL when the
-			// output exists in the cache.nixos.org binary cache.
-			NAR string `json:"nar,omitempty"`
-		} `json:"outputs,omitempty"`
-	} `json:"systems"`
+	Systems map[string]ResolvedSystem `json:"systems"`
+}
+
+// ResolvedSystem is the system-specific part of a ResolveResponse.
+type ResolvedSystem struct {
+	// FlakeInstallable is a Nix installable that specifies how to
+	// install the resolved package version.
+	//
+	// [Nix installable]: https://nixos.org/manual/nix/stable/command-ref/new-cli/nix#installables
+	FlakeInstallable flake.Installable `json:"flake_installable"`
+
+	// LastUpdated is the timestamp of the most recent change to the
+	// package.
+	LastUpdated time.Time `json:"last_updated"`
+
+	// Outputs provides additional information about the Nix store
+	// paths that this package installs. This field is not available
+	// for some (especially older) packages.
+	Outputs []ResolvedOutput `json:"outputs,omitempty"`
+}
+
+// ResolvedOutput is a Nix store path that a resolved package installs.
+type ResolvedOutput struct {
+	// Name is the output's name. Nix appends the name to
+	// the output's store path unless it's the default name
+	// of "out". Output names can be anything, but
+	// conventionally they follow the various "make install"
+	// directories such as "bin", "lib", "src", "man", etc.
+	Name string `json:"name,omitempty"`
+
+	// Path is the absolute store path (with the /nix/store/
+	// prefix) of the output.
+	Path string `json:"path,omitempty"`
+
+	// Default indicates if Nix installs this output by
+	// default.
+	Default bool `json:"default,omitempty"`
+
+	// NAR is set to the package's NAR archive URL when the
+	// output exists in the cache.nixos.org binary cache.
+	NAR string `json:"nar,omitempty"`
 }
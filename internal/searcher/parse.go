@@ -8,7 +8,9 @@ import (
 )
 
 // ParseVersionedPackage checks if the given package is a versioned package
-// (`python@3.10`) and returns its name and version
+// (`python@3.10`) and returns its name and version. The version may be a
+// range such as `^3.10` or `>=3.11 <3.13`; use ParseVersionConstraint to
+// parse it.
 func ParseVersionedPackage(versionedName string) (name, version string, found bool) {
 	// use the last @ symbol as the version delimiter, some packages have @ in the name
 	atSymbolIndex := strings.LastIndex(versionedName, "@")
@@ -0,0 +1,80 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+)
+
+// CrashedServices returns the processes that exited with an error or failed
+// to start. Processes that were skipped because a dependency failed aren't
+// included, since they never ran.
+func CrashedServices(processes []Process) []Process {
+	crashed := []Process{}
+	for _, p := range processes {
+		if p.Status == types.ProcessStateError ||
+			(p.Status == types.ProcessStateCompleted && p.ExitCode != 0) {
+			crashed = append(crashed, p)
+		}
+	}
+	return crashed
+}
+
+// PrintServiceLogs writes the logs of each process to w under a header with
+// its name and status.
+func PrintServiceLogs(ctx context.Context, w io.Writer, projectDir string, processes []Process) {
+	for _, p := range processes {
+		fmt.Fprintf(w, "\n=== Logs of service %s (%s, exit code %d) ===\n", p.Name, p.Status, p.ExitCode)
+		lines, err := GetServiceLogs(ctx, projectDir, p.Name, -1)
+		if err != nil {
+			fmt.Fprintf(w, "Error getting logs: %s\n", err)
+			continue
+		}
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// StopProcessManagerAndWait stops the project's process manager like
+// StopProcessManager, and then waits until it exits, which is once it has
+// stopped all of its services.
+func StopProcessManagerAndWait(ctx context.Context, projectDir string, w io.Writer) error {
+	instances, err := liveInstances()
+	if err != nil {
+		return err
+	}
+	pid := instances[projectDir].Pid
+	if err := StopProcessManager(ctx, projectDir, w); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for !processExited(pid) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the process manager to stop: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func processExited(pid int) bool {
+	// A child process that exited is a zombie until it's reaped, and looks
+	// alive until then. The process manager is a child of this process when
+	// `devbox services up --ci` starts it.
+	var status syscall.WaitStatus
+	if wpid, _ := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); wpid == pid {
+		return true
+	}
+	return !processIsAlive(pid)
+}
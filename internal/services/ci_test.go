@@ -0,0 +1,65 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+)
+
+func TestCrashedServices(t *testing.T) {
+	processes := []Process{
+		{Name: "db", Status: types.ProcessStateRunning},
+		{Name: "migrate", Status: types.ProcessStateCompleted},
+		{Name: "web", Status: types.ProcessStateCompleted, ExitCode: 2},
+		{Name: "worker", Status: types.ProcessStateError, ExitCode: 1},
+		{Name: "after-web", Status: types.ProcessStateSkipped},
+	}
+	var names []string
+	for _, p := range CrashedServices(processes) {
+		names = append(names, p.Name)
+	}
+	if len(names) != 2 || names[0] != "web" || names[1] != "worker" {
+		t.Errorf("got crashed services %v, want [web worker]", names)
+	}
+}
+
+func TestStopProcessManagerAndWait(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	projectDir := t.TempDir()
+
+	// The process manager is a child process that hasn't been waited for,
+	// like one started by `devbox services up --ci`.
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	path, err := globalProcessComposeJSONPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(instanceMap{projectDir: {Pid: cmd.Process.Pid}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := StopProcessManagerAndWait(ctx, projectDir, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if ProcessManagerIsRunning(projectDir) {
+		t.Error("got process manager running after stopping it")
+	}
+}
@@ -17,15 +17,19 @@ import (
 type processStates = types.ProcessesState
 
 type Process struct {
-	Name     string
-	Status   string
-	ExitCode int
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+
+	// Health is the result of the process's readiness probe: "Ready",
+	// "Not Ready", or "-" if it has no probe or hasn't been probed yet.
+	Health string `json:"health"`
 }
 
 func StartServices(ctx context.Context, w io.Writer, serviceName, projectDir string) error {
 	path := fmt.Sprintf("/process/start/%s", serviceName)
 
-	body, status, err := clientRequest(path, http.MethodPost, projectDir)
+	body, status, err := clientRequest(ctx, path, http.MethodPost, projectDir)
 	if err != nil {
 		return err
 	}
@@ -42,7 +46,7 @@ func StartServices(ctx context.Context, w io.Writer, serviceName, projectDir str
 func StopServices(ctx context.Context, serviceName, projectDir string, w io.Writer) error {
 	path := fmt.Sprintf("/process/stop/%s", serviceName)
 
-	body, status, err := clientRequest(path, http.MethodPatch, projectDir)
+	body, status, err := clientRequest(ctx, path, http.MethodPatch, projectDir)
 	if err != nil {
 		return err
 	}
@@ -59,7 +63,7 @@ func StopServices(ctx context.Context, serviceName, projectDir string, w io.Writ
 func RestartServices(ctx context.Context, serviceName, projectDir string, w io.Writer) error {
 	path := fmt.Sprintf("/process/restart/%s", serviceName)
 
-	body, status, err := clientRequest(path, http.MethodPost, projectDir)
+	body, status, err := clientRequest(ctx, path, http.MethodPost, projectDir)
 	if err != nil {
 		return err
 	}
@@ -77,7 +81,7 @@ func ListServices(ctx context.Context, projectDir string, w io.Writer) ([]Proces
 	path := "/processes"
 	results := []Process{}
 
-	body, status, err := clientRequest(path, http.MethodGet, projectDir)
+	body, status, err := clientRequest(ctx, path, http.MethodGet, projectDir)
 	if err != nil {
 		return results, err
 	}
@@ -94,6 +98,7 @@ func ListServices(ctx context.Context, projectDir string, w io.Writer) ([]Proces
 				Name:     process.Name,
 				Status:   process.Status,
 				ExitCode: process.ExitCode,
+				Health:   process.Health,
 			})
 		}
 		return results, nil
@@ -102,14 +107,14 @@ func ListServices(ctx context.Context, projectDir string, w io.Writer) ([]Proces
 	}
 }
 
-func clientRequest(path, method, projectDir string) (string, int, error) {
+func clientRequest(ctx context.Context, path, method, projectDir string) (string, int, error) {
 	port, err := GetProcessManagerPort(projectDir)
 	if err != nil {
 		err := fmt.Errorf("unable to connect to process-compose server: %s", err.Error())
 		return "", 0, err
 	}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:%d%s", port, path), nil)
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://localhost:%d%s", port, path), nil)
 	if err != nil {
 		return "", 0, err
 	}
@@ -0,0 +1,232 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/f1bonacc1/process-compose/src/health"
+	"github.com/f1bonacc1/process-compose/src/types"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+// GeneratedProcessComposePath is where devbox writes the process-compose
+// project for the services in devbox.json, relative to the project directory.
+const GeneratedProcessComposePath = ".devbox/process-compose.yaml"
+
+// composeProject and composeProcess are the subset of the process-compose
+// file format that devbox generates.
+type composeProject struct {
+	Version   string                    `yaml:"version"`
+	Processes map[string]composeProcess `yaml:"processes"`
+}
+
+type composeProcess struct {
+	Command        string                       `yaml:"command"`
+	WorkingDir     string                       `yaml:"working_dir,omitempty"`
+	Environment    []string                     `yaml:"environment,omitempty"`
+	DependsOn      map[string]composeDependency `yaml:"depends_on,omitempty"`
+	ReadinessProbe *health.Probe                `yaml:"readiness_probe,omitempty"`
+	ReadyLogLine   string                       `yaml:"ready_log_line,omitempty"`
+	Availability   *composeAvailability         `yaml:"availability,omitempty"`
+}
+
+type composeDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+type composeAvailability struct {
+	Restart string `yaml:"restart"`
+}
+
+// FromConfig writes a process-compose project for services declared in
+// devbox.json to GeneratedProcessComposePath and returns them. hasProbe
+// reports whether a service that isn't in svcs, such as a plugin service, has
+// a readiness probe, so that dependents wait for it to be ready. If svcs is
+// empty, any previously generated file is removed.
+func FromConfig(
+	projectDir string,
+	svcs map[string]*configfile.Service,
+	hasProbe func(name string) bool,
+) (Services, error) {
+	path := filepath.Join(projectDir, GeneratedProcessComposePath)
+	if len(svcs) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.WithStack(err)
+		}
+		return nil, nil
+	}
+
+	project := composeProject{Version: "0.5", Processes: map[string]composeProcess{}}
+	for name, svc := range svcs {
+		process, err := composeProcessFromConfig(name, svc, svcs, hasProbe)
+		if err != nil {
+			return nil, err
+		}
+		project.Processes[name] = process
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "# This file is generated by devbox from the services in devbox.json.")
+	fmt.Fprintln(buf, "# Do not edit it.")
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(project); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Only write the file when it changes so that it isn't touched every
+	// time the services are listed.
+	if existing, err := os.ReadFile(path); err != nil || !bytes.Equal(existing, buf.Bytes()) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return FromProcessCompose(path)
+}
+
+func composeProcessFromConfig(
+	name string,
+	svc *configfile.Service,
+	svcs map[string]*configfile.Service,
+	hasProbe func(name string) bool,
+) (composeProcess, error) {
+	process := composeProcess{
+		Command:    svc.Command,
+		WorkingDir: svc.WorkingDir,
+	}
+
+	envNames := make([]string, 0, len(svc.Env))
+	for k := range svc.Env {
+		envNames = append(envNames, k)
+	}
+	slices.Sort(envNames)
+	for _, k := range envNames {
+		process.Environment = append(process.Environment, k+"="+svc.Env[k])
+	}
+
+	for _, dep := range svc.DependsOn {
+		condition := types.ProcessConditionStarted
+		if depSvc, ok := svcs[dep]; ok {
+			switch {
+			case depSvc.ReadinessProbe == nil:
+			case depSvc.ReadinessProbe.LogLine != "":
+				condition = types.ProcessConditionLogReady
+			default:
+				condition = types.ProcessConditionHealthy
+			}
+		} else if hasProbe(dep) {
+			condition = types.ProcessConditionHealthy
+		}
+		if process.DependsOn == nil {
+			process.DependsOn = map[string]composeDependency{}
+		}
+		process.DependsOn[dep] = composeDependency{Condition: condition}
+	}
+
+	if svc.Restart != "" && svc.Restart != "no" {
+		process.Availability = &composeAvailability{Restart: svc.Restart}
+	}
+
+	probe := svc.ReadinessProbe
+	if probe == nil {
+		return process, nil
+	}
+	if probe.LogLine != "" {
+		process.ReadyLogLine = probe.LogLine
+		return process, nil
+	}
+	process.ReadinessProbe = &health.Probe{
+		InitialDelay:     probe.InitialDelaySeconds,
+		PeriodSeconds:    probe.PeriodSeconds,
+		TimeoutSeconds:   probe.TimeoutSeconds,
+		FailureThreshold: probe.FailureThreshold,
+	}
+	if probe.Exec != "" {
+		process.ReadinessProbe.Exec = &health.ExecProbe{
+			Command:    probe.Exec,
+			WorkingDir: svc.WorkingDir,
+		}
+		return process, nil
+	}
+	httpProbe, err := httpProbeFromURL(probe.HTTP)
+	if err != nil {
+		return composeProcess{}, usererr.WithUserMessage(
+			err, "Service %s has an invalid readiness_probe.http URL %q", name, probe.HTTP)
+	}
+	process.ReadinessProbe.HttpGet = httpProbe
+	return process, nil
+}
+
+func httpProbeFromURL(rawURL string) (*health.HttpProbe, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if u.Port() != "" {
+		if port, err = strconv.Atoi(u.Port()); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if path == "" {
+		path = "/"
+	}
+	return &health.HttpProbe{
+		Host:   u.Hostname(),
+		Path:   path,
+		Scheme: u.Scheme,
+		Port:   port,
+	}, nil
+}
+
+// loadProcessComposeFiles merges process-compose files into one project.
+// If more than one file defines a process, the last one wins.
+func loadProcessComposeFiles(files []string) (*types.Project, error) {
+	project := &types.Project{Processes: types.Processes{}}
+	seen := map[string]bool{}
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		p := types.Project{}
+		if err := yaml.Unmarshal(data, &p); err != nil {
+			return nil, usererr.WithUserMessage(err, "Failed to parse %s", file)
+		}
+		project.Environment = append(project.Environment, p.Environment...)
+		for name, proc := range p.Processes {
+			proc.Name = name
+			project.Processes[name] = proc
+		}
+	}
+	return project, nil
+}
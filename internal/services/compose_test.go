@@ -0,0 +1,91 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+func TestFromConfig(t *testing.T) {
+	projectDir := t.TempDir()
+	cfgSvcs := map[string]*configfile.Service{
+		"web": {
+			Command:    "python -m http.server 8000",
+			WorkingDir: filepath.Join(projectDir, "site"),
+			Env:        map[string]string{"B": "2", "A": "1"},
+			DependsOn:  []string{"postgresql", "worker"},
+			Restart:    "on_failure",
+			ReadinessProbe: &configfile.ReadinessProbe{
+				HTTP:          "http://localhost:8000/health?full=1",
+				PeriodSeconds: 2,
+			},
+		},
+		"worker": {
+			Command:        "./worker",
+			ReadinessProbe: &configfile.ReadinessProbe{LogLine: "worker started"},
+		},
+	}
+	hasProbe := func(name string) bool { return name == "postgresql" }
+
+	svcs, err := FromConfig(projectDir, cfgSvcs, hasProbe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(projectDir, GeneratedProcessComposePath)
+	wantSvcs := Services{
+		"web":    {Name: "web", ProcessComposePath: path, HasReadinessProbe: true},
+		"worker": {Name: "worker", ProcessComposePath: path, HasReadinessProbe: true},
+	}
+	if diff := cmp.Diff(wantSvcs, svcs); diff != "" {
+		t.Errorf("wrong services (-want +got):\n%s", diff)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := types.Project{}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		t.Fatal(err)
+	}
+	web := project.Processes["web"]
+	if got, want := web.Environment, (types.Environment{"A=1", "B=2"}); !cmp.Equal(got, want) {
+		t.Errorf("got web environment %v, want %v", got, want)
+	}
+	if got := web.DependsOn["postgresql"].Condition; got != types.ProcessConditionHealthy {
+		t.Errorf("got postgresql dependency condition %q, want %q", got, types.ProcessConditionHealthy)
+	}
+	if got := web.DependsOn["worker"].Condition; got != types.ProcessConditionLogReady {
+		t.Errorf("got worker dependency condition %q, want %q", got, types.ProcessConditionLogReady)
+	}
+	if got := web.RestartPolicy.Restart; got != types.RestartPolicyOnFailure {
+		t.Errorf("got web restart policy %q, want %q", got, types.RestartPolicyOnFailure)
+	}
+	probe := web.ReadinessProbe
+	if probe == nil || probe.HttpGet == nil {
+		t.Fatalf("got web readiness probe %+v, want an http probe", probe)
+	}
+	if probe.HttpGet.Port != 8000 || probe.HttpGet.Path != "/health?full=1" || probe.PeriodSeconds != 2 {
+		t.Errorf("got web http probe %+v, period %d", probe.HttpGet, probe.PeriodSeconds)
+	}
+	if got := project.Processes["worker"].ReadyLogLine; got != "worker started" {
+		t.Errorf("got worker ready log line %q, want %q", got, "worker started")
+	}
+
+	// Removing the services from devbox.json removes the generated file.
+	if _, err := FromConfig(projectDir, nil, hasProbe); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("got err %v for generated file, want it to be removed", err)
+	}
+}
@@ -37,10 +37,11 @@ func FromProcessCompose(path string) (Services, error) {
 		return nil, err
 	}
 
-	for name := range processCompose.Processes {
+	for name, process := range processCompose.Processes {
 		svc := Service{
 			Name:               name,
 			ProcessComposePath: path,
+			HasReadinessProbe:  process.ReadinessProbe != nil || process.ReadyLogLine != "",
 		}
 		services[name] = svc
 	}
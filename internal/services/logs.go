@@ -0,0 +1,252 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// logsPollInterval is how often followed logs are fetched from
+	// process-compose.
+	logsPollInterval = 500 * time.Millisecond
+
+	// logsFollowWindow is the number of lines fetched on every poll when
+	// following logs. A service that writes more lines than this between
+	// polls will have some lines skipped.
+	logsFollowWindow = 1000
+)
+
+// LogsOpts configures how StreamLogs prints service logs.
+type LogsOpts struct {
+	// Follow keeps streaming new log lines until ctx is canceled.
+	Follow bool
+
+	// Since skips lines that have a timestamp before it. Lines without a
+	// timestamp use the timestamp of the line before them.
+	Since time.Time
+
+	// Tail is the number of lines to print from the end of each service's
+	// logs. A negative value prints all lines that process-compose has
+	// buffered.
+	Tail int
+}
+
+// prefixColors are the colors used for service name prefixes, in order.
+var prefixColors = []color.Attribute{
+	color.FgCyan,
+	color.FgYellow,
+	color.FgGreen,
+	color.FgMagenta,
+	color.FgBlue,
+	color.FgRed,
+}
+
+// GetServiceLogs returns the last tail lines of a service's logs from the
+// process-compose server of a project. If tail is negative, it returns all
+// lines.
+func GetServiceLogs(ctx context.Context, projectDir, serviceName string, tail int) ([]string, error) {
+	if tail < 0 {
+		tail = math.MaxInt32
+	}
+	path := fmt.Sprintf("/process/logs/%s/%d/0", url.PathEscape(serviceName), tail)
+
+	body, status, err := clientRequest(ctx, path, http.MethodGet, projectDir)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unable to get logs for service %s: %s", serviceName, body)
+	}
+
+	var resp struct {
+		Logs []string `json:"logs"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return nil, fmt.Errorf("unable to parse logs for service %s: %w", serviceName, err)
+	}
+	return resp.Logs, nil
+}
+
+// StreamLogs writes the logs of the given services to w. When there is more
+// than one service, each line is prefixed with the name of its service.
+func StreamLogs(
+	ctx context.Context,
+	w io.Writer,
+	projectDir string,
+	serviceNames []string,
+	opts LogsOpts,
+) error {
+	printer := newLogPrinter(w, serviceNames)
+	group, ctx := errgroup.WithContext(ctx)
+	for _, name := range serviceNames {
+		group.Go(func() error {
+			return streamServiceLogs(ctx, printer, projectDir, name, opts)
+		})
+	}
+	return group.Wait()
+}
+
+func streamServiceLogs(
+	ctx context.Context,
+	printer *logPrinter,
+	projectDir, serviceName string,
+	opts LogsOpts,
+) error {
+	// When following, fetch at least a full window so that polling can tell
+	// which lines are new.
+	fetch := opts.Tail
+	if opts.Follow && fetch >= 0 {
+		fetch = max(fetch, logsFollowWindow)
+	}
+	seen, err := GetServiceLogs(ctx, projectDir, serviceName, fetch)
+	if err != nil {
+		return err
+	}
+	lines := seen
+	if opts.Tail >= 0 {
+		lines = lines[max(0, len(lines)-opts.Tail):]
+	}
+	if !opts.Since.IsZero() {
+		lines = linesSince(lines, opts.Since)
+	}
+	printer.print(serviceName, lines)
+	if !opts.Follow {
+		return nil
+	}
+
+	// The process-compose API has no cursor for logs, so we poll the end of
+	// the log buffer and print whatever comes after the lines we've already
+	// seen.
+	seen = seen[max(0, len(seen)-logsFollowWindow):]
+	ticker := time.NewTicker(logsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		latest, err := GetServiceLogs(ctx, projectDir, serviceName, logsFollowWindow)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		printer.print(serviceName, newLogLines(seen, latest))
+		seen = latest
+	}
+}
+
+// newLogLines returns the lines at the end of latest that aren't in seen. Both
+// are windows at the end of the same log buffer, so the new lines are the
+// ones after the longest suffix of seen that is a prefix of latest.
+func newLogLines(seen, latest []string) []string {
+	for overlap := min(len(seen), len(latest)); overlap > 0; overlap-- {
+		if slicesEqual(seen[len(seen)-overlap:], latest[:overlap]) {
+			return latest[overlap:]
+		}
+	}
+	return latest
+}
+
+func slicesEqual(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// logTimestampRe matches a timestamp at the start of a log line, such as
+// "2024-05-01T12:30:00Z", "2024-05-01 12:30:00.123 UTC", or "[2024-05-01
+// 12:30:00]".
+var logTimestampRe = regexp.MustCompile(
+	`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)`)
+
+// linesSince drops lines that were logged before since. Lines without a
+// timestamp are attributed to the closest timestamped line before them, and
+// lines before the first timestamp are kept because their time is unknown.
+func linesSince(lines []string, since time.Time) []string {
+	result := []string{}
+	keep := true
+	for _, line := range lines {
+		if t, ok := parseLogTimestamp(line); ok {
+			keep = !t.Before(since)
+		}
+		if keep {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func parseLogTimestamp(line string) (time.Time, bool) {
+	match := logTimestampRe.FindStringSubmatch(line)
+	if match == nil {
+		return time.Time{}, false
+	}
+	value := strings.Replace(strings.Replace(match[1], " ", "T", 1), ",", ".", 1)
+	for _, layout := range []string{
+		"2006-01-02T15:04:05.999999999Z07:00",
+		"2006-01-02T15:04:05.999999999Z0700",
+	} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	// No zone, so assume the service logs in local time.
+	t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", value, time.Local)
+	return t, err == nil
+}
+
+// logPrinter writes log lines from several services to the same writer
+// without interleaving partial lines.
+type logPrinter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	prefixes map[string]string
+}
+
+func newLogPrinter(w io.Writer, serviceNames []string) *logPrinter {
+	p := &logPrinter{w: w, prefixes: map[string]string{}}
+	if len(serviceNames) < 2 {
+		return p
+	}
+
+	width := 0
+	for _, name := range serviceNames {
+		width = max(width, len(name))
+	}
+	for i, name := range serviceNames {
+		c := color.New(prefixColors[i%len(prefixColors)])
+		p.prefixes[name] = c.Sprintf("%-*s |", width, name) + " "
+	}
+	return p
+}
+
+func (p *logPrinter) print(serviceName string, lines []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prefix := p.prefixes[serviceName]
+	for _, line := range lines {
+		fmt.Fprintf(p.w, "%s%s\n", prefix, strings.TrimRight(line, "\n"))
+	}
+}
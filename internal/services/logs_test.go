@@ -0,0 +1,130 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewLogLines(t *testing.T) {
+	tests := []struct {
+		name         string
+		seen, latest []string
+		want         []string
+	}{
+		{"no new lines", []string{"a", "b"}, []string{"a", "b"}, []string{}},
+		{"appended", []string{"a", "b"}, []string{"a", "b", "c"}, []string{"c"}},
+		{"window moved", []string{"a", "b", "c"}, []string{"b", "c", "d", "e"}, []string{"d", "e"}},
+		{"repeated lines", []string{"x", "x"}, []string{"x", "x", "x"}, []string{"x"}},
+		{"no overlap", []string{"a"}, []string{"b", "c"}, []string{"b", "c"}},
+		{"nothing seen", nil, []string{"a"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newLogLines(tt.seen, tt.latest)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("wrong new lines (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLinesSince(t *testing.T) {
+	lines := []string{
+		"starting up",
+		"2024-05-01T12:00:00Z old line",
+		"  old continuation",
+		"[2024-05-01 12:10:00.123+00:00] new line",
+		"  new continuation",
+	}
+	since := time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC)
+	want := []string{
+		"starting up",
+		"[2024-05-01 12:10:00.123+00:00] new line",
+		"  new continuation",
+	}
+	if diff := cmp.Diff(want, linesSince(lines, since)); diff != "" {
+		t.Errorf("wrong lines (-want +got):\n%s", diff)
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+	logs := map[string][]string{
+		"db":  {"db 1", "db 2", "db 3"},
+		"web": {"web 1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /process/logs/{name}/{endOffset}/{limit}
+		parts := strings.Split(r.URL.Path, "/")
+		name, _ := url.PathUnescape(parts[3])
+		offset, _ := strconv.Atoi(parts[4])
+		lines := logs[name]
+		_ = json.NewEncoder(w).Encode(map[string][]string{
+			"logs": lines[len(lines)-min(offset, len(lines)):],
+		})
+	}))
+	defer server.Close()
+
+	projectDir := t.TempDir()
+	writeTestInstance(t, projectDir, server.URL)
+
+	buf := &bytes.Buffer{}
+	err := StreamLogs(context.Background(), buf, projectDir, []string{"db"}, LogsOpts{Tail: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "db 2\ndb 3\n"; got != want {
+		t.Errorf("got logs %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	err = StreamLogs(context.Background(), buf, projectDir, []string{"db", "web"}, LogsOpts{Tail: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"db  | db 1\n", "db  | db 3\n", "web | web 1\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got logs %q, want them to contain %q", buf.String(), want)
+		}
+	}
+}
+
+// writeTestInstance records serverURL's port as the process-compose port of
+// projectDir in the global process-compose.json.
+func writeTestInstance(t *testing.T, projectDir, serverURL string) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := globalProcessComposeJSONPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(instanceMap{projectDir: {Pid: os.Getpid(), Port: port}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -30,6 +30,8 @@ const (
 type instance struct {
 	Pid  int `json:"pid"`
 	Port int `json:"port"`
+	// StartedAt is zero for instances started by older versions of devbox.
+	StartedAt time.Time `json:"started_at,omitempty"`
 }
 
 type instanceMap = map[string]instance
@@ -44,6 +46,15 @@ type ProcessComposeOpts struct {
 	BinPath    string
 	ExtraFlags []string
 	Background bool
+
+	// Native runs the services with the built-in Supervisor instead of
+	// process-compose. BinPath is then the devbox binary, which is run with
+	// the hidden `devbox services supervise` command.
+	Native bool
+
+	// Watches restart services when files change. They're only watched
+	// while the process manager runs in the foreground.
+	Watches map[string]WatchConfig
 }
 
 func newGlobalProcessComposeConfig() *globalProcessComposeConfig {
@@ -128,19 +139,17 @@ func StartProcessManager(
 	config := readGlobalProcessComposeJSON(configFile)
 	config.File = configFile
 
-	// Get the port to use for this project
-	port, err := getAvailablePort()
-	if err != nil {
-		return err
+	// Get the port to use for this project. It's stable so that tools can
+	// find the process-compose API, unless something else is using it.
+	port, err := AllocatePort(projectDir, ProcessComposePortName, 0)
+	if err != nil || !portIsFree(port) {
+		port, err = getAvailablePort()
+		if err != nil {
+			return err
+		}
 	}
 
-	// Start building the process-compose command
-	flags := []string{"-p", strconv.Itoa(port)}
-	upCommand := []string{"up"}
-
 	if len(requestedServices) > 0 {
-		flags = append(requestedServices, flags...)
-		flags = append(upCommand, flags...)
 		fmt.Fprintf(w, "Starting services: %s \n", strings.Join(requestedServices, ", "))
 	} else {
 		services := []string{}
@@ -150,6 +159,33 @@ func StartProcessManager(
 		fmt.Fprintf(w, "Starting all services: %s \n", strings.Join(services, ", "))
 	}
 
+	if processComposeConfig.Native {
+		args := []string{"services", "supervise", "--port", strconv.Itoa(port)}
+		for _, s := range availableServices {
+			args = append(args, "--file", s.ProcessComposePath)
+		}
+		args = append(args, requestedServices...)
+		cmd := exec.Command(processComposeConfig.BinPath, args...)
+		if processComposeConfig.Background {
+			return runProcessManagerInBackground(cmd, config, port, projectDir)
+		}
+		// Unlike process-compose, the supervisor has no TUI and prints
+		// service output instead.
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = w
+		defer watchInForeground(projectDir, processComposeConfig.Watches, w)()
+		return runProcessManagerInForeground(cmd, config, port, projectDir, w)
+	}
+
+	// Start building the process-compose command
+	flags := []string{"-p", strconv.Itoa(port)}
+	upCommand := []string{"up"}
+
+	if len(requestedServices) > 0 {
+		flags = append(requestedServices, flags...)
+		flags = append(upCommand, flags...)
+	}
+
 	for _, s := range availableServices {
 		flags = append(flags, "-f", s.ProcessComposePath)
 	}
@@ -163,17 +199,38 @@ func StartProcessManager(
 	}
 
 	cmd := exec.Command(processComposeConfig.BinPath, flags...)
+	// Don't write to the terminal while the process-compose TUI is using
+	// it. The TUI shows restarts anyway.
+	defer watchInForeground(projectDir, processComposeConfig.Watches, io.Discard)()
 	return runProcessManagerInForeground(cmd, config, port, projectDir, w)
 }
 
+// watchInForeground watches files for the services in watches while the
+// process manager runs. It returns a function that stops watching.
+func watchInForeground(projectDir string, watches map[string]WatchConfig, w io.Writer) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := WatchServices(ctx, w, projectDir, watches); err != nil {
+			fmt.Fprintf(w, "Error watching files, services won't restart when they change: %s\n", err)
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
 func runProcessManagerInForeground(cmd *exec.Cmd, config *globalProcessComposeConfig, port int, projectDir string, w io.Writer) error {
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start process-compose: %w", err)
 	}
 
 	projectConfig := instance{
-		Pid:  cmd.Process.Pid,
-		Port: port,
+		Pid:       cmd.Process.Pid,
+		Port:      port,
+		StartedAt: time.Now(),
 	}
 
 	config.Instances[projectDir] = projectConfig
@@ -186,24 +243,34 @@ func runProcessManagerInForeground(cmd *exec.Cmd, config *globalProcessComposeCo
 	// We're waiting now, so we can unlock the file
 	config.File.Close()
 
-	err = cmd.Wait()
-	if err != nil {
-		if err.Error() == "exit status 1" {
-			fmt.Fprintf(w, "Process-compose was terminated remotely, %s\n", err.Error())
-			return nil
-		}
-		return err
-	}
+	waitErr := cmd.Wait()
 
 	configFile, err := openGlobalConfigFile()
 	if err != nil {
 		return err
 	}
+	defer configFile.Close()
 
 	config = readGlobalProcessComposeJSON(configFile)
+	// Only remove the instance if it's still this one. It's already gone
+	// if it was stopped with `devbox services stop`.
+	if config.Instances[projectDir].Pid == cmd.Process.Pid {
+		delete(config.Instances, projectDir)
+		if err := writeGlobalProcessComposeJSON(config, configFile); err != nil {
+			return err
+		}
+	}
 
-	delete(config.Instances, projectDir)
-	return writeGlobalProcessComposeJSON(config, configFile)
+	if waitErr != nil {
+		if waitErr.Error() == "exit status 1" {
+			fmt.Fprintf(w, "Process-compose was terminated remotely, %s\n", waitErr.Error())
+			return nil
+		}
+		// Exit with the process manager's exit code instead of reporting
+		// an internal error.
+		return usererr.NewExecError(waitErr)
+	}
+	return nil
 }
 
 func runProcessManagerInBackground(cmd *exec.Cmd, config *globalProcessComposeConfig, port int, projectDir string) error {
@@ -221,8 +288,9 @@ func runProcessManagerInBackground(cmd *exec.Cmd, config *globalProcessComposeCo
 	}
 
 	projectConfig := instance{
-		Pid:  cmd.Process.Pid,
-		Port: port,
+		Pid:       cmd.Process.Pid,
+		Port:      port,
+		StartedAt: time.Now(),
 	}
 
 	config.Instances[projectDir] = projectConfig
@@ -324,6 +392,7 @@ func GetProcessManagerPort(projectDir string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	defer configFile.Close()
 
 	config := readGlobalProcessComposeJSON(configFile)
 
@@ -0,0 +1,202 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/cuecfg"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+const (
+	// ProcessComposePortName is the name of the process-compose API port in
+	// the port registry.
+	ProcessComposePortName = "process-compose"
+
+	// Ports that aren't a service's preferred port are assigned from this
+	// range, starting at an offset derived from the project and port name so
+	// that projects don't all compete for the same few ports.
+	registryPortMin = 20000
+	registryPortMax = 29999
+)
+
+// portRegistry is the set of ports assigned to each project, keyed by
+// project directory and then by port name. It's stored next to the global
+// process-compose.json so that every project on the machine sees the same
+// assignments.
+type portRegistry map[string]map[string]int
+
+func portRegistryDir() string {
+	return xdg.DataSubpath(filepath.Join("devbox", "global"))
+}
+
+func portRegistryPath() (string, error) {
+	path := portRegistryDir()
+	return filepath.Join(path, "ports.json"), errors.WithStack(os.MkdirAll(path, 0o755))
+}
+
+// AllocatePort returns the port assigned to name in projectDir, assigning one
+// if it doesn't have one yet. A new assignment uses preferred if no other
+// project has it and it's free, and otherwise a port that no other project
+// has. Assignments are stable: a project keeps its ports until they're
+// released or the project directory is deleted.
+func AllocatePort(projectDir, name string, preferred int) (int, error) {
+	var port int
+	err := updatePortRegistry(func(registry portRegistry) bool {
+		if p, ok := registry[projectDir][name]; ok {
+			port = p
+			return false
+		}
+		port = registry.pick(projectDir, name, preferred)
+		if registry[projectDir] == nil {
+			registry[projectDir] = map[string]int{}
+		}
+		registry[projectDir][name] = port
+		return true
+	})
+	return port, err
+}
+
+// LookupPort returns the port assigned to name in projectDir, if it has one.
+// Unlike AllocatePort, it never modifies the registry.
+func LookupPort(projectDir, name string) (int, bool) {
+	port, ok := readPortRegistry()[projectDir][name]
+	return port, ok
+}
+
+// ProjectPorts returns the ports assigned to projectDir, keyed by name. It
+// never modifies the registry.
+func ProjectPorts(projectDir string) map[string]int {
+	return readPortRegistry()[projectDir]
+}
+
+// ReleasePorts removes the ports of projectDir for which release returns
+// true from the registry, so that other projects can be assigned them. It
+// returns the names of the released ports.
+func ReleasePorts(projectDir string, release func(name string) bool) ([]string, error) {
+	released := []string{}
+	err := updatePortRegistry(func(registry portRegistry) bool {
+		for name := range registry[projectDir] {
+			if release(name) {
+				delete(registry[projectDir], name)
+				released = append(released, name)
+			}
+		}
+		if len(registry[projectDir]) == 0 {
+			delete(registry, projectDir)
+		}
+		return len(released) > 0
+	})
+	slices.Sort(released)
+	return released, err
+}
+
+// readPortRegistry reads the registry without locking or modifying it. A
+// missing or corrupt registry is empty.
+func readPortRegistry() portRegistry {
+	registry := portRegistry{}
+	path := filepath.Join(portRegistryDir(), "ports.json")
+	if err := cuecfg.ParseFile(path, &registry); err != nil {
+		return portRegistry{}
+	}
+	return registry
+}
+
+// updatePortRegistry calls update with the registry while holding a lock on
+// the registry file. If update returns true, the registry is saved.
+func updatePortRegistry(update func(registry portRegistry) bool) error {
+	path, err := portRegistryPath()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o664)
+	if err != nil {
+		return fmt.Errorf("failed to open port registry: %w", err)
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return err
+	}
+
+	registry := portRegistry{}
+	if err := cuecfg.ParseFile(path, &registry); err != nil {
+		// A missing or corrupt registry is replaced rather than breaking
+		// every devbox command.
+		registry = portRegistry{}
+	}
+	pruned := registry.prune()
+
+	if !update(registry) && !pruned {
+		return nil
+	}
+	data, err := cuecfg.MarshalJSON(registry)
+	if err != nil {
+		return fmt.Errorf("failed to convert port registry to json: %w", err)
+	}
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate port registry: %w", err)
+	}
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write port registry: %w", err)
+	}
+	return nil
+}
+
+// prune removes projects whose directories no longer exist and reports
+// whether any were removed.
+func (r portRegistry) prune() bool {
+	pruned := false
+	for projectDir := range r {
+		if _, err := os.Stat(projectDir); errors.Is(err, os.ErrNotExist) {
+			delete(r, projectDir)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+func (r portRegistry) pick(projectDir, name string, preferred int) int {
+	taken := map[int]bool{}
+	for _, ports := range r {
+		for _, port := range ports {
+			taken[port] = true
+		}
+	}
+	if preferred > 0 && !taken[preferred] && portIsFree(preferred) {
+		return preferred
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(projectDir + "\x00" + name))
+	size := registryPortMax - registryPortMin + 1
+	start := int(h.Sum32() % uint32(size))
+	for i := range size {
+		port := registryPortMin + (start+i)%size
+		if !taken[port] && isAllowed(port) && portIsFree(port) {
+			return port
+		}
+	}
+	// Every port in the range is taken, which means something is very
+	// wrong. Fall back to the start of the range rather than failing.
+	return registryPortMin + start
+}
+
+// portIsFree returns true if nothing is listening on port on localhost.
+func portIsFree(port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAllocatePort(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	// Hold a port so that it's never free, and prefer it.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	busy := l.Addr().(*net.TCPAddr).Port
+
+	projectA, projectB := t.TempDir(), t.TempDir()
+	free := freePort(t)
+
+	portA, err := AllocatePort(projectA, "PGPORT", free)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portA != free {
+		t.Errorf("got port %d for project A, want preferred port %d", portA, free)
+	}
+
+	// Project B prefers the same port, but project A already has it.
+	portB, err := AllocatePort(projectB, "PGPORT", free)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portB == portA {
+		t.Errorf("got port %d for both projects", portA)
+	}
+	if portB < registryPortMin || portB > registryPortMax {
+		t.Errorf("got port %d for project B, want a port in [%d, %d]", portB, registryPortMin, registryPortMax)
+	}
+
+	// Assignments are stable.
+	if again, _ := AllocatePort(projectB, "PGPORT", free); again != portB {
+		t.Errorf("got port %d for project B the second time, want %d", again, portB)
+	}
+
+	portBusy, err := AllocatePort(projectA, "REDIS_PORT", busy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portBusy == busy {
+		t.Errorf("got port %d that is in use", busy)
+	}
+
+	ports := ProjectPorts(projectA)
+	if len(ports) != 2 || ports["PGPORT"] != portA || ports["REDIS_PORT"] != portBusy {
+		t.Errorf("got project A ports %v", ports)
+	}
+	if port, ok := LookupPort(projectA, "PGPORT"); !ok || port != portA {
+		t.Errorf("got LookupPort(PGPORT) = %d, %t, want %d, true", port, ok, portA)
+	}
+	if _, ok := LookupPort(projectA, "MISSING"); ok {
+		t.Error("got port for a name that isn't assigned")
+	}
+
+	// Released ports can be assigned to other projects.
+	released, err := ReleasePorts(projectA, func(name string) bool { return name == "PGPORT" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || released[0] != "PGPORT" {
+		t.Errorf("got released ports %v, want [PGPORT]", released)
+	}
+	if _, ok := LookupPort(projectA, "PGPORT"); ok {
+		t.Error("got port for PGPORT after releasing it")
+	}
+	if port, err := AllocatePort(t.TempDir(), "PGPORT", free); err != nil || port != free {
+		t.Errorf("got port %d, %v for a new project after release, want %d", port, err, free)
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	port, err := getAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
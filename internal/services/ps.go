@@ -0,0 +1,119 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"os"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// ProcessManager is a running process-compose instance and the status of
+// its services.
+type ProcessManager struct {
+	ProjectDir string `json:"project_dir"`
+	Pid        int    `json:"pid"`
+	Port       int    `json:"port"`
+
+	// StartedAt is zero if the instance was started by an older version of
+	// devbox that didn't record it.
+	StartedAt time.Time `json:"started_at,omitempty"`
+
+	Services []Process `json:"services"`
+
+	// Error is set if the services couldn't be listed, for example because
+	// process-compose is still starting.
+	Error string `json:"error,omitempty"`
+}
+
+// Uptime returns how long the process manager has been running, or 0 if it
+// isn't known.
+func (pm *ProcessManager) Uptime(now time.Time) time.Duration {
+	if pm.StartedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(pm.StartedAt)
+}
+
+// ListProcessManagers returns every process-compose instance that devbox
+// started on this machine, sorted by project directory. Instances whose
+// process has exited are removed from the global process-compose.json. If
+// projectDir isn't empty, only that project's instance is returned.
+func ListProcessManagers(ctx context.Context, projectDir string) ([]ProcessManager, error) {
+	instances, err := liveInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	managers := []ProcessManager{}
+	for dir, inst := range instances {
+		if projectDir != "" && dir != projectDir {
+			continue
+		}
+		managers = append(managers, ProcessManager{
+			ProjectDir: dir,
+			Pid:        inst.Pid,
+			Port:       inst.Port,
+			StartedAt:  inst.StartedAt,
+		})
+	}
+	slices.SortFunc(managers, func(a, b ProcessManager) int {
+		return cmp.Compare(a.ProjectDir, b.ProjectDir)
+	})
+
+	// Query the process-compose API after the global config is unlocked,
+	// since each request reads the port from it.
+	for i := range managers {
+		svcs, err := ListServices(ctx, managers[i].ProjectDir, io.Discard)
+		if err != nil {
+			managers[i].Error = err.Error()
+			continue
+		}
+		slices.SortFunc(svcs, func(a, b Process) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+		managers[i].Services = svcs
+	}
+	return managers, nil
+}
+
+// liveInstances returns the instances in the global process-compose.json
+// whose process is still running, and removes the rest from the file.
+func liveInstances() (instanceMap, error) {
+	configFile, err := openGlobalConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	defer configFile.Close()
+
+	config := readGlobalProcessComposeJSON(configFile)
+	stale := false
+	for dir, inst := range config.Instances {
+		if !processIsAlive(inst.Pid) {
+			delete(config.Instances, dir)
+			stale = true
+		}
+	}
+	if stale {
+		if err := writeGlobalProcessComposeJSON(config, configFile); err != nil {
+			return nil, err
+		}
+	}
+	return config.Instances, nil
+}
+
+func processIsAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+)
+
+func TestListProcessManagers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(types.ProcessesState{States: []types.ProcessState{
+			{Name: "web", Status: types.ProcessStateRunning},
+			{Name: "postgresql", Status: types.ProcessStateRunning, Health: types.ProcessHealthReady},
+		}})
+	}))
+	defer server.Close()
+
+	liveDir := t.TempDir()
+	writeTestInstance(t, liveDir, server.URL)
+
+	// Add an instance for a process that has exited.
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	deadDir := t.TempDir()
+	path, err := globalProcessComposeJSONPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := instanceMap{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &instances); err != nil {
+		t.Fatal(err)
+	}
+	startedAt := time.Now().Add(-time.Hour)
+	live := instances[liveDir]
+	live.StartedAt = startedAt
+	instances[liveDir] = live
+	instances[deadDir] = instance{Pid: cmd.Process.Pid, Port: 1}
+	if data, err = json.Marshal(instances); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	managers, err := ListProcessManagers(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(managers) != 1 {
+		t.Fatalf("got %d process managers, want 1: %+v", len(managers), managers)
+	}
+	pm := managers[0]
+	if pm.ProjectDir != liveDir || pm.Pid != os.Getpid() || pm.Error != "" {
+		t.Errorf("got process manager %+v, want the one for %s", pm, liveDir)
+	}
+	if got := pm.Uptime(startedAt.Add(time.Minute)); got != time.Minute {
+		t.Errorf("got uptime %s, want %s", got, time.Minute)
+	}
+	if len(pm.Services) != 2 || pm.Services[0].Name != "postgresql" || pm.Services[1].Name != "web" {
+		t.Errorf("got services %+v, want postgresql and web", pm.Services)
+	}
+
+	// The dead instance is removed from the global config.
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances = instanceMap{}
+	if err := json.Unmarshal(data, &instances); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := instances[deadDir]; ok {
+		t.Errorf("got instance for %s after listing, want it to be removed", deadDir)
+	}
+
+	managers, err = ListProcessManagers(context.Background(), deadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(managers) != 0 {
+		t.Errorf("got process managers %+v for %s, want none", managers, deadDir)
+	}
+}
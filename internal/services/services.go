@@ -8,4 +8,9 @@ type Services map[string]Service // name -> Service
 type Service struct {
 	Name               string
 	ProcessComposePath string
+
+	// HasReadinessProbe is true if the process has a readiness probe or a
+	// ready log line, so process-compose reports when it's ready instead of
+	// only when it's running.
+	HasReadinessProbe bool
 }
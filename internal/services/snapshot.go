@@ -0,0 +1,265 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/pullbox/tar"
+)
+
+const (
+	// snapshotsDir is where snapshots are stored, relative to the project
+	// directory.
+	snapshotsDir = ".devbox/snapshots"
+
+	// virtenvDir is where plugins keep their files, relative to the project
+	// directory.
+	virtenvDir = ".devbox/virtenv"
+
+	snapshotManifest = "snapshot.json"
+)
+
+var snapshotNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// Snapshot is a saved copy of the data of plugins that run services, such as
+// a postgresql database.
+type Snapshot struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Plugins are the plugins whose virtenv directories are in the
+	// snapshot.
+	Plugins []string `json:"plugins"`
+}
+
+// PluginServices returns the services of each plugin, keyed by plugin name.
+// A plugin's services are the ones defined in a process-compose file in its
+// virtenv directory.
+func PluginServices(projectDir string, svcs Services) map[string][]string {
+	virtenv := filepath.Join(projectDir, virtenvDir)
+	plugins := map[string][]string{}
+	for name, svc := range svcs {
+		rel, err := filepath.Rel(virtenv, svc.ProcessComposePath)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		plugin := strings.Split(rel, string(filepath.Separator))[0]
+		plugins[plugin] = append(plugins[plugin], name)
+	}
+	for _, names := range plugins {
+		slices.Sort(names)
+	}
+	return plugins
+}
+
+// SaveSnapshot archives the virtenv directories of plugins into a snapshot
+// called name, replacing any snapshot with that name. The plugins' services
+// should be stopped first so that their data is consistent.
+func SaveSnapshot(projectDir, name string, plugins []string) (*Snapshot, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if len(plugins) == 0 {
+		return nil, usererr.New("No plugins with services found in your project")
+	}
+	root := filepath.Join(projectDir, snapshotsDir)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Write the snapshot to a temporary directory first so that a failure
+	// doesn't leave a partial snapshot behind.
+	tmp, err := os.MkdirTemp(root, ".tmp-"+name+"-")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	snapshot := &Snapshot{Name: name, CreatedAt: time.Now(), Plugins: slices.Clone(plugins)}
+	slices.Sort(snapshot.Plugins)
+	for _, plugin := range snapshot.Plugins {
+		dir := filepath.Join(projectDir, virtenvDir, plugin)
+		if _, err := os.Stat(dir); err != nil {
+			return nil, usererr.WithUserMessage(err, "Plugin %s has no data to snapshot", plugin)
+		}
+		if err := tar.CompressTo(dir, filepath.Join(tmp, plugin+".tar.gz")); err != nil {
+			return nil, usererr.WithUserMessage(err, "Failed to archive the data of plugin %s", plugin)
+		}
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, snapshotManifest), data, 0o644); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	dest := filepath.Join(root, name)
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return snapshot, nil
+}
+
+// ReadSnapshot returns the snapshot called name.
+func ReadSnapshot(projectDir, name string) (*Snapshot, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(projectDir, snapshotsDir, name, snapshotManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, usererr.New("Snapshot %s not found. Run `devbox services snapshot list` to see your snapshots.", name)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return snapshot, nil
+}
+
+// RestoreSnapshot replaces the virtenv directories of the plugins in a
+// snapshot with their contents when the snapshot was saved. The plugins'
+// services should be stopped first.
+func RestoreSnapshot(projectDir string, snapshot *Snapshot) error {
+	for _, plugin := range snapshot.Plugins {
+		archive := filepath.Join(projectDir, snapshotsDir, snapshot.Name, plugin+".tar.gz")
+		dir := filepath.Join(projectDir, virtenvDir, plugin)
+
+		// Extract next to the virtenv directory and swap it in, so that a
+		// failed extraction leaves the current data alone.
+		tmp, err := os.MkdirTemp(filepath.Dir(dir), ".restore-"+plugin+"-")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := tar.ExtractFile(archive, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return usererr.WithUserMessage(err, "Failed to restore the data of plugin %s", plugin)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			os.RemoveAll(tmp)
+			return errors.WithStack(err)
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// ListSnapshots returns the project's snapshots, sorted by name.
+func ListSnapshots(projectDir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(projectDir, snapshotsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	snapshots := []Snapshot{}
+	for _, e := range entries {
+		if !e.IsDir() || !snapshotNameRegex.MatchString(e.Name()) {
+			continue
+		}
+		snapshot, err := ReadSnapshot(projectDir, e.Name())
+		if err != nil {
+			// Skip directories that aren't snapshots.
+			continue
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	return snapshots, nil
+}
+
+func validateSnapshotName(name string) error {
+	if !snapshotNameRegex.MatchString(name) {
+		return usererr.New(
+			"Invalid snapshot name %q. Names can contain letters, numbers, '_', '.', and '-'.", name)
+	}
+	return nil
+}
+
+// PauseServices stops whichever of the named services are running and waits
+// for them to exit. It returns a function that starts them again, which
+// should be called even if PauseServices returns an error.
+func PauseServices(
+	ctx context.Context, w io.Writer, projectDir string, names []string,
+) (resume func(context.Context) error, err error) {
+	resume = func(context.Context) error { return nil }
+	if !ProcessManagerIsRunning(projectDir) {
+		return resume, nil
+	}
+	procs, err := ListServices(ctx, projectDir, w)
+	if err != nil {
+		return resume, err
+	}
+	var running []string
+	for _, p := range procs {
+		if slices.Contains(names, p.Name) && isRunning(p) {
+			running = append(running, p.Name)
+		}
+	}
+	resume = func(ctx context.Context) error {
+		for _, name := range running {
+			if err := StartServices(ctx, w, name, projectDir); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range running {
+		if err := StopServices(ctx, name, projectDir, w); err != nil {
+			return resume, err
+		}
+	}
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		procs, err := ListServices(ctx, projectDir, w)
+		if err != nil {
+			return resume, err
+		}
+		stillRunning := slices.ContainsFunc(procs, func(p Process) bool {
+			return slices.Contains(running, p.Name) && isRunning(p)
+		})
+		if !stillRunning {
+			return resume, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return resume, fmt.Errorf("timed out waiting for services to stop: %w", ctx.Err())
+		}
+	}
+}
+
+func isRunning(p Process) bool {
+	switch p.Status {
+	case types.ProcessStateRunning, types.ProcessStateLaunching,
+		types.ProcessStateLaunched, types.ProcessStateRestarting,
+		types.ProcessStateTerminating:
+		return true
+	}
+	return false
+}
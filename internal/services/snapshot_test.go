@@ -0,0 +1,140 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPluginServices(t *testing.T) {
+	projectDir := t.TempDir()
+	virtenv := filepath.Join(projectDir, virtenvDir)
+	svcs := Services{
+		"mysql":      {Name: "mysql", ProcessComposePath: filepath.Join(virtenv, "mysql", "process-compose.yaml")},
+		"mysql_logs": {Name: "mysql_logs", ProcessComposePath: filepath.Join(virtenv, "mysql", "process-compose.yaml")},
+		"postgresql": {Name: "postgresql", ProcessComposePath: filepath.Join(virtenv, "postgresql", "process-compose.yaml")},
+		"web":        {Name: "web", ProcessComposePath: filepath.Join(projectDir, "process-compose.yaml")},
+	}
+	want := map[string][]string{
+		"mysql":      {"mysql", "mysql_logs"},
+		"postgresql": {"postgresql"},
+	}
+	if diff := cmp.Diff(want, PluginServices(projectDir, svcs)); diff != "" {
+		t.Errorf("wrong plugin services (-want +got):\n%s", diff)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	projectDir := t.TempDir()
+	dataFile := filepath.Join(projectDir, virtenvDir, "postgresql", "data", "table")
+	writeFile := func(contents string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(dataFile), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dataFile, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("seeded")
+	if _, err := SaveSnapshot(projectDir, "seeded", []string{"postgresql"}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile("changed by tests")
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dataFile), "new"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := ReadSnapshot(projectDir, "seeded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreSnapshot(projectDir, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(dataFile); err != nil || string(got) != "seeded" {
+		t.Errorf("got data %q, %v after restoring, want %q", got, err, "seeded")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dataFile), "new")); !os.IsNotExist(err) {
+		t.Errorf("got err %v for file created after the snapshot, want it to be removed", err)
+	}
+
+	snapshots, err := ListSnapshots(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Name != "seeded" || !slices.Equal(snapshots[0].Plugins, []string{"postgresql"}) {
+		t.Errorf("got snapshots %+v, want seeded of postgresql", snapshots)
+	}
+
+	if _, err := SaveSnapshot(projectDir, "../escape", []string{"postgresql"}); err == nil {
+		t.Error("got nil error saving a snapshot with an invalid name, want error")
+	}
+	if _, err := ReadSnapshot(projectDir, "missing"); err == nil {
+		t.Error("got nil error reading a missing snapshot, want error")
+	}
+}
+
+func TestPauseServices(t *testing.T) {
+	projectDir := t.TempDir()
+	path := filepath.Join(projectDir, "process-compose.yaml")
+	err := os.WriteFile(path, []byte(`
+processes:
+  db:
+    command: "sleep 60"
+  web:
+    command: "sleep 60"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSupervisor([]string{path}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := getAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestInstance(t, projectDir, fmt.Sprintf("http://localhost:%d", port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	runCtx, stop := context.WithCancel(ctx)
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(runCtx, port, nil) }()
+	defer func() {
+		stop()
+		<-runErr
+	}()
+	running := func(p types.ProcessState) bool { return p.Status == types.ProcessStateRunning }
+	waitForState(t, s, "db", running)
+	waitForState(t, s, "web", running)
+
+	resume, err := PauseServices(ctx, io.Discard, projectDir, []string{"db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db := findState(s, "db"); db.IsRunning {
+		t.Errorf("got db state %+v after pausing it, want it stopped", db)
+	}
+	if web := findState(s, "web"); !web.IsRunning {
+		t.Errorf("got web state %+v after pausing db, want it running", web)
+	}
+	if err := resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, s, "db", running)
+}
@@ -0,0 +1,765 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/health"
+	"github.com/f1bonacc1/process-compose/src/types"
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+const (
+	// supervisorLogLength is the number of log lines kept for each process,
+	// the same as the process-compose default.
+	supervisorLogLength = 1000
+
+	defaultShutdownTimeout = 10 * time.Second
+	defaultRestartBackoff  = time.Second
+)
+
+// Supervisor runs the processes in process-compose files without the
+// process-compose binary. It supports the parts of the file format that
+// services in devbox.json and the built-in plugins use: command, working_dir,
+// environment, depends_on, availability, readiness_probe, ready_log_line,
+// is_daemon, disabled, and shutdown. Other fields are ignored.
+//
+// While it runs, a Supervisor serves the process-compose API endpoints that
+// devbox uses to list, start, stop, and restart services and to read their
+// logs, so the rest of `devbox services` works the same with either one.
+type Supervisor struct {
+	shell   string
+	env     []string
+	order   []string // process names, with dependencies before dependents
+	printer *logPrinter
+
+	mu        sync.Mutex
+	processes map[string]*supervisedProcess
+	changed   chan struct{} // closed and replaced whenever a state changes
+	exit      context.CancelFunc
+}
+
+type supervisedProcess struct {
+	config types.ProcessConfig
+	logs   logBuffer
+
+	// The fields below are guarded by Supervisor.mu.
+	state     types.ProcessState
+	startedAt time.Time
+	cancel    context.CancelFunc // stops the current run, or nil if not running
+	done      chan struct{}      // closed when the current run ends
+}
+
+// NewSupervisor loads the processes in the process-compose files. If more
+// than one file defines a process, the last one wins. Process output is
+// written to out, prefixed with the process name.
+func NewSupervisor(files []string, out io.Writer) (*Supervisor, error) {
+	project, err := loadProcessComposeFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Supervisor{
+		shell:     "bash",
+		env:       project.Environment,
+		processes: map[string]*supervisedProcess{},
+		changed:   make(chan struct{}),
+	}
+	if _, err := exec.LookPath(s.shell); err != nil {
+		s.shell = "sh"
+	}
+	names := make([]string, 0, len(project.Processes))
+	for name, proc := range project.Processes {
+		names = append(names, name)
+		s.processes[name] = &supervisedProcess{
+			config: proc,
+			logs:   logBuffer{max: supervisorLogLength},
+			state:  *types.NewProcessState(&proc),
+		}
+	}
+	slices.Sort(names)
+	s.printer = newLogPrinter(out, names)
+
+	visiting := map[string]bool{}
+	var visit func(name string, from []string) error
+	visit = func(name string, from []string) error {
+		if slices.Contains(s.order, name) {
+			return nil
+		}
+		if visiting[name] {
+			return usererr.New("Processes have a circular dependency: %s -> %s",
+				strings.Join(from, " -> "), name)
+		}
+		visiting[name] = true
+		deps := make([]string, 0, len(s.processes[name].config.DependsOn))
+		for dep := range s.processes[name].config.DependsOn {
+			if _, ok := s.processes[dep]; !ok {
+				return usererr.New("Process %s depends on %s, which doesn't exist", name, dep)
+			}
+			deps = append(deps, dep)
+		}
+		slices.Sort(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(from, name)); err != nil {
+				return err
+			}
+		}
+		s.order = append(s.order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// RunSupervisor runs the processes in files with a Supervisor, serving its
+// API on port, until it's interrupted or terminated. It's what the hidden
+// `devbox services supervise` command runs.
+func RunSupervisor(ctx context.Context, out io.Writer, port int, files, names []string) error {
+	s, err := NewSupervisor(files, out)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return s.Run(ctx, port, names)
+}
+
+// Run starts the named processes and their dependencies, or every process
+// that isn't disabled if names is empty, and serves the API on port. It
+// returns after ctx is done and every process has stopped, or after a process
+// with the exit_on_failure restart policy fails.
+func (s *Supervisor) Run(ctx context.Context, port int, names []string) error {
+	for _, name := range names {
+		if _, ok := s.processes[name]; !ok {
+			return usererr.New("Process %s not found", name)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mu.Lock()
+	s.exit = cancel
+	s.mu.Unlock()
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	server := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	for _, name := range s.withDependencies(names) {
+		if err := s.Start(name); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	s.StopAll()
+	return nil
+}
+
+// withDependencies returns names and everything they depend on, in the order
+// they should start. If names is empty, it returns every enabled process.
+func (s *Supervisor) withDependencies(names []string) []string {
+	want := map[string]bool{}
+	var add func(name string)
+	add = func(name string) {
+		if want[name] {
+			return
+		}
+		want[name] = true
+		for dep := range s.processes[name].config.DependsOn {
+			add(dep)
+		}
+	}
+	for _, name := range names {
+		add(name)
+	}
+	if len(names) == 0 {
+		for name, p := range s.processes {
+			if !p.config.Disabled {
+				add(name)
+			}
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(s.order), func(name string) bool { return !want[name] })
+}
+
+// Start starts a process that isn't running. It waits for the process's
+// dependencies in the background.
+func (s *Supervisor) Start(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.processes[name]
+	if !ok {
+		return fmt.Errorf("process %s not found", name)
+	}
+	if p.cancel != nil {
+		return fmt.Errorf("process %s is already running", name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.cancel, p.done = cancel, done
+	p.state = types.ProcessState{
+		Name:       name,
+		Namespace:  types.DefaultNamespace,
+		Status:     types.ProcessStatePending,
+		SystemTime: types.PlaceHolderValue,
+		Health:     types.ProcessHealthUnknown,
+	}
+	s.notify()
+
+	go func() {
+		defer close(done)
+		s.run(ctx, p)
+
+		s.mu.Lock()
+		if p.done == done {
+			p.cancel = nil
+		}
+		s.mu.Unlock()
+		cancel()
+	}()
+	return nil
+}
+
+// Stop stops a running process and waits for it to exit.
+func (s *Supervisor) Stop(name string) error {
+	stopped, err := s.stop(name)
+	if err == nil && !stopped {
+		return fmt.Errorf("process %s is not running", name)
+	}
+	return err
+}
+
+// Restart stops a process if it's running and starts it again.
+func (s *Supervisor) Restart(name string) error {
+	if _, err := s.stop(name); err != nil {
+		return err
+	}
+	return s.Start(name)
+}
+
+// StopAll stops every running process, stopping dependents before the
+// processes they depend on.
+func (s *Supervisor) StopAll() {
+	for i := len(s.order) - 1; i >= 0; i-- {
+		_, _ = s.stop(s.order[i])
+	}
+}
+
+// stop stops a process if it's running and reports whether it was.
+func (s *Supervisor) stop(name string) (bool, error) {
+	s.mu.Lock()
+	p, ok := s.processes[name]
+	if !ok {
+		s.mu.Unlock()
+		return false, fmt.Errorf("process %s not found", name)
+	}
+	cancel, done := p.cancel, p.done
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return false, nil
+	}
+	cancel()
+	<-done
+	return true, nil
+}
+
+// States returns the state of every process, sorted by name.
+func (s *Supervisor) States() []types.ProcessState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make([]types.ProcessState, 0, len(s.processes))
+	for _, p := range s.processes {
+		state := p.state
+		if state.IsRunning {
+			state.Age = time.Since(p.startedAt)
+		}
+		states = append(states, state)
+	}
+	slices.SortFunc(states, func(a, b types.ProcessState) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return states
+}
+
+// run runs a process until ctx is canceled or it exits and its restart
+// policy says not to restart it.
+func (s *Supervisor) run(ctx context.Context, p *supervisedProcess) {
+	if !s.waitForDependencies(ctx, p) {
+		return
+	}
+
+	policy := p.config.RestartPolicy
+	backoff := defaultRestartBackoff
+	if policy.BackoffSeconds > 0 {
+		backoff = time.Duration(policy.BackoffSeconds) * time.Second
+	}
+	for restarts := 0; ; restarts++ {
+		exitCode := s.runOnce(ctx, p)
+		if ctx.Err() != nil {
+			s.update(p, func(state *types.ProcessState) {
+				state.Status = types.ProcessStateCompleted
+			})
+			return
+		}
+
+		restart := policy.Restart == types.RestartPolicyAlways ||
+			(policy.Restart == types.RestartPolicyOnFailure && exitCode != 0)
+		if policy.MaxRestarts > 0 && restarts >= policy.MaxRestarts {
+			restart = false
+		}
+		if !restart {
+			s.update(p, func(state *types.ProcessState) {
+				// Keep the error status of a process that failed to start.
+				if state.Status != types.ProcessStateError {
+					state.Status = types.ProcessStateCompleted
+				}
+			})
+			if policy.Restart == types.RestartPolicyExitOnFailure && exitCode != 0 {
+				s.logLine(p, fmt.Sprintf("exited with code %d, stopping all processes", exitCode))
+				s.mu.Lock()
+				if s.exit != nil {
+					s.exit()
+				}
+				s.mu.Unlock()
+			}
+			return
+		}
+
+		s.update(p, func(state *types.ProcessState) {
+			state.Status = types.ProcessStateRestarting
+			state.Restarts = restarts + 1
+		})
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			s.update(p, func(state *types.ProcessState) {
+				state.Status = types.ProcessStateCompleted
+			})
+			return
+		}
+	}
+}
+
+// waitForDependencies blocks until the conditions on the process's
+// dependencies are met. It returns false if a dependency failed, in which
+// case the process is skipped, or if ctx is done first.
+func (s *Supervisor) waitForDependencies(ctx context.Context, p *supervisedProcess) bool {
+	for {
+		s.mu.Lock()
+		met, failed := s.dependenciesMet(p)
+		changed := s.changed
+		s.mu.Unlock()
+
+		if failed != "" {
+			s.logLine(p, fmt.Sprintf("skipped because dependency %s failed", failed))
+			s.update(p, func(state *types.ProcessState) {
+				state.Status = types.ProcessStateSkipped
+			})
+			return false
+		}
+		if met {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			s.update(p, func(state *types.ProcessState) {
+				state.Status = types.ProcessStateCompleted
+			})
+			return false
+		}
+	}
+}
+
+// dependenciesMet reports whether every dependency condition of p is met,
+// or the name of a dependency that failed. s.mu must be held.
+func (s *Supervisor) dependenciesMet(p *supervisedProcess) (met bool, failed string) {
+	met = true
+	for name, dep := range p.config.DependsOn {
+		d := s.processes[name]
+		state := d.state
+		exited := state.Status == types.ProcessStateCompleted
+		if state.Status == types.ProcessStateError || state.Status == types.ProcessStateSkipped {
+			return false, name
+		}
+
+		switch dep.Condition {
+		case types.ProcessConditionCompleted:
+			met = met && exited
+		case types.ProcessConditionCompletedSuccessfully:
+			if exited && state.ExitCode != 0 {
+				return false, name
+			}
+			met = met && exited
+		case types.ProcessConditionHealthy, types.ProcessConditionLogReady:
+			if exited {
+				return false, name
+			}
+			// A dependency without a probe can never become healthy, so
+			// treat it as ready once it has started.
+			hasProbe := d.config.ReadinessProbe != nil || d.config.ReadyLogLine != ""
+			if hasProbe {
+				met = met && state.Health == types.ProcessHealthReady
+			} else {
+				met = met && hasStarted(state.Status)
+			}
+		default:
+			met = met && hasStarted(state.Status)
+		}
+	}
+	return met, ""
+}
+
+func hasStarted(status string) bool {
+	switch status {
+	case types.ProcessStateRunning, types.ProcessStateLaunching,
+		types.ProcessStateLaunched, types.ProcessStateCompleted,
+		types.ProcessStateRestarting:
+		return true
+	}
+	return false
+}
+
+// runOnce runs the process's command and returns its exit code. If ctx is
+// canceled first, it shuts the process down.
+func (s *Supervisor) runOnce(ctx context.Context, p *supervisedProcess) int {
+	cmd := s.command(context.Background(), p, p.config.Command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// Use a pipe rather than an io.Writer so that Wait doesn't block on
+	// output from daemons that outlive the command.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		s.failToStart(p, err)
+		return -1
+	}
+	cmd.Stdout, cmd.Stderr = pw, pw
+	err = cmd.Start()
+	pw.Close()
+	if err != nil {
+		pr.Close()
+		s.failToStart(p, err)
+		return -1
+	}
+	go s.readLogs(p, pr)
+
+	status := types.ProcessStateRunning
+	if p.config.IsDaemon {
+		status = types.ProcessStateLaunching
+	}
+	s.mu.Lock()
+	p.startedAt = time.Now()
+	p.state.Status = status
+	p.state.Pid = cmd.Process.Pid
+	p.state.IsRunning = true
+	p.state.ExitCode = 0
+	p.state.Health = types.ProcessHealthUnknown
+	s.notify()
+	s.mu.Unlock()
+
+	probeCtx, stopProbe := context.WithCancel(ctx)
+	defer stopProbe()
+	go s.probe(probeCtx, p)
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- cmd.Wait() }()
+	select {
+	case err = <-waitErr:
+	case <-ctx.Done():
+		err = s.shutdown(p, cmd, waitErr)
+	}
+	exitCode := 0
+	if exitErr := (&exec.ExitError{}); errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	if p.config.IsDaemon && exitCode == 0 && ctx.Err() == nil {
+		// The daemon has started and forked into the background. It keeps
+		// running until the process is stopped.
+		s.update(p, func(state *types.ProcessState) {
+			state.Status = types.ProcessStateLaunched
+		})
+		<-ctx.Done()
+		s.runShutdownCommand(p)
+	}
+
+	s.update(p, func(state *types.ProcessState) {
+		state.IsRunning = false
+		state.ExitCode = exitCode
+		state.Pid = 0
+		state.Health = types.ProcessHealthUnknown
+	})
+	return exitCode
+}
+
+// shutdown stops a running process with its shutdown command, or by
+// signaling its process group, and kills it if it doesn't exit in time.
+func (s *Supervisor) shutdown(p *supervisedProcess, cmd *exec.Cmd, waitErr <-chan error) error {
+	s.update(p, func(state *types.ProcessState) {
+		state.Status = types.ProcessStateTerminating
+	})
+
+	params := p.config.ShutDownParams
+	timeout := defaultShutdownTimeout
+	if params.ShutDownTimeout > 0 {
+		timeout = time.Duration(params.ShutDownTimeout) * time.Second
+	}
+	if params.ShutDownCommand != "" {
+		s.runShutdownCommand(p)
+	} else {
+		sig := syscall.SIGTERM
+		if params.Signal != 0 {
+			sig = syscall.Signal(params.Signal)
+		}
+		_ = syscall.Kill(-cmd.Process.Pid, sig)
+	}
+
+	select {
+	case err := <-waitErr:
+		return err
+	case <-time.After(timeout):
+		s.logLine(p, fmt.Sprintf("didn't stop after %s, killing it", timeout))
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return <-waitErr
+	}
+}
+
+func (s *Supervisor) runShutdownCommand(p *supervisedProcess) {
+	params := p.config.ShutDownParams
+	if params.ShutDownCommand == "" {
+		return
+	}
+	timeout := defaultShutdownTimeout
+	if params.ShutDownTimeout > 0 {
+		timeout = time.Duration(params.ShutDownTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	out, err := s.command(ctx, p, params.ShutDownCommand).CombinedOutput()
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if line != "" {
+			s.logLine(p, line)
+		}
+	}
+	if err != nil {
+		s.logLine(p, fmt.Sprintf("shutdown command failed: %v", err))
+	}
+}
+
+// probe runs the process's readiness probe until ctx is done and updates
+// its health with the result.
+func (s *Supervisor) probe(ctx context.Context, p *supervisedProcess) {
+	probe := p.config.ReadinessProbe
+	if probe == nil {
+		return
+	}
+	// These are the process-compose defaults.
+	period := 10
+	if probe.PeriodSeconds > 0 {
+		period = probe.PeriodSeconds
+	}
+	timeout := max(probe.TimeoutSeconds, 1)
+	successThreshold := max(probe.SuccessThreshold, 1)
+	failureThreshold := probe.FailureThreshold
+	if failureThreshold < 1 {
+		failureThreshold = 3
+	}
+
+	select {
+	case <-time.After(time.Duration(max(probe.InitialDelay, 0)) * time.Second):
+	case <-ctx.Done():
+		return
+	}
+	ticker := time.NewTicker(time.Duration(period) * time.Second)
+	defer ticker.Stop()
+	successes, failures := 0, 0
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		err := s.check(checkCtx, p, probe)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			successes, failures = successes+1, 0
+			if successes >= successThreshold {
+				s.setHealth(p, types.ProcessHealthReady)
+			}
+		} else {
+			successes, failures = 0, failures+1
+			if failures >= failureThreshold {
+				s.setHealth(p, types.ProcessHealthNotReady)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check runs a readiness probe once and returns an error if it fails.
+func (s *Supervisor) check(ctx context.Context, p *supervisedProcess, probe *health.Probe) error {
+	if probe.Exec != nil {
+		cmd := s.command(ctx, p, probe.Exec.Command)
+		if probe.Exec.WorkingDir != "" {
+			cmd.Dir = os.ExpandEnv(probe.Exec.WorkingDir)
+		}
+		return cmd.Run()
+	}
+	if probe.HttpGet == nil {
+		return nil
+	}
+	get := probe.HttpGet
+	scheme, host := get.Scheme, get.Host
+	if scheme == "" {
+		scheme = "http"
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	if get.Port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(get.Port))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+host+get.Path, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return errors.Errorf("got status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *Supervisor) setHealth(p *supervisedProcess, readiness string) {
+	s.update(p, func(state *types.ProcessState) {
+		if state.IsRunning {
+			state.Health = readiness
+		}
+	})
+}
+
+func (s *Supervisor) failToStart(p *supervisedProcess, err error) {
+	s.logLine(p, fmt.Sprintf("failed to start: %v", err))
+	s.update(p, func(state *types.ProcessState) {
+		state.Status = types.ProcessStateError
+		state.ExitCode = -1
+	})
+}
+
+// command returns a command that runs script with the process's working
+// directory and environment.
+func (s *Supervisor) command(ctx context.Context, p *supervisedProcess, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, s.shell, "-c", script)
+	cmd.Dir = os.ExpandEnv(p.config.WorkingDir)
+	cmd.Env = slices.Concat(os.Environ(), s.env, p.config.Environment)
+	return cmd
+}
+
+func (s *Supervisor) readLogs(p *supervisedProcess, r io.ReadCloser) {
+	defer r.Close()
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			s.logLine(p, strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Supervisor) logLine(p *supervisedProcess, line string) {
+	p.logs.add(line)
+	s.printer.print(p.config.Name, []string{line})
+
+	if p.config.ReadyLogLine != "" && strings.Contains(line, p.config.ReadyLogLine) {
+		s.update(p, func(state *types.ProcessState) {
+			if state.IsRunning {
+				state.Health = types.ProcessHealthReady
+			}
+		})
+	}
+}
+
+// update changes a process's state and wakes up anything waiting on it.
+func (s *Supervisor) update(p *supervisedProcess, fn func(state *types.ProcessState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&p.state)
+	s.notify()
+}
+
+// notify wakes up goroutines waiting for a state change. s.mu must be held.
+func (s *Supervisor) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// logBuffer keeps the last max lines that a process logged.
+type logBuffer struct {
+	mu    sync.Mutex
+	max   int
+	lines []string
+}
+
+func (b *logBuffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	if len(b.lines) > b.max {
+		b.lines = slices.Delete(b.lines, 0, len(b.lines)-b.max)
+	}
+}
+
+// get returns up to limit lines starting offsetFromEnd lines before the end,
+// the same way the process-compose logs endpoint does. A limit of 0 returns
+// every line up to the end.
+func (b *logBuffer) get(offsetFromEnd, limit int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	offsetFromEnd = min(max(offsetFromEnd, 0), len(b.lines))
+	start := len(b.lines) - offsetFromEnd
+	end := len(b.lines)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return slices.Clone(b.lines[start:end])
+}
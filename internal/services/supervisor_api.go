@@ -0,0 +1,66 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+)
+
+// handler serves the subset of the process-compose HTTP API that devbox
+// uses. Responses have the same shape as the ones from process-compose.
+func (s *Supervisor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /live", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+	})
+	mux.HandleFunc("GET /processes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, types.ProcessesState{States: s.States()})
+	})
+	mux.HandleFunc("GET /process/logs/{name}/{endOffset}/{limit}", s.handleLogs)
+	mux.HandleFunc("POST /process/start/{name}", s.handleAction(s.Start))
+	mux.HandleFunc("PATCH /process/stop/{name}", s.handleAction(s.Stop))
+	mux.HandleFunc("POST /process/restart/{name}", s.handleAction(s.Restart))
+	return mux
+}
+
+func (s *Supervisor) handleLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	endOffset, err := strconv.Atoi(r.PathValue("endOffset"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(r.PathValue("limit"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	p, ok := s.processes[name]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "process " + name + " not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"logs": p.logs.get(endOffset, limit)})
+}
+
+func (s *Supervisor) handleAction(action func(name string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if err := action(name); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"name": name})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+)
+
+const testSupervisorProject = `
+version: "0.5"
+processes:
+  db:
+    command: "echo starting && sleep 0.2 && echo ready to accept connections && sleep 60"
+    ready_log_line: "ready to accept"
+  web:
+    command: "echo web started && sleep 60"
+    depends_on:
+      db:
+        condition: process_log_ready
+  job:
+    command: "exit 3"
+  after-job:
+    command: "echo should not run"
+    depends_on:
+      job:
+        condition: process_completed_successfully
+  probed:
+    command: "sleep 60"
+    readiness_probe:
+      exec:
+        command: "test -n \"$PROBE_VAR\""
+      period_seconds: 1
+    environment:
+      - PROBE_VAR=set
+`
+
+func TestSupervisor(t *testing.T) {
+	projectDir := t.TempDir()
+	path := filepath.Join(projectDir, "process-compose.yaml")
+	if err := os.WriteFile(path, []byte(testSupervisorProject), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSupervisor([]string{path}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := getAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestInstance(t, projectDir, fmt.Sprintf("http://localhost:%d", port))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(ctx, port, nil) }()
+
+	waitForState(t, s, "web", func(p types.ProcessState) bool {
+		return p.Status == types.ProcessStateRunning
+	})
+	if db := findState(s, "db"); db.Health != types.ProcessHealthReady {
+		t.Errorf("got db health %q when web started, want %q", db.Health, types.ProcessHealthReady)
+	}
+	waitForState(t, s, "job", func(p types.ProcessState) bool {
+		return p.Status == types.ProcessStateCompleted && p.ExitCode == 3
+	})
+	waitForState(t, s, "after-job", func(p types.ProcessState) bool {
+		return p.Status == types.ProcessStateSkipped
+	})
+	waitForState(t, s, "probed", func(p types.ProcessState) bool {
+		return p.Health == types.ProcessHealthReady
+	})
+
+	// The API works with the same client as process-compose.
+	listed, err := ListServices(ctx, projectDir, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(listed))
+	for _, p := range listed {
+		names = append(names, p.Name)
+	}
+	if want := []string{"after-job", "db", "job", "probed", "web"}; !slices.Equal(names, want) {
+		t.Errorf("got services %v, want %v", names, want)
+	}
+	logs, err := GetServiceLogs(ctx, projectDir, "db", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"starting", "ready to accept connections"}; !slices.Equal(logs, want) {
+		t.Errorf("got db logs %q, want %q", logs, want)
+	}
+
+	webPid := findState(s, "web").Pid
+	if err := StopServices(ctx, "web", projectDir, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if web := findState(s, "web"); web.Status != types.ProcessStateCompleted || web.IsRunning {
+		t.Errorf("got web state %+v after stopping it, want it completed", web)
+	}
+	if processIsAlive(webPid) {
+		t.Errorf("web process %d is still running after stopping it", webPid)
+	}
+	if err := StopServices(ctx, "web", projectDir, io.Discard); err == nil {
+		t.Error("got nil error stopping web twice, want error")
+	}
+	if err := StartServices(ctx, io.Discard, "web", projectDir); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, s, "web", func(p types.ProcessState) bool {
+		return p.Status == types.ProcessStateRunning
+	})
+
+	dbPid := findState(s, "db").Pid
+	cancel()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("supervisor didn't stop")
+	}
+	if processIsAlive(dbPid) {
+		t.Errorf("db process %d is still running after the supervisor stopped", dbPid)
+	}
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	projectDir := t.TempDir()
+	path := filepath.Join(projectDir, "process-compose.yaml")
+	err := os.WriteFile(path, []byte(`
+processes:
+  flaky:
+    command: "echo run && exit 1"
+    availability:
+      restart: on_failure
+      backoff_seconds: 1
+      max_restarts: 1
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSupervisor([]string{path}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start("flaky"); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, s, "flaky", func(p types.ProcessState) bool {
+		return p.Status == types.ProcessStateCompleted && p.Restarts == 1
+	})
+	if got := s.processes["flaky"].logs.get(10, 0); !slices.Equal(got, []string{"run", "run"}) {
+		t.Errorf("got logs %q, want the process to run twice", got)
+	}
+}
+
+func TestSupervisorDependencyErrors(t *testing.T) {
+	tests := map[string]string{
+		"circular dependency": `
+processes:
+  a:
+    command: "true"
+    depends_on:
+      b: {}
+  b:
+    command: "true"
+    depends_on:
+      a: {}
+`,
+		"which doesn't exist": `
+processes:
+  a:
+    command: "true"
+    depends_on:
+      missing: {}
+`,
+	}
+	for want, project := range tests {
+		path := filepath.Join(t.TempDir(), "process-compose.yaml")
+		if err := os.WriteFile(path, []byte(project), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := NewSupervisor([]string{path}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %v, want error containing %q", err, want)
+		}
+	}
+}
+
+func findState(s *Supervisor, name string) types.ProcessState {
+	for _, state := range s.States() {
+		if state.Name == name {
+			return state
+		}
+	}
+	return types.ProcessState{}
+}
+
+func waitForState(t *testing.T, s *Supervisor, name string, ok func(types.ProcessState) bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !ok(findState(s, name)) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s, got state %+v", name, findState(s, name))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// SystemdUnit is a systemd unit file.
+type SystemdUnit struct {
+	// Name is the unit's file name, such as devbox-myapp-1a2b3c4d-web.service.
+	Name     string
+	Contents string
+}
+
+var unitNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// systemdUnitPrefix returns the prefix of the names of a project's units. It
+// includes a hash of the project directory so that projects with the same
+// directory name don't clash.
+func systemdUnitPrefix(projectDir string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(projectDir))
+	name := unitNameUnsafe.ReplaceAllString(filepath.Base(projectDir), "-")
+	return fmt.Sprintf("devbox-%s-%08x", strings.Trim(name, "-."), h.Sum32())
+}
+
+// SystemdTargetName returns the name of the target that starts all of a
+// project's services.
+func SystemdTargetName(projectDir string) string {
+	return systemdUnitPrefix(projectDir) + ".target"
+}
+
+func systemdServiceName(projectDir, service string) string {
+	return systemdUnitPrefix(projectDir) + "-" + unitNameUnsafe.ReplaceAllString(service, "-") + ".service"
+}
+
+// SystemdUnits converts services into systemd user units that run each
+// service's command in the devbox environment with `devbox run`, and a
+// target that starts all of them. devboxPath is the devbox binary that the
+// units run.
+//
+// Dependencies become Requires= and After=. A service with an exec readiness
+// probe only finishes starting once the probe passes, so services that
+// depend on it start after it's ready. Restart policies become Restart= and
+// RestartSec=. Daemons, which fork into the background, are Type=forking
+// units. Disabled services are skipped.
+func SystemdUnits(projectDir, devboxPath string, svcs Services) ([]SystemdUnit, error) {
+	if len(svcs) == 0 {
+		return nil, usererr.New("No services found in your project")
+	}
+	names := make([]string, 0, len(svcs))
+	for name := range svcs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		files = append(files, svcs[name].ProcessComposePath)
+	}
+	project, err := loadProcessComposeFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	target := SystemdTargetName(projectDir)
+	units := []SystemdUnit{}
+	wants := []string{}
+	for _, name := range names {
+		proc, ok := project.Processes[name]
+		if !ok || proc.Disabled {
+			continue
+		}
+		unit := systemdServiceName(projectDir, name)
+		wants = append(wants, unit)
+		units = append(units, SystemdUnit{
+			Name:     unit,
+			Contents: systemdServiceUnit(projectDir, devboxPath, target, project, proc, svcs[name]),
+		})
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintln(b, "# Generated by devbox. Do not edit.")
+	fmt.Fprintln(b, "[Unit]")
+	fmt.Fprintf(b, "Description=Devbox services for %s\n", projectDir)
+	fmt.Fprintf(b, "Wants=%s\n", strings.Join(wants, " "))
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "[Install]")
+	fmt.Fprintln(b, "WantedBy=default.target")
+	units = append(units, SystemdUnit{Name: target, Contents: b.String()})
+	return units, nil
+}
+
+func systemdServiceUnit(
+	projectDir, devboxPath, target string,
+	project *types.Project,
+	proc types.ProcessConfig,
+	svc Service,
+) string {
+	var deps []string
+	for dep := range proc.DependsOn {
+		if d, ok := project.Processes[dep]; ok && !d.Disabled {
+			deps = append(deps, systemdServiceName(projectDir, dep))
+		}
+	}
+	slices.Sort(deps)
+
+	devboxRun := func(script string) string {
+		return systemdQuoteArgs(devboxPath, "run", "--config", projectDir, "--", "bash", "-c", script)
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# Generated by devbox from %s. Do not edit.\n", svc.ProcessComposePath)
+	fmt.Fprintln(b, "[Unit]")
+	fmt.Fprintf(b, "Description=Devbox service %s for %s\n", proc.Name, projectDir)
+	fmt.Fprintf(b, "PartOf=%s\n", target)
+	if len(deps) > 0 {
+		fmt.Fprintf(b, "Requires=%s\n", strings.Join(deps, " "))
+		fmt.Fprintf(b, "After=%s\n", strings.Join(deps, " "))
+	}
+	if proc.RestartPolicy.MaxRestarts > 0 {
+		// systemd limits restarts within an interval rather than in total,
+		// so use a long interval.
+		fmt.Fprintln(b, "StartLimitIntervalSec=1d")
+		fmt.Fprintf(b, "StartLimitBurst=%d\n", proc.RestartPolicy.MaxRestarts+1)
+	}
+
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "[Service]")
+	if proc.IsDaemon {
+		fmt.Fprintln(b, "Type=forking")
+	} else {
+		fmt.Fprintln(b, "Type=simple")
+	}
+	workingDir := projectDir
+	if proc.WorkingDir != "" {
+		workingDir = proc.WorkingDir
+		if !filepath.IsAbs(workingDir) {
+			workingDir = filepath.Join(projectDir, workingDir)
+		}
+	}
+	fmt.Fprintf(b, "WorkingDirectory=%s\n", systemdEscape(workingDir))
+	for _, env := range slices.Concat(project.Environment, proc.Environment) {
+		fmt.Fprintf(b, "Environment=%s\n", systemdQuote(env))
+	}
+	fmt.Fprintf(b, "ExecStart=%s\n", devboxRun(proc.Command))
+	if probe := proc.ReadinessProbe; probe != nil && probe.Exec != nil {
+		period := 10
+		if probe.PeriodSeconds > 0 {
+			period = probe.PeriodSeconds
+		}
+		wait := fmt.Sprintf("until %s; do sleep %d; done", probe.Exec.Command, period)
+		if probe.InitialDelay > 0 {
+			wait = fmt.Sprintf("sleep %d; %s", probe.InitialDelay, wait)
+		}
+		fmt.Fprintf(b, "ExecStartPost=%s\n", devboxRun(wait))
+	}
+	if proc.ShutDownParams.ShutDownCommand != "" {
+		fmt.Fprintf(b, "ExecStop=%s\n", devboxRun(proc.ShutDownParams.ShutDownCommand))
+	}
+	if proc.ShutDownParams.Signal != 0 {
+		fmt.Fprintf(b, "KillSignal=%d\n", proc.ShutDownParams.Signal)
+	}
+	if proc.ShutDownParams.ShutDownTimeout > 0 {
+		fmt.Fprintf(b, "TimeoutStopSec=%d\n", proc.ShutDownParams.ShutDownTimeout)
+	}
+	switch proc.RestartPolicy.Restart {
+	case types.RestartPolicyAlways:
+		fmt.Fprintln(b, "Restart=always")
+	case types.RestartPolicyOnFailure, types.RestartPolicyExitOnFailure:
+		fmt.Fprintln(b, "Restart=on-failure")
+	default:
+		fmt.Fprintln(b, "Restart=no")
+	}
+	if proc.RestartPolicy.BackoffSeconds > 0 {
+		fmt.Fprintf(b, "RestartSec=%d\n", proc.RestartPolicy.BackoffSeconds)
+	}
+	return b.String()
+}
+
+// systemdQuoteArgs quotes command line arguments for ExecStart= and similar
+// settings, escaping $ so that the shell expands variables rather than
+// systemd.
+func systemdQuoteArgs(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(strings.ReplaceAll(arg, "$", "$$"))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// systemdQuote double-quotes a value and escapes the characters that systemd
+// would otherwise interpret.
+func systemdQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(systemdEscape(s)) + `"`
+}
+
+// systemdEscape escapes % specifiers.
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// SystemdUserUnitDir returns the directory that systemd loads user units
+// from.
+func SystemdUserUnitDir() string {
+	return xdg.ConfigSubpath(filepath.Join("systemd", "user"))
+}
+
+// WriteSystemdUnits writes units to dir and returns their paths.
+func WriteSystemdUnits(dir string, units []SystemdUnit) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	paths := make([]string, 0, len(units))
+	for _, unit := range units {
+		path := filepath.Join(dir, unit.Name)
+		if err := os.WriteFile(path, []byte(unit.Contents), 0o644); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// InstallSystemdUnits installs a project's units for the current user,
+// replacing any that were installed before, and enables and starts its
+// target.
+func InstallSystemdUnits(ctx context.Context, w io.Writer, projectDir string, units []SystemdUnit) error {
+	if err := removeSystemdUnitFiles(projectDir); err != nil {
+		return err
+	}
+	paths, err := WriteSystemdUnits(SystemdUserUnitDir(), units)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Fprintf(w, "Wrote %s\n", path)
+	}
+	if err := systemctl(ctx, "daemon-reload"); err != nil {
+		return err
+	}
+	target := SystemdTargetName(projectDir)
+	if err := systemctl(ctx, "enable", "--now", target); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Started %s. Run `systemctl --user status %s` to check on your services.\n", target, target)
+	return nil
+}
+
+// UninstallSystemdUnits stops and removes a project's units.
+func UninstallSystemdUnits(ctx context.Context, w io.Writer, projectDir string) error {
+	paths, err := systemdUnitFiles(projectDir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return usererr.New("No systemd units are installed for this project")
+	}
+
+	target := SystemdTargetName(projectDir)
+	if err := systemctl(ctx, "disable", "--now", target); err != nil {
+		// Keep going so that the files are removed even if systemd is in a
+		// bad state.
+		fmt.Fprintf(w, "Warning: failed to stop %s: %v\n", target, err)
+	}
+	if err := removeSystemdUnitFiles(projectDir); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Fprintf(w, "Removed %s\n", path)
+	}
+	return systemctl(ctx, "daemon-reload")
+}
+
+// systemdUnitFiles returns the paths of the project's installed units.
+func systemdUnitFiles(projectDir string) ([]string, error) {
+	prefix := systemdUnitPrefix(projectDir)
+	paths, err := filepath.Glob(filepath.Join(SystemdUserUnitDir(), prefix+"*"))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// The glob also matches prefixes of longer names, so check that what
+	// follows the prefix is a service or the target.
+	return slices.DeleteFunc(paths, func(path string) bool {
+		rest := strings.TrimPrefix(filepath.Base(path), prefix)
+		return rest != ".target" && !strings.HasPrefix(rest, "-")
+	}), nil
+}
+
+func removeSystemdUnitFiles(projectDir string) error {
+	paths, err := systemdUnitFiles(projectDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func systemctl(ctx context.Context, args ...string) error {
+	path, err := exec.LookPath("systemctl")
+	if err != nil {
+		return usererr.New("systemctl wasn't found. Installing services requires systemd.")
+	}
+	cmd := exec.CommandContext(ctx, path, append([]string{"--user"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return usererr.WithUserMessage(err, "systemctl --user %s failed: %s",
+			strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnits(t *testing.T) {
+	projectDir := filepath.Join(t.TempDir(), "my app")
+	path := filepath.Join(projectDir, "process-compose.yaml")
+	if err := os.MkdirAll(projectDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(path, []byte(`
+processes:
+  postgresql:
+    command: "pg_ctl start -o \"-k $PGHOST\""
+    is_daemon: true
+    shutdown:
+      command: "pg_ctl stop -m fast"
+    availability:
+      restart: always
+    readiness_probe:
+      exec:
+        command: "pg_isready"
+      period_seconds: 2
+  web:
+    command: "python -m http.server 8000 # 100%"
+    working_dir: site
+    environment:
+      - DEBUG=1
+    depends_on:
+      postgresql:
+        condition: process_healthy
+    availability:
+      restart: on_failure
+      backoff_seconds: 3
+      max_restarts: 5
+  old:
+    command: "true"
+    disabled: true
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svcs := Services{
+		"postgresql": {Name: "postgresql", ProcessComposePath: path},
+		"web":        {Name: "web", ProcessComposePath: path},
+		"old":        {Name: "old", ProcessComposePath: path},
+	}
+
+	units, err := SystemdUnits(projectDir, "/bin/devbox", svcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := systemdUnitPrefix(projectDir)
+	if !strings.HasPrefix(prefix, "devbox-my-app-") {
+		t.Errorf("got unit prefix %q, want it to start with devbox-my-app-", prefix)
+	}
+	contents := map[string]string{}
+	for _, unit := range units {
+		contents[strings.TrimPrefix(unit.Name, prefix)] = unit.Contents
+	}
+	if len(contents) != 3 {
+		t.Fatalf("got units %v, want postgresql, web, and the target", contents)
+	}
+
+	pg := contents["-postgresql.service"]
+	wantPG := []string{
+		"Type=forking",
+		`ExecStart="/bin/devbox" "run" "--config" "` + projectDir + `" "--" "bash" "-c" "pg_ctl start -o \"-k $$PGHOST\""`,
+		`ExecStartPost="/bin/devbox" "run" "--config" "` + projectDir + `" "--" "bash" "-c" "until pg_isready; do sleep 2; done"`,
+		`ExecStop="/bin/devbox" "run" "--config" "` + projectDir + `" "--" "bash" "-c" "pg_ctl stop -m fast"`,
+		"Restart=always",
+	}
+	for _, want := range wantPG {
+		if !strings.Contains(pg, want+"\n") {
+			t.Errorf("postgresql unit doesn't contain %q:\n%s", want, pg)
+		}
+	}
+
+	web := contents["-web.service"]
+	wantWeb := []string{
+		"Requires=" + prefix + "-postgresql.service",
+		"After=" + prefix + "-postgresql.service",
+		"PartOf=" + prefix + ".target",
+		"StartLimitBurst=6",
+		"Type=simple",
+		"WorkingDirectory=" + filepath.Join(projectDir, "site"),
+		`Environment="DEBUG=1"`,
+		`"python -m http.server 8000 # 100%%"`,
+		"Restart=on-failure",
+		"RestartSec=3",
+	}
+	for _, want := range wantWeb {
+		if !strings.Contains(web, want) {
+			t.Errorf("web unit doesn't contain %q:\n%s", want, web)
+		}
+	}
+
+	target := contents[".target"]
+	wantWants := "Wants=" + prefix + "-postgresql.service " + prefix + "-web.service\n"
+	if !strings.Contains(target, wantWants) {
+		t.Errorf("target doesn't contain %q:\n%s", wantWants, target)
+	}
+}
+
+func TestSystemdUnitFiles(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	projectDir := filepath.Join(t.TempDir(), "app")
+	otherDir := projectDir + "-other"
+
+	dir := SystemdUserUnitDir()
+	_, err := WriteSystemdUnits(dir, []SystemdUnit{
+		{Name: systemdServiceName(projectDir, "web")},
+		{Name: SystemdTargetName(projectDir)},
+		{Name: systemdServiceName(otherDir, "web")},
+		{Name: "unrelated.service"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := removeSystemdUnitFiles(projectDir); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	want := []string{systemdServiceName(otherDir, "web"), "unrelated.service"}
+	if strings.Join(left, ",") != strings.Join(want, ",") {
+		t.Errorf("got files %v after removing the project's units, want %v", left, want)
+	}
+}
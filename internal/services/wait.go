@@ -0,0 +1,162 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// waitPollInterval is how often WaitForServices checks process-compose.
+const waitPollInterval = 500 * time.Millisecond
+
+// Readiness describes whether a service is ready to be used.
+type Readiness string
+
+const (
+	// ReadinessReady means the service's readiness probe passed, or the
+	// service is running and has no probe.
+	ReadinessReady Readiness = "ready"
+
+	// ReadinessNotReady means the service is starting, or is running and
+	// its readiness probe hasn't passed yet.
+	ReadinessNotReady Readiness = "not ready"
+
+	// ReadinessFailed means the service exited with an error or was
+	// skipped because a dependency failed.
+	ReadinessFailed Readiness = "failed"
+
+	// ReadinessDisabled means process-compose isn't running the service.
+	ReadinessDisabled Readiness = "disabled"
+)
+
+// Readiness returns whether p is ready. hasProbe is true if the service has
+// a readiness probe, in which case it's only ready once the probe passes.
+func (p Process) Readiness(hasProbe bool) Readiness {
+	switch p.Status {
+	case types.ProcessStateDisabled:
+		return ReadinessDisabled
+	case types.ProcessStateError, types.ProcessStateSkipped:
+		return ReadinessFailed
+	case types.ProcessStateCompleted:
+		if p.ExitCode != 0 {
+			return ReadinessFailed
+		}
+		// A process that completed successfully, such as a migration, is
+		// done rather than ready, but nothing should wait for it any longer.
+		return ReadinessReady
+	case types.ProcessStateRunning, types.ProcessStateLaunched:
+		if !hasProbe || p.Health == types.ProcessHealthReady {
+			return ReadinessReady
+		}
+	}
+	return ReadinessNotReady
+}
+
+// WaitForServices blocks until all of the named services are ready, one of
+// them fails, or ctx is done. hasProbe reports whether a service has a
+// readiness probe. If serviceNames is empty, it waits for every service that
+// process-compose is running.
+func WaitForServices(
+	ctx context.Context,
+	w io.Writer,
+	projectDir string,
+	serviceNames []string,
+	hasProbe func(name string) bool,
+) error {
+	ready := map[string]bool{}
+	var pending []Process
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		processes, err := ListServices(ctx, projectDir, w)
+		// The process-compose server may not be listening yet if it was
+		// just started, so keep retrying until ctx is done.
+		if err == nil {
+			pending, err = pendingServices(w, processes, serviceNames, hasProbe, ready)
+			if err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return usererr.New("Timed out waiting for services: %s", describeProcesses(pending))
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// pendingServices returns the processes that aren't ready yet, and reports
+// the ones that became ready since the last call.
+func pendingServices(
+	w io.Writer,
+	processes []Process,
+	serviceNames []string,
+	hasProbe func(name string) bool,
+	ready map[string]bool,
+) ([]Process, error) {
+	byName := map[string]Process{}
+	for _, p := range processes {
+		byName[p.Name] = p
+	}
+	names := serviceNames
+	if len(names) == 0 {
+		for _, p := range processes {
+			names = append(names, p.Name)
+		}
+		slices.Sort(names)
+	}
+
+	pending := []Process{}
+	for _, name := range names {
+		p, ok := byName[name]
+		if !ok {
+			return nil, usererr.New("Service %s is not running in process-compose", name)
+		}
+		switch p.Readiness(hasProbe(name)) {
+		case ReadinessReady:
+			if !ready[name] {
+				ready[name] = true
+				fmt.Fprintf(w, "Service %s is ready.\n", name)
+			}
+		case ReadinessFailed:
+			return nil, usererr.New(
+				"Service %s failed with status %s and exit code %d. Run `devbox services logs %s` to see why.",
+				name, p.Status, p.ExitCode, name)
+		case ReadinessDisabled:
+			if len(serviceNames) > 0 {
+				return nil, usererr.New("Service %s is disabled in process-compose", name)
+			}
+		default:
+			pending = append(pending, p)
+		}
+	}
+	return pending, nil
+}
+
+func describeProcesses(processes []Process) string {
+	if len(processes) == 0 {
+		return "unable to connect to process-compose"
+	}
+	descriptions := make([]string, len(processes))
+	for i, p := range processes {
+		descriptions[i] = fmt.Sprintf("%s (%s)", p.Name, strings.ToLower(p.Status))
+	}
+	return strings.Join(descriptions, ", ")
+}
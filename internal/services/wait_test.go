@@ -0,0 +1,85 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+)
+
+func TestProcessReadiness(t *testing.T) {
+	tests := []struct {
+		process  Process
+		hasProbe bool
+		want     Readiness
+	}{
+		{Process{Status: types.ProcessStatePending}, false, ReadinessNotReady},
+		{Process{Status: types.ProcessStateRunning, Health: types.ProcessHealthUnknown}, false, ReadinessReady},
+		{Process{Status: types.ProcessStateRunning, Health: types.ProcessHealthUnknown}, true, ReadinessNotReady},
+		{Process{Status: types.ProcessStateLaunched, Health: types.ProcessHealthNotReady}, true, ReadinessNotReady},
+		{Process{Status: types.ProcessStateLaunched, Health: types.ProcessHealthReady}, true, ReadinessReady},
+		{Process{Status: types.ProcessStateCompleted}, false, ReadinessReady},
+		{Process{Status: types.ProcessStateCompleted, ExitCode: 1}, false, ReadinessFailed},
+		{Process{Status: types.ProcessStateSkipped}, true, ReadinessFailed},
+		{Process{Status: types.ProcessStateDisabled}, false, ReadinessDisabled},
+	}
+	for _, tt := range tests {
+		if got := tt.process.Readiness(tt.hasProbe); got != tt.want {
+			t.Errorf("got %+v.Readiness(%v) = %q, want %q", tt.process, tt.hasProbe, got, tt.want)
+		}
+	}
+}
+
+func TestWaitForServices(t *testing.T) {
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// postgresql becomes ready on the third poll.
+		health := types.ProcessHealthNotReady
+		if polls.Add(1) >= 3 {
+			health = types.ProcessHealthReady
+		}
+		_ = json.NewEncoder(w).Encode(types.ProcessesState{States: []types.ProcessState{
+			{Name: "postgresql", Status: types.ProcessStateLaunched, Health: health},
+			{Name: "web", Status: types.ProcessStateRunning, Health: types.ProcessHealthUnknown},
+			{Name: "broken", Status: types.ProcessStateError, ExitCode: 2},
+		}})
+	}))
+	defer server.Close()
+
+	projectDir := t.TempDir()
+	writeTestInstance(t, projectDir, server.URL)
+	hasProbe := func(name string) bool { return name == "postgresql" }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := WaitForServices(ctx, io.Discard, projectDir, []string{"postgresql", "web"}, hasProbe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polls.Load() < 3 {
+		t.Errorf("returned after %d polls, want at least 3", polls.Load())
+	}
+
+	err = WaitForServices(ctx, io.Discard, projectDir, []string{"broken"}, hasProbe)
+	if err == nil || !strings.Contains(err.Error(), "Service broken failed") {
+		t.Errorf("got error %v, want service broken to fail", err)
+	}
+
+	polls.Store(0)
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = WaitForServices(ctx, io.Discard, projectDir, []string{"postgresql"}, hasProbe)
+	if err == nil || !strings.Contains(err.Error(), "postgresql (launched)") {
+		t.Errorf("got error %v, want timeout waiting for postgresql", err)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// DefaultWatchDebounce is how long to wait after the last change to a
+// watched file before restarting a service.
+const DefaultWatchDebounce = 500 * time.Millisecond
+
+// watchAlwaysIgnore are directories that are never watched. Devbox writes to
+// .devbox while services run, so watching it could restart services in a loop.
+var watchAlwaysIgnore = []string{".git", ".devbox"}
+
+// WatchConfig is the set of files that restart a service when they change.
+type WatchConfig struct {
+	// Paths and Ignore are glob patterns relative to the project directory.
+	Paths  []string
+	Ignore []string
+
+	Debounce time.Duration
+}
+
+func (c WatchConfig) matches(rel string) bool {
+	return matchAny(c.Paths, rel) && !matchAny(c.Ignore, rel)
+}
+
+// ignoresDir reports whether no file in the directory rel can match, because
+// the directory itself is ignored.
+func (c WatchConfig) ignoresDir(rel string) bool {
+	for _, pattern := range c.Ignore {
+		if globMatch(pattern, rel) ||
+			globMatch(strings.TrimSuffix(pattern, "/**"), rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch reports whether rel matches pattern. Patterns are validated when
+// the config is loaded, so an invalid pattern doesn't match anything.
+func globMatch(pattern, rel string) bool {
+	ok, _ := doublestar.Match(pattern, rel)
+	return ok
+}
+
+func matchAny(patterns []string, rel string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		return globMatch(pattern, rel)
+	})
+}
+
+// debounceTimer restarts a service once its files stop changing.
+type debounceTimer struct {
+	name  string
+	timer *time.Timer
+}
+
+// WatchServices restarts services through the process manager when files
+// that match their WatchConfig change, until ctx is done. Changes are
+// debounced per service so that saving many files at once restarts a service
+// once.
+func WatchServices(
+	ctx context.Context, w io.Writer, projectDir string, watches map[string]WatchConfig,
+) error {
+	if len(watches) == 0 {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer watcher.Close()
+
+	ignoresDir := func(rel string) bool {
+		if slices.Contains(watchAlwaysIgnore, strings.Split(rel, "/")[0]) {
+			return true
+		}
+		// Watch a directory unless every service ignores it.
+		for _, cfg := range watches {
+			if !cfg.ignoresDir(rel) {
+				return false
+			}
+		}
+		return true
+	}
+	// fsnotify doesn't watch subdirectories, so add each directory,
+	// including ones that are created later.
+	addDirs := func(root string) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				// Directories can be removed while walking.
+				return nil
+			}
+			rel, err := filepath.Rel(projectDir, path)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if rel != "." && ignoresDir(filepath.ToSlash(rel)) {
+				return filepath.SkipDir
+			}
+			return errors.WithStack(watcher.Add(path))
+		})
+	}
+	if err := addDirs(projectDir); err != nil {
+		return err
+	}
+
+	timers := map[string]*debounceTimer{}
+	defer func() {
+		for _, t := range timers {
+			t.timer.Stop()
+		}
+	}()
+	restart := make(chan *debounceTimer)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(w, "Error watching files: %s\n", err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := addDirs(event.Name); err != nil {
+						fmt.Fprintf(w, "Error watching %s: %s\n", event.Name, err)
+					}
+				}
+			}
+			rel, err := filepath.Rel(projectDir, event.Name)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			for name, cfg := range watches {
+				if !cfg.matches(rel) {
+					continue
+				}
+				if t, ok := timers[name]; ok {
+					t.timer.Stop()
+				}
+				debounce := cfg.Debounce
+				if debounce == 0 {
+					debounce = DefaultWatchDebounce
+				}
+				t := &debounceTimer{name: name}
+				t.timer = time.AfterFunc(debounce, func() {
+					select {
+					case restart <- t:
+					case <-ctx.Done():
+					}
+				})
+				timers[name] = t
+			}
+		case t := <-restart:
+			// A timer can fire while a newer change to the same service
+			// replaces it. The newer timer restarts the service, so the
+			// replaced one doesn't, and it must not remove the newer one.
+			if timers[t.name] != t {
+				continue
+			}
+			delete(timers, t.name)
+			fmt.Fprintf(w, "Files changed, restarting service %s.\n", t.name)
+			if err := RestartServices(ctx, t.name, projectDir, w); err != nil {
+				fmt.Fprintf(w, "Error restarting service %s: %s\n", t.name, err)
+			}
+		}
+	}
+}
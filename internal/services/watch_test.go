@@ -0,0 +1,111 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/f1bonacc1/process-compose/src/types"
+)
+
+func TestWatchConfigMatches(t *testing.T) {
+	cfg := WatchConfig{
+		Paths:  []string{"**/*.go", "templates/*"},
+		Ignore: []string{"**/*_test.go", "vendor/**"},
+	}
+	tests := map[string]bool{
+		"main.go":                 true,
+		"internal/server/http.go": true,
+		"templates/index.html":    true,
+		"templates/a/index.html":  false,
+		"README.md":               false,
+		"server_test.go":          false,
+		"vendor/lib/lib.go":       false,
+	}
+	for rel, want := range tests {
+		if got := cfg.matches(rel); got != want {
+			t.Errorf("got matches(%q) = %t, want %t", rel, got, want)
+		}
+	}
+	if !cfg.ignoresDir("vendor") || cfg.ignoresDir("internal") {
+		t.Error("got wrong ignored directories, want only vendor ignored")
+	}
+}
+
+func TestWatchServices(t *testing.T) {
+	projectDir := t.TempDir()
+	path := filepath.Join(projectDir, "process-compose.yaml")
+	err := os.WriteFile(path, []byte(`
+processes:
+  web:
+    command: "sleep 60"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcDir := filepath.Join(projectDir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSupervisor([]string{path}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := getAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestInstance(t, projectDir, fmt.Sprintf("http://localhost:%d", port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	runCtx, stop := context.WithCancel(ctx)
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(runCtx, port, nil) }()
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- WatchServices(runCtx, io.Discard, projectDir, map[string]WatchConfig{
+			"web": {Paths: []string{"src/**/*.go"}, Debounce: 50 * time.Millisecond},
+		})
+	}()
+	defer func() {
+		stop()
+		<-runErr
+		if err := <-watchErr; err != nil {
+			t.Error(err)
+		}
+	}()
+	running := func(p types.ProcessState) bool { return p.Status == types.ProcessStateRunning }
+	waitForState(t, s, "web", running)
+	pid := findState(s, "web").Pid
+
+	// Give the watcher time to add the directories before changing files.
+	time.Sleep(200 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(projectDir, "README.md"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := findState(s, "web").Pid; got != pid {
+		t.Fatalf("web restarted after an unwatched file changed")
+	}
+
+	// Files in new directories are watched too.
+	newDir := filepath.Join(srcDir, "server")
+	if err := os.Mkdir(newDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(newDir, "main.go"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, s, "web", func(p types.ProcessState) bool {
+		return running(p) && p.Pid != pid
+	})
+}
@@ -25,7 +25,7 @@ func newFlakePlan(ctx context.Context, devbox devboxer) (*flakePlan, error) {
 	defer task.End()
 
 	for _, pluginConfig := range devbox.Config().IncludedPluginConfigs() {
-		if err := devbox.PluginManager().CreateFilesForConfig(pluginConfig); err != nil {
+		if err := devbox.PluginManager().CreateFilesForConfig(ctx, pluginConfig); err != nil {
 			return nil, err
 		}
 	}
@@ -56,7 +56,8 @@ var (
 
 // Start enables telemetry for the current program.
 func Start() {
-	if started || envir.DoNotTrack() || build.SentryDSN == "" || build.TelemetryKey == "" {
+	if started || envir.DoNotTrack() || envir.IsOffline() ||
+		build.SentryDSN == "" || build.TelemetryKey == "" {
 		return
 	}
 
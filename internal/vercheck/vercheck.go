@@ -65,7 +65,7 @@ func CheckVersion(w io.Writer, commandPath string) {
 		return
 	}
 
-	if envir.IsDevboxCloud() {
+	if envir.IsDevboxCloud() || envir.IsOffline() {
 		return
 	}
 
@@ -101,6 +101,9 @@ func CheckVersion(w io.Writer, commandPath string) {
 // for devbox. The production devbox application is actually this launcher script
 // that acts as "devbox" and delegates commands to the devbox CLI binary.
 func SelfUpdate(stdOut, stdErr io.Writer) error {
+	if envir.IsOffline() {
+		return usererr.New("Cannot update devbox in offline mode.")
+	}
 	if isNewLauncherAvailable() {
 		return selfUpdateLauncher(stdOut, stdErr)
 	}
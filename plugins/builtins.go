@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"context"
 	"embed"
 	"io/fs"
 	"regexp"
@@ -41,14 +42,20 @@ var builtInMap = map[*regexp.Regexp]string{
 }
 
 func BuiltInForPackage(pkgName string) ([]byte, error) {
+	return ForPackage(builtIn, pkgName)
+}
+
+// ForPackage is like BuiltInForPackage, but reads the plugins from fsys
+// instead of the ones that are embedded in devbox.
+func ForPackage(fsys fs.FS, pkgName string) ([]byte, error) {
 	for re, name := range builtInMap {
 		if re.MatchString(pkgName) {
-			return builtIn.ReadFile(name + ".json")
+			return fs.ReadFile(fsys, name+".json")
 		}
 	}
-	return builtIn.ReadFile(pkgName + ".json")
+	return fs.ReadFile(fsys, pkgName+".json")
 }
 
-func (f *BuiltIn) FileContent(contentPath string) ([]byte, error) {
+func (f *BuiltIn) FileContent(_ context.Context, contentPath string) ([]byte, error) {
 	return builtIn.ReadFile(contentPath)
 }
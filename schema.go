@@ -0,0 +1,14 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package devbox holds files at the root of the repository that devbox
+// embeds.
+package devbox
+
+import _ "embed"
+
+// PluginSchema is the JSON schema of plugin.json files, which editors also
+// use through the $schema field.
+//
+//go:embed .schema/devbox-plugin.schema.json
+var PluginSchema []byte